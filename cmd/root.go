@@ -1,16 +1,23 @@
 package cmd
 
 import (
-	"fmt"
+	"io"
+	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/yourusername/go-cli-tool/internal/logging"
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile    string
+	verbose    bool
+	configUsed string
+
+	// logger is the application logger configured from the log flags
+	logger    = slog.Default()
+	logCloser io.Closer
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -21,6 +28,14 @@ var rootCmd = &cobra.Command{
 in Go development, including proper project structure, testing,
 and documentation.`,
 	Version: "1.0.0",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return initLogging()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if logCloser != nil {
+			_ = logCloser.Close()
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -38,10 +53,20 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.go-cli-tool.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().String("log-level", "info", "log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("log-format", "text", "log format (text, json)")
+	rootCmd.PersistentFlags().String("log-file", "", "write logs to this file instead of stderr")
 
 	// Bind flags to viper
-	if err := viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag: %v\n", err)
+	for key, flag := range map[string]string{
+		"verbose":    "verbose",
+		"log.level":  "log-level",
+		"log.format": "log-format",
+		"log.file":   "log-file",
+	} {
+		if err := viper.BindPFlag(key, rootCmd.PersistentFlags().Lookup(flag)); err != nil {
+			slog.Error("failed to bind flag", "flag", flag, "error", err)
+		}
 	}
 }
 
@@ -65,7 +90,27 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil && verbose {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	if err := viper.ReadInConfig(); err == nil {
+		configUsed = viper.ConfigFileUsed()
+	}
+}
+
+// initLogging configures the application logger from flags and config
+func initLogging() error {
+	l, closer, err := logging.New(logging.Options{
+		Level:  viper.GetString("log.level"),
+		Format: viper.GetString("log.format"),
+		File:   viper.GetString("log.file"),
+	})
+	if err != nil {
+		return err
+	}
+
+	logger, logCloser = l, closer
+	slog.SetDefault(logger)
+
+	if configUsed != "" && verbose {
+		logger.Info("using config file", "path", configUsed)
 	}
+	return nil
 }
@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/yourusername/go-cli-tool/internal/notify"
 	"github.com/yourusername/go-cli-tool/internal/task"
+	"github.com/yourusername/go-cli-tool/internal/tracing"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -19,6 +27,17 @@ var (
 	taskList    bool
 	concurrency int
 	noColor     bool
+	watchMode   bool
+	debounce    time.Duration
+	runVars     []string
+	envFiles    []string
+	eventsFile  string
+
+	scheduleMode    string
+	explainSchedule bool
+	lockWait        bool
+	lockNoWait      bool
+	policyFile      string
 )
 
 // taskCmd represents the task command
@@ -40,6 +59,9 @@ Tasks can have dependencies, retry logic, and timeouts.`,
   # Execute a specific task
   go-cli-tool task run --file tasks.yaml --id my-task
 
+  # Re-run tasks whenever their source files change
+  go-cli-tool task run --file tasks.yaml --watch
+
   # List all tasks in a config file
   go-cli-tool task list --file tasks.yaml
 
@@ -90,6 +112,26 @@ func init() {
 	taskRunCmd.Flags().StringVar(&taskID, "id", "", "run specific task by ID")
 	taskRunCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 1, "number of concurrent tasks")
 	taskRunCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	taskRunCmd.Flags().StringArrayVar(&runVars, "var", nil, "set a run variable (key=value), can be repeated")
+	taskRunCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "load task env from a dotenv file, overriding the config (can be repeated)")
+	taskRunCmd.Flags().StringVar(&eventsFile, "events-file", "", "append run events as JSON lines to this file")
+	taskRunCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "re-run tasks when their sources change")
+	taskRunCmd.Flags().DurationVar(&debounce, "debounce", task.DefaultDebounce, "quiet period before re-running on changes")
+	taskRunCmd.Flags().StringVar(&scheduleMode, "schedule", string(task.ScheduleOrder), "how ready tasks are picked: order or critical-path")
+	taskRunCmd.Flags().BoolVar(&explainSchedule, "explain-schedule", false, "show when and why each task started")
+	taskRunCmd.Flags().StringVar(&historyFile, "history", task.DefaultHistoryFile, "file to record run history in, and read duration estimates from")
+	taskRunCmd.Flags().BoolVar(&lockWait, "wait", true, "wait for runs of other processes holding the workspace or a task lock")
+	taskRunCmd.Flags().BoolVar(&lockNoWait, "no-wait", false, "fail instead of waiting when a lock is held")
+	taskRunCmd.Flags().String("trace-endpoint", "", "export traces to this OTLP/HTTP endpoint (default is tracing.endpoint from config)")
+
+	if err := viper.BindPFlag("tracing.endpoint", taskRunCmd.Flags().Lookup("trace-endpoint")); err != nil {
+		slog.Error("failed to bind flag", "flag", "trace-endpoint", "error", err)
+	}
+
+	// Policy for untrusted configs
+	for _, c := range []*cobra.Command{taskRunCmd, taskValidateCmd, taskDaemonCmd, taskServeCmd, taskListenCmd} {
+		c.Flags().StringVar(&policyFile, "policy", "", "policy file restricting executables, arguments, workdirs and hosts of tasks")
+	}
 
 	// Flags for init command
 	taskInitCmd.Flags().BoolVar(&taskList, "example", false, "create file with example tasks")
@@ -97,18 +139,74 @@ func init() {
 
 func runTasks(cmd *cobra.Command, args []string) error {
 	// Load configuration
-	config, err := task.LoadConfig(taskFile)
+	config, data, err := loadTrustableConfig()
 	if err != nil {
 		return fmt.Errorf("❌ Failed to load config: %w", err)
 	}
+	if err := config.ApplyEnvFiles(envFiles); err != nil {
+		return fmt.Errorf("❌ Failed to load env file: %w", err)
+	}
 
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return fmt.Errorf("❌ Invalid config: %w", err)
 	}
 
+	if err := ensureTrusted(config, data); err != nil {
+		return err
+	}
+
+	vars, err := task.ParseVars(runVars)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	policy, err := checkPolicy(config, vars)
+	if err != nil {
+		return err
+	}
+
+	mode := task.ScheduleMode(scheduleMode)
+	if err := mode.Validate(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	history := task.NewHistory(historyFile)
+
 	// Create executor
 	executor := task.NewExecutor(concurrency, verbose)
+	executor.SetLogger(logger)
+	executor.Subscribe(task.NewLogFileSink(task.DefaultLogDir))
+	executor.Subscribe(task.NewOutputSink(os.Stdout, config.Tasks, task.OutputNone))
+	executor.SetSecretResolver(newSecretResolver())
+	executor.SetResources(config.Resources)
+	executor.SetPolicy(policy)
+	executor.SetHooks(config.Hooks)
+	if len(config.Notify) > 0 {
+		executor.Subscribe(newNotifySink(config))
+	}
+	wait := lockWait && !lockNoWait
+	executor.SetLocking(task.DefaultLockDir, wait)
+	if endpoint := traceEndpoint(); endpoint != "" {
+		exporter := tracing.NewOTLPExporter(endpoint, tracing.HeadersFromEnv())
+		executor.SetTracer(tracing.NewTracer(exporter, logger))
+	}
+
+	if eventsFile != "" {
+		sink, err := task.OpenJSONLinesFile(eventsFile)
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		defer sink.Close()
+		executor.Subscribe(sink)
+	}
+
+	if mode == task.ScheduleCriticalPath {
+		records, err := history.Records()
+		if err != nil {
+			return fmt.Errorf("❌ Failed to read history: %w", err)
+		}
+		executor.SetSchedule(mode, task.EstimateDurations(records))
+	}
 
 	// Add tasks
 	if err := executor.AddTasks(config.Tasks); err != nil {
@@ -117,15 +215,36 @@ func runTasks(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("📋 Loaded %d task(s) from %s\n\n", len(config.Tasks), taskFile)
 
+	if watchMode {
+		// The watcher locks each batch, so other runs get their turn while
+		// it is idle
+		return watchTasks(executor, config, vars, wait)
+	}
+
+	lock, err := acquireRunLock(wait)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			logger.Warn("failed to release run lock", "error", err)
+		}
+	}()
+
 	// Execute tasks
-	ctx := context.Background()
+	ctx := runContext(vars)
 	startTime := time.Now()
 
 	var execErr error
 	if taskID != "" {
 		// Execute specific task
 		fmt.Printf("▶️  Executing task: %s\n\n", taskID)
-		_, execErr = executor.ExecuteTask(ctx, taskID)
+		if ids := config.Instances(taskID); len(ids) > 0 {
+			// All instances of a matrix task
+			execErr = executor.ExecuteTasks(ctx, ids)
+		} else {
+			_, execErr = executor.ExecuteTask(ctx, taskID)
+		}
 	} else {
 		// Execute all tasks
 		fmt.Println("▶️  Executing all tasks...")
@@ -134,12 +253,182 @@ func runTasks(cmd *cobra.Command, args []string) error {
 
 	duration := time.Since(startTime)
 
-	// Display results
+	results := executor.GetResults()
+	for _, result := range results {
+		if err := history.Append(task.NewHistoryRecord(executor.RunID(), task.TriggerManual, result)); err != nil {
+			logger.Warn("failed to record history", "task_id", result.Task.ID, "error", err)
+		}
+	}
+
+	printSummary(results, duration)
+	printHookResults(executor.HookResults())
+	if explainSchedule {
+		printSchedule(executor.Schedule(), mode)
+	}
+
+	if execErr != nil {
+		return fmt.Errorf("\n⚠️  Execution completed with errors: %w", execErr)
+	}
+
+	return nil
+}
+
+// checkPolicy loads the --policy file, if any, and checks every task of
+// config against it, printing a denial message per denied task
+func checkPolicy(config *task.Config, vars map[string]string) (*task.Policy, error) {
+	if policyFile == "" {
+		return nil, nil
+	}
+	policy, err := task.LoadPolicy(policyFile)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to load policy: %w", err)
+	}
+	tasks := append(append([]*task.Task(nil), config.Tasks...), config.HookCommands()...)
+	denials := policy.CheckAll(task.WithVars(context.Background(), vars), tasks)
+	for _, denial := range denials {
+		fmt.Printf("🚫 %v\n", denial)
+	}
+	if len(denials) > 0 {
+		return nil, fmt.Errorf("❌ %d task(s) denied by policy %s", len(denials), policyFile)
+	}
+	return policy, nil
+}
+
+// acquireRunLock takes the lock guarding runs of the task file in this
+// workspace, so concurrent runs do not clobber each other's outputs
+func acquireRunLock(wait bool) (*task.Lock, error) {
+	path := task.ConfigLockPath(task.DefaultLockDir, taskFile)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	lock, err := task.AcquireLock(ctx, path, "task run", false)
+	var locked *task.LockedError
+	if errors.As(err, &locked) {
+		if !wait {
+			return nil, fmt.Errorf("another run is in progress: %w", err)
+		}
+		fmt.Printf("⏳ Waiting for the run of %s to finish...\n", locked.Holder)
+		lock, err = task.AcquireLock(ctx, path, "task run", true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire run lock: %w", err)
+	}
+	return lock, nil
+}
+
+// newNotifySink creates a sink sending the notifications configured in
+// config when runs finish
+func newNotifySink(config *task.Config) *task.NotifySink {
+	return task.NewNotifySink(notify.New(config.Notify, notify.DefaultStateFile, logger), logger)
+}
+
+// traceEndpoint returns the configured OTLP endpoint, falling back to the
+// standard OpenTelemetry environment variables
+func traceEndpoint() string {
+	if endpoint := viper.GetString("tracing.endpoint"); endpoint != "" {
+		return endpoint
+	}
+	return tracing.EndpointFromEnv()
+}
+
+// runContext returns the context for running tasks with vars. When this
+// process was started with a TRACEPARENT, its runs join that trace.
+func runContext(vars map[string]string) context.Context {
+	ctx := task.WithVars(context.Background(), vars)
+	if value := os.Getenv(task.TraceparentEnv); value != "" {
+		parent, err := tracing.ParseTraceparent(value)
+		if err != nil {
+			logger.Warn("ignoring invalid trace context", "error", err)
+			return ctx
+		}
+		ctx = tracing.WithRemoteParent(ctx, parent)
+	}
+	return ctx
+}
+
+// watchTasks runs tasks and keeps re-running them as their sources change
+func watchTasks(executor *task.Executor, config *task.Config, vars map[string]string, wait bool) error {
+	watched := config.Tasks
+	initial := []string{}
+	if taskID != "" {
+		ids := config.Instances(taskID)
+		if len(ids) == 0 {
+			ids = []string{taskID}
+		}
+		watched = nil
+		for _, id := range ids {
+			t, ok := findTask(config, id)
+			if !ok {
+				return fmt.Errorf("task %s not found", taskID)
+			}
+			watched = append(watched, t)
+			initial = append(initial, id)
+		}
+	} else {
+		// Tasks run by hooks only run as hooks
+		hookTasks := make(map[string]bool)
+		for _, id := range config.HookTaskIDs() {
+			hookTasks[id] = true
+		}
+		for _, t := range config.Tasks {
+			if !hookTasks[t.ID] {
+				initial = append(initial, t.ID)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(runContext(vars), os.Interrupt)
+	defer stop()
+
+	var startTime time.Time
+	watcher := task.NewWatcher(executor, watched, debounce)
+	watcher.LockPath = task.ConfigLockPath(task.DefaultLockDir, taskFile)
+	watcher.LockWait = wait
+	watcher.OnRunStart = func(ids []string) {
+		startTime = time.Now()
+		fmt.Printf("▶️  Running %d task(s): %s\n", len(ids), strings.Join(ids, ", "))
+	}
+	watcher.OnRunDone = func(ids []string, err error) {
+		if err == context.Canceled {
+			fmt.Println("⏹️  Run cancelled, sources changed")
+			return
+		}
+		var locked *task.LockedError
+		if errors.As(err, &locked) {
+			fmt.Printf("⏭️  Run skipped: %v\n", err)
+			fmt.Println("\n👀 Watching for changes... (press Ctrl+C to stop)")
+			return
+		}
+		results := make(map[string]*task.TaskResult)
+		for _, id := range ids {
+			if result, ok := executor.GetResult(id); ok {
+				results[id] = result
+			}
+		}
+		printSummary(results, time.Since(startTime))
+		printHookResults(executor.HookResults())
+		fmt.Println("\n👀 Watching for changes... (press Ctrl+C to stop)")
+	}
+
+	return watcher.Run(ctx, initial)
+}
+
+// findTask looks up a task by ID in the configuration
+func findTask(config *task.Config, id string) (*task.Task, bool) {
+	for _, t := range config.Tasks {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// printSummary displays the results table of an execution
+func printSummary(results map[string]*task.TaskResult, duration time.Duration) {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("📊 Execution Summary")
 	fmt.Println(strings.Repeat("=", 60))
 
-	results := executor.GetResults()
 	successCount := 0
 	failCount := 0
 
@@ -168,12 +457,89 @@ func runTasks(cmd *cobra.Command, args []string) error {
 	fmt.Printf("\nTotal Duration: %.2fs\n", duration.Seconds())
 	fmt.Printf("Success: %d\n", successCount)
 	fmt.Printf("Failed: %d\n", failCount)
+}
 
-	if execErr != nil {
-		return fmt.Errorf("\n⚠️  Execution completed with errors: %w", execErr)
+// printHookResults displays the results of the hooks of an execution, if
+// any ran
+func printHookResults(results []task.HookResult) {
+	if len(results) == 0 {
+		return
 	}
+	fmt.Println("\n🪝 Hooks")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Hook\tRuns\tStatus\tDuration\tMessage")
+	fmt.Fprintln(w, "----\t----\t------\t--------\t-------")
+	for _, hr := range results {
+		hook := string(hr.Kind)
+		if hr.Owner != "" {
+			hook = hr.Owner + " " + hook
+		}
+		runs := "task " + hr.Result.Task.ID
+		if hr.Hook.Task == "" {
+			runs = strings.TrimSpace(hr.Hook.Command + " " + strings.Join(hr.Hook.Args, " "))
+		}
+		status, message := "✅ Success", "Completed"
+		if !hr.Result.Success {
+			status, message = "❌ Failed", "Failed"
+			if hr.Result.Error != nil {
+				message = hr.Result.Error.Error()
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2fs\t%s\n", hook, runs, status, hr.Result.Duration.Seconds(), message)
+	}
+	w.Flush()
+}
 
-	return nil
+// printSchedule explains when each task of the run became ready and
+// started, and why it started before other ready tasks
+func printSchedule(entries []task.ScheduleEntry, mode task.ScheduleMode) {
+	if mode == "" {
+		mode = task.ScheduleOrder
+	}
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Printf("🧭 Schedule (%s, concurrency %d)\n", mode, concurrency)
+	fmt.Println(strings.Repeat("=", 60))
+	if len(entries) == 0 {
+		fmt.Println("No scheduling decisions: a single task was run")
+		return
+	}
+
+	offset := func(d time.Duration) string {
+		return fmt.Sprintf("+%.2fs", d.Seconds())
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Task ID\tReady\tStart\tEnd\tPriority\tPath\tWhy")
+	fmt.Fprintln(w, "-------\t-----\t-----\t---\t--------\t----\t---")
+	for _, entry := range entries {
+		var why []string
+		if entry.WaitedFor != "" {
+			why = append(why, "ready after "+entry.WaitedFor)
+		} else {
+			why = append(why, "ready at start")
+		}
+		if entry.Blocked != "" {
+			why = append(why, "waited for "+entry.Blocked)
+		}
+		if entry.Skipped {
+			why = append(why, "skipped, dependencies failed")
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t%d\t%s\t%s\n", entry.TaskID, offset(entry.Ready), entry.Priority, formatPath(entry, mode), strings.Join(why, "; "))
+			continue
+		}
+		if len(entry.Ahead) > 0 {
+			why = append(why, fmt.Sprintf("before %s (%s)", strings.Join(entry.Ahead, ", "), entry.Reason))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", entry.TaskID, offset(entry.Ready), offset(entry.Start), offset(entry.End), entry.Priority, formatPath(entry, mode), strings.Join(why, "; "))
+	}
+	w.Flush()
+}
+
+// formatPath shows the estimated critical path of an entry, when the
+// schedule used it
+func formatPath(entry task.ScheduleEntry, mode task.ScheduleMode) string {
+	if mode != task.ScheduleCriticalPath {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fs", entry.Path.Seconds())
 }
 
 func listTasks(cmd *cobra.Command, args []string) error {
@@ -211,12 +577,68 @@ func validateTasks(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("❌ Invalid config: %w", err)
 	}
 
+	if _, err := checkPolicy(config, nil); err != nil {
+		return err
+	}
+
 	fmt.Printf("✅ Configuration file '%s' is valid!\n", taskFile)
 	fmt.Printf("   Version: %s\n", config.Version)
 	fmt.Printf("   Tasks: %d\n", len(config.Tasks))
+
+	for _, t := range config.Tasks {
+		if len(t.Extends) == 0 {
+			continue
+		}
+		if err := printResolvedTask(t); err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+	}
 	return nil
 }
 
+// printResolvedTask prints a task that extends templates as it is after
+// merging, leaving out empty settings
+func printResolvedTask(t *task.Task) error {
+	var node yaml.Node
+	if err := node.Encode(t.Redacted()); err != nil {
+		return fmt.Errorf("failed to encode task %s: %w", t.ID, err)
+	}
+	pruneEmpty(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return fmt.Errorf("failed to encode task %s: %w", t.ID, err)
+	}
+
+	fmt.Printf("\n📄 %s (extends %s):\n", t.ID, strings.Join(t.Extends, ", "))
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		fmt.Printf("   %s\n", line)
+	}
+	return nil
+}
+
+// pruneEmpty removes keys with empty, zero or null values from a mapping
+func pruneEmpty(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	content := node.Content[:0]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch {
+		case key.Value == "extends":
+			continue
+		case value.Kind == yaml.ScalarNode && (value.Tag == "!!null" || value.Value == "" || value.Value == "0" || value.Value == "0s"):
+			continue
+		case (value.Kind == yaml.SequenceNode || value.Kind == yaml.MappingNode) && len(value.Content) == 0:
+			continue
+		}
+		content = append(content, key, value)
+	}
+	node.Content = content
+}
+
 func initTaskConfig(cmd *cobra.Command, args []string) error {
 	// Check if file already exists
 	if _, err := os.Stat(taskFile); err == nil {
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+var (
+	historyFile string
+	metricsAddr string
+)
+
+// taskDaemonCmd runs scheduled tasks
+var taskDaemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled tasks in the foreground",
+	Long: `Run tasks with a schedule field in-process until interrupted.
+
+Schedules use five-field cron syntax ("*/5 * * * *"), the @hourly,
+@daily, @weekly, @monthly and @yearly shorthands, or "@every 5m".
+A task is never started while its previous run is still going, every
+run is recorded in the history file, and the configuration file is
+reloaded when it changes without interrupting running tasks.
+
+With --metrics-addr, Prometheus metrics are served on /metrics.`,
+	Example: `  go-cli-tool task daemon --file tasks.yaml
+  go-cli-tool task daemon --file tasks.yaml --metrics-addr 127.0.0.1:9100`,
+	RunE: runDaemon,
+}
+
+func init() {
+	taskCmd.AddCommand(taskDaemonCmd)
+
+	taskDaemonCmd.Flags().StringVar(&historyFile, "history", task.DefaultHistoryFile, "file to record run history in")
+	taskDaemonCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address")
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Notifications are configured at startup; reloads keep them as they were
+	config, data, err := loadTrustableConfig()
+	if err != nil {
+		return fmt.Errorf("❌ Failed to load config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("❌ Invalid config: %w", err)
+	}
+	if err := ensureTrusted(config, data); err != nil {
+		return err
+	}
+	check, err := trustedContentCheck()
+	if err != nil {
+		return err
+	}
+	policy, err := checkPolicy(config, nil)
+	if err != nil {
+		return err
+	}
+
+	history := task.NewHistory(historyFile)
+	daemon := task.NewDaemon(taskFile, history, logger, verbose)
+	if len(config.Notify) > 0 {
+		daemon.Subscribe(newNotifySink(config))
+	}
+	daemon.Subscribe(task.NewLogFileSink(task.DefaultLogDir))
+	daemon.SetSecretResolver(newSecretResolver())
+	daemon.SetPolicy(policy)
+	daemon.SetConfigCheck(check)
+	if metricsAddr != "" {
+		collector := newCollector(history)
+		daemon.Subscribe(collector)
+		if err := serveMetrics(ctx, metricsAddr, collector); err != nil {
+			return fmt.Errorf("❌ Failed to serve metrics: %w", err)
+		}
+	}
+	if err := daemon.Run(ctx); err != nil {
+		return fmt.Errorf("❌ Daemon failed: %w", err)
+	}
+	return nil
+}
+
+// serveMetrics serves handler on /metrics at addr until ctx is done
+func serveMetrics(ctx context.Context, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server failed", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	logger.Info("serving metrics", "addr", listener.Addr().String())
+	return nil
+}
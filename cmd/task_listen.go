@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yourusername/go-cli-tool/internal/server"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+var listenAddr string
+
+// taskListenCmd serves webhook triggers
+var taskListenCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "Run tasks when their webhooks are called",
+	Long: `Serve the webhook triggers declared by tasks and run a task whenever
+a request arrives on its path.
+
+Webhooks with a secret require a GitHub-style "X-Hub-Signature-256"
+HMAC of the body or a GitLab "X-Gitlab-Token" header. The JSON payload
+is exposed to the task as WEBHOOK_* variables, e.g. WEBHOOK_REF for
+the pushed ref of a GitHub push event. Prometheus metrics are served
+on /metrics.`,
+	Example: `  go-cli-tool task listen --file tasks.yaml --addr :9000`,
+	RunE:    listenTasks,
+}
+
+func init() {
+	taskCmd.AddCommand(taskListenCmd)
+
+	taskListenCmd.Flags().StringVar(&listenAddr, "addr", "127.0.0.1:9000", "address to listen on")
+	taskListenCmd.Flags().StringVar(&historyFile, "history", task.DefaultHistoryFile, "file to record run history in")
+}
+
+func listenTasks(cmd *cobra.Command, args []string) error {
+	config, data, err := loadTrustableConfig()
+	if err != nil {
+		return fmt.Errorf("❌ Failed to load config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("❌ Invalid config: %w", err)
+	}
+	if err := ensureTrusted(config, data); err != nil {
+		return err
+	}
+	policy, err := checkPolicy(config, nil)
+	if err != nil {
+		return err
+	}
+
+	hooks := server.New(config, "")
+	defer hooks.Close()
+	history := task.NewHistory(historyFile)
+	hooks.SetHistory(history)
+	hooks.Subscribe(task.NewLogFileSink(task.DefaultLogDir))
+	hooks.SetSecretResolver(newSecretResolver())
+	hooks.SetPolicy(policy)
+	hooks.SetLockPath(task.ConfigLockPath(task.DefaultLockDir, taskFile))
+	collector := newCollector(history)
+	hooks.Subscribe(collector)
+	if len(config.Notify) > 0 {
+		hooks.Subscribe(newNotifySink(config))
+	}
+
+	handler, err := hooks.WebhookHandler()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	banner := fmt.Sprintf("🪝 Listening for webhooks on http://%s (%s)", listenAddr, strings.Join(hooks.WebhookPaths(), ", "))
+	return listenAndServe(listenAddr, withMetrics(handler, collector), banner)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+var (
+	logsRun    string
+	logsFollow bool
+)
+
+// taskLogsCmd prints the output logged by task attempts
+var taskLogsCmd = &cobra.Command{
+	Use:   "logs <task-id>",
+	Short: "Print the logged output of a task",
+	Long: `Print the output of a task from the per-run log files.
+
+Every attempt of every task is logged to .task/logs/<run-id>/<task-id>.<attempt>.log
+by "task run", "task daemon", "task serve" and "task listen". Without --run
+the most recent run of the task is shown. With --follow, new output is
+printed as it is written until the task finishes.`,
+	Example: `  go-cli-tool task logs build
+  go-cli-tool task logs build --run 20240119-101500-1a2b3c4d
+  go-cli-tool task logs nightly --follow`,
+	Args: cobra.ExactArgs(1),
+	RunE: showLogs,
+}
+
+func init() {
+	taskCmd.AddCommand(taskLogsCmd)
+
+	taskLogsCmd.Flags().StringVar(&logsRun, "run", "", "show the logs of this run (default is the latest run)")
+	taskLogsCmd.Flags().BoolVar(&logsFollow, "follow", false, "keep printing output until the task finishes")
+}
+
+func showLogs(cmd *cobra.Command, args []string) error {
+	taskID := args[0]
+	store := task.NewLogStore(task.DefaultLogDir)
+
+	runID := logsRun
+	if runID == "" {
+		latest, err := store.LatestRun(taskID)
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		runID = latest
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := store.Print(ctx, runID, taskID, cmd.OutOrStdout(), logsFollow); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/yourusername/go-cli-tool/internal/secrets"
+	"github.com/yourusername/go-cli-tool/internal/task"
+	"golang.org/x/term"
+)
+
+// taskSecretsCmd manages the local secrets vault
+var taskSecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage secrets in the local encrypted vault",
+	Long: `Manage secrets stored in a local vault file encrypted with a passphrase
+(AES-256-GCM, key derived with scrypt).
+
+Tasks reference vault secrets in env values as secret://vault/<name>.
+The passphrase is read from TASK_VAULT_PASSPHRASE or prompted for.`,
+	Example: `  echo -n "$TOKEN" | go-cli-tool task secrets set API_TOKEN
+  go-cli-tool task secrets list
+  go-cli-tool task secrets get API_TOKEN`,
+}
+
+var taskSecretsSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Store a secret, reading the value from stdin if omitted",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  setSecret,
+}
+
+var taskSecretsGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  getSecret,
+}
+
+var taskSecretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of stored secrets",
+	Args:  cobra.NoArgs,
+	RunE:  listSecrets,
+}
+
+var taskSecretsDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Remove a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  deleteSecret,
+}
+
+func init() {
+	taskCmd.AddCommand(taskSecretsCmd)
+	taskSecretsCmd.AddCommand(taskSecretsSetCmd, taskSecretsGetCmd, taskSecretsListCmd, taskSecretsDeleteCmd)
+
+	taskSecretsCmd.PersistentFlags().String("vault", task.DefaultVaultFile, "vault file (default is secrets.vault from config)")
+	viper.SetDefault("secrets.vault", task.DefaultVaultFile)
+	if err := viper.BindPFlag("secrets.vault", taskSecretsCmd.PersistentFlags().Lookup("vault")); err != nil {
+		slog.Error("failed to bind flag", "flag", "vault", "error", err)
+	}
+}
+
+func setSecret(cmd *cobra.Command, args []string) error {
+	vault, err := openVault(true)
+	if err != nil {
+		return err
+	}
+
+	var value string
+	if len(args) == 2 {
+		value = args[1]
+	} else {
+		value, err = readSecretValue(cmd, args[0])
+		if err != nil {
+			return fmt.Errorf("❌ Failed to read secret: %w", err)
+		}
+	}
+
+	vault.Set(args[0], value)
+	if err := vault.Save(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Stored secret %s\n", args[0])
+	return nil
+}
+
+func getSecret(cmd *cobra.Command, args []string) error {
+	vault, err := openVault(false)
+	if err != nil {
+		return err
+	}
+	value, err := vault.Get(cmd.Context(), args[0])
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), value)
+	return nil
+}
+
+func listSecrets(cmd *cobra.Command, args []string) error {
+	vault, err := openVault(false)
+	if err != nil {
+		return err
+	}
+	for _, name := range vault.Names() {
+		fmt.Fprintln(cmd.OutOrStdout(), name)
+	}
+	return nil
+}
+
+func deleteSecret(cmd *cobra.Command, args []string) error {
+	vault, err := openVault(false)
+	if err != nil {
+		return err
+	}
+	if !vault.Delete(args[0]) {
+		return fmt.Errorf("❌ Secret %s not found", args[0])
+	}
+	if err := vault.Save(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Deleted secret %s\n", args[0])
+	return nil
+}
+
+// openVault opens the configured vault. With create, a missing vault is
+// created and an interactively entered passphrase must be confirmed.
+func openVault(create bool) (*secrets.Vault, error) {
+	path := viper.GetString("secrets.vault")
+	_, statErr := os.Stat(path)
+	if statErr != nil && !create {
+		return nil, fmt.Errorf("❌ No vault found at %s, add a secret with \"task secrets set\"", path)
+	}
+
+	passphrase, err := vaultPassphrase(create && os.IsNotExist(statErr))
+	if err != nil {
+		return nil, fmt.Errorf("❌ %w", err)
+	}
+	vault, err := secrets.OpenVault(path, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to open vault: %w", err)
+	}
+	return vault, nil
+}
+
+// vaultPassphrase reads the passphrase from the environment or prompts for
+// it on the terminal, twice when confirm is set
+func vaultPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(secrets.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no vault passphrase: set %s", secrets.PassphraseEnv)
+	}
+
+	passphrase, err := prompt("Vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := prompt("Confirm passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// readSecretValue prompts for a secret on the terminal or reads it from
+// piped stdin, without the trailing newline
+func readSecretValue(cmd *cobra.Command, name string) (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return prompt(fmt.Sprintf("Value for %s: ", name))
+	}
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// prompt reads a line from the terminal without echoing it
+func prompt(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// newSecretResolver returns the resolver for task env values, with the
+// env and file providers and the configured vault as secret://vault/<name>
+func newSecretResolver() *secrets.Resolver {
+	resolver := secrets.NewResolver()
+	resolver.Register("vault", secrets.NewVaultProvider(viper.GetString("secrets.vault"), func() (string, error) {
+		return vaultPassphrase(false)
+	}))
+	return resolver
+}
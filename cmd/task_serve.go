@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/yourusername/go-cli-tool/internal/metrics"
+	"github.com/yourusername/go-cli-tool/internal/server"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+var (
+	serveAddr   string
+	serveNoAuth bool
+)
+
+// taskServeCmd exposes the executor over HTTP
+var taskServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API for triggering and observing runs",
+	Long: `Expose the tasks of a configuration file over a local HTTP/JSON API.
+
+Endpoints:
+  GET  /api/tasks              list tasks
+  POST /api/runs               start a run: {"task_id": "...", "vars": {...}}
+  GET  /api/runs               list runs
+  GET  /api/runs/{id}          run status and results
+  GET  /api/runs/{id}/logs     stream the run log (Server-Sent Events)
+  POST /api/runs/{id}/cancel   cancel a run
+  GET  /metrics                Prometheus metrics
+
+Requests must send "Authorization: Bearer <token>". The token is read
+from serve.token in the config file or the --token flag. /metrics is
+served without authentication.`,
+	Example: `  go-cli-tool task serve --file tasks.yaml --token s3cret
+  curl -H "Authorization: Bearer s3cret" -d '{"task_id":"build"}' localhost:8080/api/runs`,
+	RunE: serveTasks,
+}
+
+func init() {
+	taskCmd.AddCommand(taskServeCmd)
+
+	taskServeCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "address to listen on")
+	taskServeCmd.Flags().String("token", "", "bearer token required by the API (default is serve.token from config)")
+	taskServeCmd.Flags().StringVar(&historyFile, "history", task.DefaultHistoryFile, "file to record run history in")
+	taskServeCmd.Flags().BoolVar(&serveNoAuth, "no-auth", false, "serve without authentication")
+
+	if err := viper.BindPFlag("serve.token", taskServeCmd.Flags().Lookup("token")); err != nil {
+		slog.Error("failed to bind flag", "flag", "token", "error", err)
+	}
+}
+
+func serveTasks(cmd *cobra.Command, args []string) error {
+	config, data, err := loadTrustableConfig()
+	if err != nil {
+		return fmt.Errorf("❌ Failed to load config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("❌ Invalid config: %w", err)
+	}
+	if err := ensureTrusted(config, data); err != nil {
+		return err
+	}
+	policy, err := checkPolicy(config, nil)
+	if err != nil {
+		return err
+	}
+
+	token := viper.GetString("serve.token")
+	if token == "" && !serveNoAuth {
+		return fmt.Errorf("❌ No API token configured: set serve.token in the config file, pass --token, or use --no-auth")
+	}
+
+	api := server.New(config, token)
+	defer api.Close()
+	history := task.NewHistory(historyFile)
+	api.SetHistory(history)
+	api.Subscribe(task.NewLogFileSink(task.DefaultLogDir))
+	api.SetSecretResolver(newSecretResolver())
+	api.SetPolicy(policy)
+	api.SetLockPath(task.ConfigLockPath(task.DefaultLockDir, taskFile))
+	collector := newCollector(history)
+	api.Subscribe(collector)
+	if len(config.Notify) > 0 {
+		api.Subscribe(newNotifySink(config))
+	}
+
+	banner := fmt.Sprintf("🌐 Serving %d task(s) from %s on http://%s", len(config.Tasks), taskFile, serveAddr)
+	return listenAndServe(serveAddr, withMetrics(api.Handler(), collector), banner)
+}
+
+// newCollector creates a metrics collector seeded from run history
+func newCollector(history *task.History) *metrics.Collector {
+	collector := metrics.New()
+	records, err := history.Records()
+	if err != nil {
+		logger.Warn("failed to read run history for metrics", "error", err)
+	}
+	collector.Seed(records)
+	return collector
+}
+
+// withMetrics serves the collector on /metrics and everything else with handler
+func withMetrics(handler http.Handler, collector *metrics.Collector) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", collector)
+	mux.Handle("/", handler)
+	return mux
+}
+
+// listenAndServe serves handler on addr until interrupted
+func listenAndServe(addr string, handler http.Handler, banner string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+	fmt.Println(banner)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("❌ Server failed: %w", err)
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// Log streams stay open until their run ends, so don't wait on them
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			_ = srv.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yourusername/go-cli-tool/internal/task"
+	"github.com/yourusername/go-cli-tool/internal/trust"
+	"golang.org/x/term"
+)
+
+var (
+	trustList  bool
+	trustFlag  bool
+	trustStdin = bufio.NewReader(os.Stdin)
+)
+
+// taskTrustCmd marks a task configuration file as trusted
+var taskTrustCmd = &cobra.Command{
+	Use:   "trust [file]",
+	Short: "Trust a task configuration file to run",
+	Long: `Record a task configuration file, and the hash of its content, as trusted
+in the user config ($HOME/.go-cli-tool.yaml or --config).
+
+task run, daemon, serve and listen ask before running a configuration that
+is not trusted or that changed since it was trusted. The file defaults to --file.
+
+Only the configuration file itself is hashed: env_file files and scripts it
+references are not, so changes to them do not make it untrusted.`,
+	Example: `  go-cli-tool task trust
+  go-cli-tool task trust ci/tasks.yaml
+  go-cli-tool task trust --list`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: trustConfig,
+}
+
+// taskUntrustCmd removes a task configuration file from the trusted ones
+var taskUntrustCmd = &cobra.Command{
+	Use:   "untrust [file]",
+	Short: "Stop trusting a task configuration file",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  untrustConfig,
+}
+
+func init() {
+	taskCmd.AddCommand(taskTrustCmd, taskUntrustCmd)
+
+	taskTrustCmd.Flags().BoolVar(&trustList, "list", false, "list trusted configuration files")
+	for _, c := range []*cobra.Command{taskRunCmd, taskDaemonCmd, taskServeCmd, taskListenCmd} {
+		c.Flags().BoolVar(&trustFlag, "trust", false, "run without asking even if the configuration is not trusted, e.g. in CI")
+	}
+}
+
+// trustStore returns the store of trusted configurations. It always lives in
+// the user config: a .go-cli-tool.yaml in the workspace must not be able to
+// trust the workspace.
+func trustStore() (*trust.Store, error) {
+	if cfgFile != "" {
+		return trust.NewStore(cfgFile), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return trust.NewStore(filepath.Join(home, ".go-cli-tool.yaml")), nil
+}
+
+// configArg returns the configuration file named by args, or --file
+func configArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return taskFile
+}
+
+func trustConfig(cmd *cobra.Command, args []string) error {
+	store, err := trustStore()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	if trustList {
+		entries, err := store.Entries()
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No trusted configuration files")
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s  %s  %s\n", entry.SHA256[:12], entry.TrustedAt.Local().Format("2006-01-02 15:04"), entry.Path)
+		}
+		return nil
+	}
+
+	entry, err := store.Trust(configArg(args))
+	if err != nil {
+		return fmt.Errorf("❌ Failed to trust config: %w", err)
+	}
+	fmt.Printf("✅ Trusted %s (sha256 %s)\n", entry.Path, entry.SHA256[:12])
+	return nil
+}
+
+func untrustConfig(cmd *cobra.Command, args []string) error {
+	store, err := trustStore()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	path := configArg(args)
+	found, err := store.Untrust(path)
+	if err != nil {
+		return fmt.Errorf("❌ Failed to untrust config: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("❌ %s is not trusted", path)
+	}
+	fmt.Printf("✅ No longer trusting %s\n", path)
+	return nil
+}
+
+// loadTrustableConfig reads and parses the task file, and returns its
+// content for ensureTrusted, so the content checked is the one parsed
+func loadTrustableConfig() (*task.Config, []byte, error) {
+	data, err := os.ReadFile(taskFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	config, err := task.ParseConfig(data, taskFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, data, nil
+}
+
+// ensureTrusted asks before running config, parsed from data, if data is not
+// trusted or changed since it was trusted, and records it as trusted if the
+// user agrees. --trust skips the check.
+func ensureTrusted(config *task.Config, data []byte) error {
+	if trustFlag {
+		return nil
+	}
+	store, err := trustStore()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	status, err := store.CheckContent(taskFile, data)
+	if err != nil {
+		return fmt.Errorf("❌ Failed to check trust: %w", err)
+	}
+
+	switch status {
+	case trust.Trusted:
+		return nil
+	case trust.Changed:
+		fmt.Printf("⚠️  %s changed since you trusted it.\n", taskFile)
+	default:
+		fmt.Printf("⚠️  %s is not trusted yet.\n", taskFile)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("❌ refusing to run an untrusted config: review it, then run 'go-cli-tool task trust %s' or pass --trust", taskFile)
+	}
+
+	fmt.Println("It runs these commands:")
+	for _, t := range append(append([]*task.Task(nil), config.Tasks...), config.HookCommands()...) {
+		fmt.Printf("   %s: %s\n", t.ID, strings.TrimSpace(t.Command+" "+strings.Join(t.Args, " ")))
+	}
+	fmt.Print("Trust it and run? [y/N]: ")
+	answer, err := trustStdin.ReadString('\n')
+	if err != nil {
+		fmt.Println()
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("❌ not running untrusted config %s", taskFile)
+	}
+	if _, err := store.TrustContent(taskFile, data); err != nil {
+		return fmt.Errorf("❌ Failed to trust config: %w", err)
+	}
+	fmt.Println()
+	return nil
+}
+
+// trustedContentCheck returns a check refusing content of the task file
+// that is not trusted as it is, for configurations reloaded after
+// ensureTrusted. It returns nil with --trust.
+func trustedContentCheck() (func(data []byte) error, error) {
+	if trustFlag {
+		return nil, nil
+	}
+	store, err := trustStore()
+	if err != nil {
+		return nil, fmt.Errorf("❌ %w", err)
+	}
+	return func(data []byte) error {
+		status, err := store.CheckContent(taskFile, data)
+		if err != nil {
+			return fmt.Errorf("failed to check trust: %w", err)
+		}
+		switch status {
+		case trust.Trusted:
+			return nil
+		case trust.Changed:
+			return fmt.Errorf("%s changed since it was trusted: review it, then run 'go-cli-tool task trust %s'", taskFile, taskFile)
+		default:
+			return fmt.Errorf("%s is not trusted", taskFile)
+		}
+	}, nil
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures the application logger
+type Options struct {
+	// Level is one of debug, info, warn or error
+	Level string
+	// Format is text or json
+	Format string
+	// File receives the log records instead of stderr when set
+	File string
+}
+
+// New creates a logger from the options. The returned closer releases the
+// log file, if one was opened.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var w io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		if dir := filepath.Dir(opts.File); dir != "." {
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+			}
+		}
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w, closer = f, f
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q, expected text or json", opts.Format)
+	}
+
+	return slog.New(handler), closer, nil
+}
+
+// ParseLevel parses a level name
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
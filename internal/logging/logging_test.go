@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{name: "debug", want: slog.LevelDebug},
+		{name: "", want: slog.LevelInfo},
+		{name: "INFO", want: slog.LevelInfo},
+		{name: "warning", want: slog.LevelWarn},
+		{name: "error", want: slog.LevelError},
+		{name: "loud", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, err := ParseLevel(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, level)
+		})
+	}
+}
+
+func TestNew_JSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	logger, closer, err := New(Options{Level: "warn", Format: "json", File: path})
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept", "task_id", "build")
+	require.NoError(t, closer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, "kept", record["msg"])
+	assert.Equal(t, "build", record["task_id"])
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	_, _, err := New(Options{Format: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+// Package metrics collects task execution metrics from executor events and
+// exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "go_cli_tool"
+
+// DefaultBuckets are the upper bounds of the duration histogram in seconds,
+// covering quick checks up to hour-long nightly jobs
+var DefaultBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+// Collector turns executor events into metrics. It is safe for concurrent
+// use and can be subscribed to any number of executors.
+type Collector struct {
+	buckets []float64
+
+	mu          sync.Mutex
+	runs        map[runKey]float64
+	retries     map[string]float64
+	durations   map[string]*histogram
+	running     map[string]map[string]bool
+	lastSuccess map[string]float64
+}
+
+// runKey identifies a runs counter series
+type runKey struct {
+	taskID string
+	status task.TaskStatus
+}
+
+// histogram holds the cumulative bucket counts of one series
+type histogram struct {
+	counts []float64
+	sum    float64
+	count  float64
+}
+
+// New creates an empty collector
+func New() *Collector {
+	return &Collector{
+		buckets:     DefaultBuckets,
+		runs:        make(map[runKey]float64),
+		retries:     make(map[string]float64),
+		durations:   make(map[string]*histogram),
+		running:     make(map[string]map[string]bool),
+		lastSuccess: make(map[string]float64),
+	}
+}
+
+// Seed initialises the last success timestamps from recorded history, so
+// the metric survives restarts of the process
+func (c *Collector) Seed(records []task.HistoryRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, record := range records {
+		if record.Status != task.StatusCompleted {
+			continue
+		}
+		if ts := unixSeconds(record.EndTime.UnixNano()); ts > c.lastSuccess[record.TaskID] {
+			c.lastSuccess[record.TaskID] = ts
+		}
+	}
+}
+
+// Handle records an executor event
+func (c *Collector) Handle(event task.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch event.Type {
+	case task.EventTaskStarted:
+		if c.running[event.TaskID] == nil {
+			c.running[event.TaskID] = make(map[string]bool)
+		}
+		c.running[event.TaskID][event.RunID] = true
+
+	case task.EventTaskRetrying:
+		delete(c.running[event.TaskID], event.RunID)
+		c.retries[event.TaskID]++
+
+	case task.EventTaskSucceeded:
+		delete(c.running[event.TaskID], event.RunID)
+		c.runs[runKey{event.TaskID, task.StatusCompleted}]++
+		c.observe(event.TaskID, event.Duration.Seconds())
+		c.lastSuccess[event.TaskID] = unixSeconds(event.Time.UnixNano())
+
+	case task.EventTaskFailed:
+		delete(c.running[event.TaskID], event.RunID)
+		c.runs[runKey{event.TaskID, task.StatusFailed}]++
+		c.observe(event.TaskID, event.Duration.Seconds())
+
+	case task.EventTaskSkipped:
+		c.runs[runKey{event.TaskID, task.StatusSkipped}]++
+	}
+}
+
+// observe adds a duration to the task's histogram; c.mu must be held
+func (c *Collector) observe(taskID string, seconds float64) {
+	h, ok := c.durations[taskID]
+	if !ok {
+		h = &histogram{counts: make([]float64, len(c.buckets))}
+		c.durations[taskID] = h
+	}
+	for i, bound := range c.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = c.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (c *Collector) Write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var b strings.Builder
+
+	name := Namespace + "_task_runs_total"
+	header(&b, name, "counter", "Finished task executions by task ID and status.")
+	keys := make([]runKey, 0, len(c.runs))
+	for key := range c.runs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].taskID != keys[j].taskID {
+			return keys[i].taskID < keys[j].taskID
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, key := range keys {
+		sample(&b, name, labels("task_id", key.taskID, "status", string(key.status)), c.runs[key])
+	}
+
+	name = Namespace + "_task_retries_total"
+	header(&b, name, "counter", "Retried task attempts by task ID.")
+	for _, id := range sortedKeys(c.retries) {
+		sample(&b, name, labels("task_id", id), c.retries[id])
+	}
+
+	name = Namespace + "_task_duration_seconds"
+	header(&b, name, "histogram", "Duration of finished task executions in seconds.")
+	ids := make([]string, 0, len(c.durations))
+	for id := range c.durations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		h := c.durations[id]
+		for i, bound := range c.buckets {
+			sample(&b, name+"_bucket", labels("task_id", id, "le", formatFloat(bound)), h.counts[i])
+		}
+		sample(&b, name+"_bucket", labels("task_id", id, "le", "+Inf"), h.count)
+		sample(&b, name+"_sum", labels("task_id", id), h.sum)
+		sample(&b, name+"_count", labels("task_id", id), h.count)
+	}
+
+	name = Namespace + "_task_running"
+	header(&b, name, "gauge", "Task executions currently in progress by task ID.")
+	ids = ids[:0]
+	for id := range c.running {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sample(&b, name, labels("task_id", id), float64(len(c.running[id])))
+	}
+
+	name = Namespace + "_task_last_success_timestamp_seconds"
+	header(&b, name, "gauge", "Unix time of the last successful execution by task ID.")
+	for _, id := range sortedKeys(c.lastSuccess) {
+		sample(&b, name, labels("task_id", id), c.lastSuccess[id])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func header(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(b *strings.Builder, name, labels string, value float64) {
+	fmt.Fprintf(b, "%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+// labels formats name/value pairs as a Prometheus label set
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escapeLabel(pairs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func unixSeconds(nanos int64) float64 {
+	return float64(nanos) / 1e9
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+func render(t *testing.T, c *Collector) string {
+	t.Helper()
+	var b strings.Builder
+	require.NoError(t, c.Write(&b))
+	return b.String()
+}
+
+func TestCollector_Events(t *testing.T) {
+	finished := time.Unix(1700000000, 0)
+	events := []task.Event{
+		{Type: task.EventTaskStarted, RunID: "r1", TaskID: "build", Attempt: 1},
+		{Type: task.EventTaskRetrying, RunID: "r1", TaskID: "build", Attempt: 1, Duration: 200 * time.Millisecond},
+		{Type: task.EventTaskStarted, RunID: "r1", TaskID: "build", Attempt: 2},
+		{Type: task.EventTaskSucceeded, RunID: "r1", TaskID: "build", Attempt: 2, Duration: 2 * time.Second, Time: finished},
+		{Type: task.EventTaskStarted, RunID: "r1", TaskID: "test", Attempt: 1},
+		{Type: task.EventTaskFailed, RunID: "r1", TaskID: "test", Attempt: 1, Duration: 45 * time.Second},
+		{Type: task.EventTaskSkipped, RunID: "r1", TaskID: "deploy"},
+		{Type: task.EventTaskStarted, RunID: "r2", TaskID: "build", Attempt: 1},
+	}
+
+	c := New()
+	for _, event := range events {
+		c.Handle(event)
+	}
+	out := render(t, c)
+
+	for _, line := range []string{
+		`# TYPE go_cli_tool_task_runs_total counter`,
+		`go_cli_tool_task_runs_total{task_id="build",status="completed"} 1`,
+		`go_cli_tool_task_runs_total{task_id="deploy",status="skipped"} 1`,
+		`go_cli_tool_task_runs_total{task_id="test",status="failed"} 1`,
+		`go_cli_tool_task_retries_total{task_id="build"} 1`,
+		`# TYPE go_cli_tool_task_duration_seconds histogram`,
+		`go_cli_tool_task_duration_seconds_bucket{task_id="build",le="1"} 0`,
+		`go_cli_tool_task_duration_seconds_bucket{task_id="build",le="5"} 1`,
+		`go_cli_tool_task_duration_seconds_bucket{task_id="build",le="+Inf"} 1`,
+		`go_cli_tool_task_duration_seconds_sum{task_id="test"} 45`,
+		`go_cli_tool_task_duration_seconds_count{task_id="test"} 1`,
+		`go_cli_tool_task_running{task_id="build"} 1`,
+		`go_cli_tool_task_running{task_id="test"} 0`,
+		`go_cli_tool_task_last_success_timestamp_seconds{task_id="build"} 1.7e+09`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.NotContains(t, out, `last_success_timestamp_seconds{task_id="test"}`)
+}
+
+func TestCollector_Seed(t *testing.T) {
+	c := New()
+	c.Seed([]task.HistoryRecord{
+		{TaskID: "nightly", Status: task.StatusCompleted, EndTime: time.Unix(100, 0)},
+		{TaskID: "nightly", Status: task.StatusCompleted, EndTime: time.Unix(300, 0)},
+		{TaskID: "nightly", Status: task.StatusFailed, EndTime: time.Unix(500, 0)},
+		{TaskID: "broken", Status: task.StatusFailed, EndTime: time.Unix(500, 0)},
+	})
+	out := render(t, c)
+
+	assert.Contains(t, out, `go_cli_tool_task_last_success_timestamp_seconds{task_id="nightly"} 300`+"\n")
+	assert.NotContains(t, out, `task_id="broken"`)
+}
+
+func TestCollector_ExecutorAndHTTP(t *testing.T) {
+	c := New()
+	executor := task.NewExecutor(1, false)
+	executor.Subscribe(c)
+	require.NoError(t, executor.AddTask(&task.Task{
+		ID: "ok", Name: "OK", Type: task.TaskTypeCommand, Command: "go", Args: []string{"version"},
+	}))
+	require.NoError(t, executor.AddTask(&task.Task{
+		ID: "bad", Name: "Bad", Type: task.TaskTypeCommand, Command: "go", Args: []string{"no-such-command"},
+		DependsOn: []string{"ok"},
+	}))
+	assert.Error(t, executor.ExecuteAll(context.Background()))
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	body := rec.Body.String()
+	assert.Contains(t, body, `go_cli_tool_task_runs_total{task_id="ok",status="completed"} 1`)
+	assert.Contains(t, body, `go_cli_tool_task_runs_total{task_id="bad",status="failed"} 1`)
+	assert.Contains(t, body, `go_cli_tool_task_running{task_id="bad"} 0`)
+	assert.Contains(t, body, `go_cli_tool_task_last_success_timestamp_seconds{task_id="ok"}`)
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabel("a\"b\\c\nd"))
+}
@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// subject returns the default one-line summary of run
+func subject(run *Run) string {
+	icon, outcome := "✅", "succeeded"
+	if run.Status == StatusFailure {
+		icon, outcome = "❌", "failed"
+	}
+	return fmt.Sprintf("%s Run %s %s on %s", icon, run.ID, outcome, run.Host)
+}
+
+// message returns the default message about run
+func message(run *Run) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s after %s\n", subject(run), run.Duration.Round(time.Millisecond))
+	if run.Changed {
+		fmt.Fprintf(&b, "The previous run's status was %s.\n", run.PreviousStatus)
+	}
+	if failed := run.Failed(); len(failed) > 0 {
+		fmt.Fprintf(&b, "%d of %d tasks did not succeed:\n", len(failed), len(run.Tasks))
+		for _, t := range failed {
+			fmt.Fprintf(&b, "• %s: %s\n", t.ID, t.Error)
+		}
+	} else if run.Error != "" {
+		fmt.Fprintf(&b, "%s\n", run.Error)
+	}
+	return b.String()
+}
+
+// sendWebhook posts the rendered body, or the run as JSON, to the channel
+func (n *Notifier) sendWebhook(ctx context.Context, channel Channel, run *Run) error {
+	fallback, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	body, err := render("body", channel.Body, string(fallback), run)
+	if err != nil {
+		return err
+	}
+	if !json.Valid([]byte(body)) {
+		return fmt.Errorf("rendered body is not valid JSON")
+	}
+	return n.post(ctx, channel, []byte(body))
+}
+
+// sendSlack posts a Slack-compatible incoming webhook payload
+func (n *Notifier) sendSlack(ctx context.Context, channel Channel, run *Run) error {
+	text, err := render("text", channel.Text, message(run), run)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, channel, body)
+}
+
+// post sends a JSON body to the channel's URL
+func (n *Notifier) post(ctx context.Context, channel Channel, body []byte) error {
+	url := channel.url()
+	if url == "" {
+		return fmt.Errorf("no url: %s is not set", channel.URLEnv)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range channel.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
+
+// sendEmail sends the notification over SMTP, upgrading to TLS when the
+// server supports it. Credentials are only sent over TLS or to localhost.
+func sendEmail(ctx context.Context, channel Channel, run *Run) error {
+	subj, err := render("subject", channel.Subject, subject(run), run)
+	if err != nil {
+		return err
+	}
+	text, err := render("text", channel.Text, message(run), run)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(channel.SMTP)
+	if err != nil {
+		return fmt.Errorf("invalid smtp address: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", channel.SMTP)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if channel.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", channel.Username, channel.password(), host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(channel.From); err != nil {
+		return err
+	}
+	for _, to := range channel.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	headers := []string{
+		"From: " + channel.From,
+		"To: " + strings.Join(channel.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subj),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(text, "\n", "\r\n")
+	if _, err := io.WriteString(w, body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// desktopCommand returns the command showing a desktop notification on
+// this platform
+var desktopCommand = func(ctx context.Context, title, text string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(text), appleScriptString(title))
+		return exec.CommandContext(ctx, "osascript", "-e", script)
+	case "windows":
+		// Title and text are passed in the environment, so they need no quoting
+		script := `Add-Type -AssemblyName System.Windows.Forms; ` +
+			`$n = New-Object System.Windows.Forms.NotifyIcon; ` +
+			`$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; ` +
+			`$n.ShowBalloonTip(10000, $env:NOTIFY_TITLE, $env:NOTIFY_TEXT, 'None'); ` +
+			`Start-Sleep -Seconds 5; $n.Dispose()`
+		cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script)
+		cmd.Env = append(os.Environ(), "NOTIFY_TITLE="+title, "NOTIFY_TEXT="+text)
+		return cmd
+	default:
+		return exec.CommandContext(ctx, "notify-send", "--app-name=go-cli-tool", title, text)
+	}
+}
+
+// sendDesktop shows a desktop notification
+func sendDesktop(ctx context.Context, channel Channel, run *Run) error {
+	title, err := render("subject", channel.Subject, subject(run), run)
+	if err != nil {
+		return err
+	}
+	text, err := render("text", channel.Text, message(run), run)
+	if err != nil {
+		return err
+	}
+	if out, err := desktopCommand(ctx, title, text).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,324 @@
+// Package notify sends notifications about finished runs to webhooks,
+// Slack, email and the desktop.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultStateFile records the last status of each kind of run, to tell
+// when it changed
+const DefaultStateFile = ".task/notify.json"
+
+// sendTimeout bounds the delivery of a notification to one channel
+const sendTimeout = 10 * time.Second
+
+// ChannelType defines where a notification is sent
+type ChannelType string
+
+const (
+	ChannelWebhook ChannelType = "webhook" // POST a JSON body to a URL
+	ChannelSlack   ChannelType = "slack"   // POST to a Slack-compatible incoming webhook
+	ChannelEmail   ChannelType = "email"   // Send an email over SMTP
+	ChannelDesktop ChannelType = "desktop" // Show a desktop notification
+)
+
+// Trigger defines which runs a channel is notified of
+type Trigger string
+
+const (
+	TriggerSuccess Trigger = "success" // Runs that succeeded
+	TriggerFailure Trigger = "failure" // Runs that failed
+	TriggerChange  Trigger = "change"  // Runs whose status differs from the previous run of the same tasks
+)
+
+// Channel is an entry of the notify block of a task configuration
+type Channel struct {
+	Type ChannelType `yaml:"type"`
+	// On lists the runs to notify of; defaults to failure
+	On []Trigger `yaml:"on,omitempty"`
+
+	// URL of a webhook or Slack channel. URLEnv names an environment
+	// variable holding the URL instead, as it usually embeds a secret.
+	URL     string            `yaml:"url,omitempty"`
+	URLEnv  string            `yaml:"url_env,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Body is a template rendering the JSON body of a webhook; defaults to
+	// the run as JSON
+	Body string `yaml:"body,omitempty"`
+
+	// Text is a template rendering the message of Slack, email and desktop
+	// notifications
+	Text string `yaml:"text,omitempty"`
+	// Subject is a template rendering the email subject or desktop title
+	Subject string `yaml:"subject,omitempty"`
+
+	// SMTP is the host:port of the mail server
+	SMTP        string   `yaml:"smtp,omitempty"`
+	From        string   `yaml:"from,omitempty"`
+	To          []string `yaml:"to,omitempty"`
+	Username    string   `yaml:"username,omitempty"`
+	Password    string   `yaml:"password,omitempty"`
+	PasswordEnv string   `yaml:"password_env,omitempty"`
+}
+
+// Validate checks if the channel configuration is valid
+func (c Channel) Validate() error {
+	for _, on := range c.On {
+		switch on {
+		case TriggerSuccess, TriggerFailure, TriggerChange:
+		default:
+			return fmt.Errorf("invalid notify trigger %q (want success, failure or change)", on)
+		}
+	}
+
+	switch c.Type {
+	case ChannelWebhook, ChannelSlack:
+		if c.URL == "" && c.URLEnv == "" {
+			return fmt.Errorf("%s notification needs a url or url_env", c.Type)
+		}
+	case ChannelEmail:
+		if c.SMTP == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("email notification needs smtp, from and to")
+		}
+	case ChannelDesktop:
+	default:
+		return fmt.Errorf("invalid notify type %q (want webhook, slack, email or desktop)", c.Type)
+	}
+
+	templates := []struct{ name, text string }{{"body", c.Body}, {"text", c.Text}, {"subject", c.Subject}}
+	for _, tmpl := range templates {
+		if _, err := parseTemplate(tmpl.name, tmpl.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifies reports whether the channel is notified of run
+func (c Channel) notifies(run *Run) bool {
+	on := c.On
+	if len(on) == 0 {
+		on = []Trigger{TriggerFailure}
+	}
+	for _, trigger := range on {
+		switch {
+		case trigger == TriggerChange && run.Changed,
+			trigger == TriggerSuccess && run.Status == StatusSuccess,
+			trigger == TriggerFailure && run.Status == StatusFailure:
+			return true
+		}
+	}
+	return false
+}
+
+// url returns the URL of a webhook or Slack channel
+func (c Channel) url() string {
+	if c.URLEnv != "" {
+		return os.Getenv(c.URLEnv)
+	}
+	return c.URL
+}
+
+// password returns the SMTP password
+func (c Channel) password() string {
+	if c.PasswordEnv != "" {
+		return os.Getenv(c.PasswordEnv)
+	}
+	return c.Password
+}
+
+// Status is the outcome of a run
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailure Status = "failure"
+)
+
+// Run describes a finished run. It is the data of the templates and the
+// default webhook body.
+type Run struct {
+	ID       string        `json:"run_id"`
+	Status   Status        `json:"status"`
+	Start    time.Time     `json:"start_time"`
+	End      time.Time     `json:"end_time"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	Tasks    []TaskRun     `json:"tasks"`
+	// Host is the machine the run ran on
+	Host string `json:"host"`
+
+	// PreviousStatus is the status of the previous run of the same tasks,
+	// empty if there was none; Changed is set if the status differs
+	PreviousStatus Status `json:"previous_status,omitempty"`
+	Changed        bool   `json:"changed"`
+}
+
+// TaskRun describes a task of a finished run
+type TaskRun struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Failed returns the tasks of the run that did not succeed
+func (r *Run) Failed() []TaskRun {
+	var failed []TaskRun
+	for _, t := range r.Tasks {
+		if t.Status != "completed" {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// Notifier sends notifications about finished runs to channels
+type Notifier struct {
+	channels  []Channel
+	statePath string
+	logger    *slog.Logger
+	client    *http.Client
+
+	mu sync.Mutex
+}
+
+// New creates a notifier for channels, recording the last status of runs
+// in the state file at statePath
+func New(channels []Channel, statePath string, logger *slog.Logger) *Notifier {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Notifier{
+		channels:  channels,
+		statePath: statePath,
+		logger:    logger,
+		client:    &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Notify records the status of run under key, which identifies runs of the
+// same tasks, and notifies the channels that want to know about it. It
+// returns the errors of the channels that failed.
+func (n *Notifier) Notify(ctx context.Context, key string, run *Run) error {
+	if run.Host == "" {
+		run.Host, _ = os.Hostname()
+	}
+	previous, err := n.swapStatus(key, run.Status)
+	if err != nil {
+		n.logger.Warn("failed to record run status", "path", n.statePath, "error", err)
+	}
+	run.PreviousStatus = previous
+	run.Changed = previous != "" && previous != run.Status
+
+	var errs []error
+	for i, channel := range n.channels {
+		if !channel.notifies(run) {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := n.send(sendCtx, channel, run)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notify[%d] %s: %w", i, channel.Type, err))
+			continue
+		}
+		n.logger.Debug("sent notification", "type", channel.Type, "run_id", run.ID, "status", run.Status)
+	}
+	return errors.Join(errs...)
+}
+
+// send delivers the notification of run to channel
+func (n *Notifier) send(ctx context.Context, channel Channel, run *Run) error {
+	switch channel.Type {
+	case ChannelWebhook:
+		return n.sendWebhook(ctx, channel, run)
+	case ChannelSlack:
+		return n.sendSlack(ctx, channel, run)
+	case ChannelEmail:
+		return sendEmail(ctx, channel, run)
+	case ChannelDesktop:
+		return sendDesktop(ctx, channel, run)
+	}
+	return fmt.Errorf("invalid notify type %q", channel.Type)
+}
+
+// swapStatus records status under key in the state file, returning the
+// status recorded before
+func (n *Notifier) swapStatus(key string, status Status) (Status, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.statePath == "" {
+		return "", nil
+	}
+
+	state := make(map[string]Status)
+	data, err := os.ReadFile(n.statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return "", fmt.Errorf("invalid notify state: %w", err)
+		}
+	}
+	previous := state[key]
+	state[key] = status
+
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return previous, err
+	}
+	if err := os.MkdirAll(filepath.Dir(n.statePath), 0750); err != nil {
+		return previous, err
+	}
+	return previous, os.WriteFile(n.statePath, data, 0600)
+}
+
+// templateFuncs are available in templates: json encodes a value as JSON,
+// e.g. {"text": {{json .Error}}}
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// render renders the template text with run, or returns fallback if text
+// is empty
+func render(name, text, fallback string, run *Run) (string, error) {
+	if text == "" {
+		return fallback, nil
+	}
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, run); err != nil {
+		return "", fmt.Errorf("failed to render notify %s: %w", name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,255 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failedRun() *Run {
+	return &Run{
+		ID:       "run-1",
+		Status:   StatusFailure,
+		Duration: 1500 * time.Millisecond,
+		Host:     "ci-1",
+		Tasks: []TaskRun{
+			{ID: "build", Name: "Build", Status: "completed"},
+			{ID: "test", Name: "Test", Status: "failed", ExitCode: 1, Error: `exit status 1 "quoted"`},
+		},
+	}
+}
+
+func TestChannel_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel Channel
+		wantErr string
+	}{
+		{name: "webhook", channel: Channel{Type: ChannelWebhook, URL: "http://localhost/hook", On: []Trigger{TriggerChange}}},
+		{name: "slack url_env", channel: Channel{Type: ChannelSlack, URLEnv: "SLACK_URL"}},
+		{name: "desktop", channel: Channel{Type: ChannelDesktop}},
+		{name: "type", channel: Channel{Type: "pager"}, wantErr: `invalid notify type "pager"`},
+		{name: "trigger", channel: Channel{Type: ChannelDesktop, On: []Trigger{"always"}}, wantErr: `invalid notify trigger "always"`},
+		{name: "no url", channel: Channel{Type: ChannelWebhook}, wantErr: "webhook notification needs a url or url_env"},
+		{name: "email", channel: Channel{Type: ChannelEmail, SMTP: "localhost:25", From: "ci@example.com"}, wantErr: "needs smtp, from and to"},
+		{name: "template", channel: Channel{Type: ChannelDesktop, Text: "{{.Status"}, wantErr: "invalid notify text template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.channel.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// receiver records the requests sent to a local HTTP stand-in
+type receiver struct {
+	server *httptest.Server
+	bodies chan []byte
+	header chan http.Header
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{bodies: make(chan []byte, 10), header: make(chan http.Header, 10)}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.bodies <- body
+		r.header <- req.Header
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func TestNotifier_Webhook(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	t.Setenv("NOTIFY_TOKEN", "s3cret")
+	notifier := New([]Channel{
+		{Type: ChannelWebhook, URL: r.server.URL, Headers: map[string]string{"Authorization": "Bearer ${NOTIFY_TOKEN}"}},
+		{Type: ChannelWebhook, URL: r.server.URL, Body: `{"summary": {{json (printf "%s: %d failed" .ID (len .Failed))}}, "error": {{json (index .Failed 0).Error}}}`},
+	}, "", nil)
+
+	require.NoError(t, notifier.Notify(context.Background(), "build,test", failedRun()))
+
+	var run Run
+	require.NoError(t, json.Unmarshal(<-r.bodies, &run))
+	assert.Equal(t, "run-1", run.ID)
+	assert.Equal(t, StatusFailure, run.Status)
+	assert.Len(t, run.Tasks, 2)
+	assert.Equal(t, "Bearer s3cret", (<-r.header).Get("Authorization"))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(<-r.bodies, &body))
+	assert.Equal(t, map[string]string{"summary": "run-1: 1 failed", "error": `exit status 1 "quoted"`}, body)
+	assert.Equal(t, "application/json", (<-r.header).Get("Content-Type"))
+}
+
+func TestNotifier_WebhookErrors(t *testing.T) {
+	r := newReceiver(t, http.StatusInternalServerError)
+	notifier := New([]Channel{
+		{Type: ChannelWebhook, URL: r.server.URL, Body: `{"status": {{.Status}}}`},
+		{Type: ChannelWebhook, URL: r.server.URL},
+		{Type: ChannelSlack, URLEnv: "NOTIFY_UNSET_URL"},
+	}, "", nil)
+
+	err := notifier.Notify(context.Background(), "build", failedRun())
+	assert.ErrorContains(t, err, "notify[0] webhook: rendered body is not valid JSON")
+	assert.ErrorContains(t, err, "notify[1] webhook: unexpected response status: 500")
+	assert.ErrorContains(t, err, "notify[2] slack: no url: NOTIFY_UNSET_URL is not set")
+}
+
+func TestNotifier_Slack(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	notifier := New([]Channel{
+		{Type: ChannelSlack, URL: r.server.URL},
+		{Type: ChannelSlack, URL: r.server.URL, Text: "{{.ID}} is {{.Status}}"},
+	}, "", nil)
+
+	require.NoError(t, notifier.Notify(context.Background(), "build", failedRun()))
+
+	var payload map[string]string
+	require.NoError(t, json.Unmarshal(<-r.bodies, &payload))
+	assert.Contains(t, payload["text"], "❌ Run run-1 failed on ci-1 after 1.5s")
+	assert.Contains(t, payload["text"], "1 of 2 tasks did not succeed:\n• test: exit status 1")
+	require.NoError(t, json.Unmarshal(<-r.bodies, &payload))
+	assert.Equal(t, map[string]string{"text": "run-1 is failure"}, payload)
+}
+
+func TestNotifier_Triggers(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	notifier := New([]Channel{
+		{Type: ChannelSlack, URL: r.server.URL, Text: "failure {{.ID}}"},
+		{Type: ChannelSlack, URL: r.server.URL, Text: "change {{.ID}} from {{.PreviousStatus}}", On: []Trigger{TriggerChange}},
+		{Type: ChannelSlack, URL: r.server.URL, Text: "success {{.ID}}", On: []Trigger{TriggerSuccess}},
+	}, filepath.Join(t.TempDir(), "notify.json"), nil)
+
+	sent := func(runs ...*Run) []string {
+		var texts []string
+		for _, run := range runs {
+			require.NoError(t, notifier.Notify(context.Background(), "build", run))
+		}
+		for len(r.bodies) > 0 {
+			<-r.header
+			var payload map[string]string
+			require.NoError(t, json.Unmarshal(<-r.bodies, &payload))
+			texts = append(texts, payload["text"])
+		}
+		return texts
+	}
+
+	assert.Equal(t, []string{"failure a"}, sent(&Run{ID: "a", Status: StatusFailure}))
+	assert.Equal(t, []string{"failure b"}, sent(&Run{ID: "b", Status: StatusFailure}))
+	assert.Equal(t, []string{"change c from failure", "success c"}, sent(&Run{ID: "c", Status: StatusSuccess}))
+	assert.Equal(t, []string{"success d"}, sent(&Run{ID: "d", Status: StatusSuccess}))
+
+	// Runs of other tasks have their own status
+	require.NoError(t, notifier.Notify(context.Background(), "test", &Run{ID: "e", Status: StatusFailure}))
+	assert.Equal(t, []string{"failure e"}, sent())
+}
+
+// startSMTP starts a local SMTP stand-in accepting one message, which it
+// sends on the returned channel together with the AUTH PLAIN credentials
+func startSMTP(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 2)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch verb {
+			case "EHLO":
+				_ = tp.PrintfLine("250-localhost")
+				_ = tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				credentials, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+				received <- strings.ReplaceAll(string(credentials), "\x00", " ")
+				_ = tp.PrintfLine("235 OK")
+			case "MAIL", "RCPT":
+				_ = tp.PrintfLine("250 OK")
+			case "DATA":
+				_ = tp.PrintfLine("354 Go ahead")
+				lines, _ := tp.ReadDotLines()
+				received <- strings.Join(lines, "\n")
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 Bye")
+				return
+			default:
+				_ = tp.PrintfLine("502 Unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestNotifier_Email(t *testing.T) {
+	addr, received := startSMTP(t)
+	t.Setenv("SMTP_PASSWORD", "hunter2")
+	notifier := New([]Channel{{
+		Type:        ChannelEmail,
+		SMTP:        addr,
+		From:        "ci@example.com",
+		To:          []string{"dev@example.com", "ops@example.com"},
+		Username:    "ci",
+		PasswordEnv: "SMTP_PASSWORD",
+	}}, "", nil)
+
+	require.NoError(t, notifier.Notify(context.Background(), "build", failedRun()))
+
+	assert.Equal(t, " ci hunter2", <-received)
+	message := <-received
+	assert.Contains(t, message, "From: ci@example.com")
+	assert.Contains(t, message, "To: dev@example.com, ops@example.com")
+	assert.Contains(t, message, "Subject: =?utf-8?q?")
+	assert.Contains(t, message, "• test: exit status 1")
+}
+
+func TestNotifier_Desktop(t *testing.T) {
+	var args []string
+	original := desktopCommand
+	desktopCommand = func(ctx context.Context, title, text string) *exec.Cmd {
+		args = []string{title, text}
+		return exec.CommandContext(ctx, "go", "version")
+	}
+	t.Cleanup(func() { desktopCommand = original })
+
+	notifier := New([]Channel{{Type: ChannelDesktop, Subject: "{{.Status}} on {{.Host}}", On: []Trigger{TriggerFailure}}}, "", nil)
+	require.NoError(t, notifier.Notify(context.Background(), "build", failedRun()))
+	require.Len(t, args, 2)
+	assert.Equal(t, "failure on ci-1", args[0])
+	assert.Contains(t, args[1], "Run run-1 failed")
+}
+
+func TestAppleScriptString(t *testing.T) {
+	assert.Equal(t, `"say \"hi\" \\ bye"`, appleScriptString(`say "hi" \ bye`))
+}
@@ -0,0 +1,120 @@
+// Package secrets resolves secret references such as secret://env/NAME in
+// task configuration through pluggable providers.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Scheme prefixes every secret reference: secret://<provider>/<ref>
+const Scheme = "secret://"
+
+// Provider looks up secrets in one store
+type Provider interface {
+	// Get returns the secret identified by ref
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, ref string) (string, error)
+
+// Get calls f(ctx, ref)
+func (f ProviderFunc) Get(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// IsRef reports whether value is a secret reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+// ParseRef splits a secret reference into provider name and reference
+func ParseRef(value string) (provider, ref string, err error) {
+	if !IsRef(value) {
+		return "", "", fmt.Errorf("not a secret reference: %q", value)
+	}
+	provider, ref, ok := strings.Cut(strings.TrimPrefix(value, Scheme), "/")
+	if !ok || provider == "" || ref == "" {
+		return "", "", fmt.Errorf("invalid secret reference %q, expected %s<provider>/<name>", value, Scheme)
+	}
+	return provider, ref, nil
+}
+
+// Resolver resolves secret references through registered providers
+type Resolver struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewResolver creates a resolver with the env and file providers registered
+func NewResolver() *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("env", ProviderFunc(getEnv))
+	r.Register("file", ProviderFunc(getFile))
+	return r
+}
+
+// Register makes a provider available as secret://<name>/...
+func (r *Resolver) Register(name string, provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[name] = provider
+}
+
+// Providers returns the names of the registered providers
+func (r *Resolver) Providers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the secret a reference points to
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	name, ref, err := ParseRef(value)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.RLock()
+	provider, ok := r.providers[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", name)
+	}
+
+	secret, err := provider.Get(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+	}
+	return secret, nil
+}
+
+// getEnv reads a secret from an environment variable of this process
+func getEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// getFile reads a secret from a file, without its trailing newline.
+// Relative paths are relative to the working directory, absolute paths
+// start with a second slash: secret://file//run/secrets/token.
+func getFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
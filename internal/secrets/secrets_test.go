@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		value    string
+		provider string
+		ref      string
+		wantErr  bool
+	}{
+		{"secret://env/TOKEN", "env", "TOKEN", false},
+		{"secret://file//run/secrets/token", "file", "/run/secrets/token", false},
+		{"secret://vault/db/password", "vault", "db/password", false},
+		{"secret://env/", "", "", true},
+		{"secret://env", "", "", true},
+		{"env/TOKEN", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			provider, ref, err := ParseRef(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.provider, provider)
+			assert.Equal(t, tt.ref, ref)
+		})
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	t.Setenv("SECRETS_TEST_TOKEN", "from-env")
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	r := NewResolver()
+	r.Register("static", ProviderFunc(func(_ context.Context, ref string) (string, error) {
+		if ref == "missing" {
+			return "", errors.New("no such secret")
+		}
+		return "static-" + ref, nil
+	}))
+	assert.Equal(t, []string{"env", "file", "static"}, r.Providers())
+
+	value, err := r.Resolve(context.Background(), "secret://env/SECRETS_TEST_TOKEN")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	value, err = r.Resolve(context.Background(), "secret://file/"+path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", value)
+
+	value, err = r.Resolve(context.Background(), "secret://static/key")
+	require.NoError(t, err)
+	assert.Equal(t, "static-key", value)
+
+	_, err = r.Resolve(context.Background(), "secret://env/SECRETS_TEST_UNSET")
+	assert.ErrorContains(t, err, "not set")
+	_, err = r.Resolve(context.Background(), "secret://static/missing")
+	assert.ErrorContains(t, err, "no such secret")
+	_, err = r.Resolve(context.Background(), "secret://nope/x")
+	assert.ErrorContains(t, err, "unknown secret provider")
+}
+
+func TestVault_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "secrets.vault")
+
+	vault, err := OpenVault(path, "passphrase-1")
+	require.NoError(t, err)
+	assert.Empty(t, vault.Names())
+	vault.Set("B_TOKEN", "b-value")
+	vault.Set("A_TOKEN", "a-value")
+	require.NoError(t, vault.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "a-value")
+
+	vault, err = OpenVault(path, "passphrase-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A_TOKEN", "B_TOKEN"}, vault.Names())
+	value, err := vault.Get(context.Background(), "A_TOKEN")
+	require.NoError(t, err)
+	assert.Equal(t, "a-value", value)
+
+	assert.True(t, vault.Delete("A_TOKEN"))
+	assert.False(t, vault.Delete("A_TOKEN"))
+	require.NoError(t, vault.Save())
+	vault, err = OpenVault(path, "passphrase-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"B_TOKEN"}, vault.Names())
+
+	_, err = OpenVault(path, "passphrase-2")
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+	_, err = OpenVault(path, "short")
+	assert.Error(t, err)
+}
+
+func TestVaultProvider_OpensOnFirstUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	vault, err := OpenVault(path, "passphrase-1")
+	require.NoError(t, err)
+	vault.Set("TOKEN", "vaulted")
+	require.NoError(t, vault.Save())
+
+	calls := 0
+	provider := NewVaultProvider(path, func() (string, error) {
+		calls++
+		return "passphrase-1", nil
+	})
+	assert.Equal(t, 0, calls)
+
+	r := NewResolver()
+	r.Register("vault", provider)
+	for i := 0; i < 2; i++ {
+		value, err := r.Resolve(context.Background(), "secret://vault/TOKEN")
+		require.NoError(t, err)
+		assert.Equal(t, "vaulted", value)
+	}
+	assert.Equal(t, 1, calls)
+}
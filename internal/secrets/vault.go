@@ -0,0 +1,239 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv holds the vault passphrase for non-interactive use
+const PassphraseEnv = "TASK_VAULT_PASSPHRASE"
+
+// vaultVersion is the version of the vault file format
+const vaultVersion = 1
+
+// scrypt parameters for deriving the vault key from the passphrase
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	keyLength     = 32
+	saltLength    = 16
+	minPassLength = 8
+)
+
+// ErrWrongPassphrase is returned when the vault cannot be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted vault")
+
+// vaultFile is the on-disk format of a vault. The secrets are stored as
+// one AES-256-GCM encrypted JSON object.
+type vaultFile struct {
+	Version    int    `json:"version"`
+	KDF        kdf    `json:"kdf"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type kdf struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// Vault is a local file of secrets encrypted with a passphrase
+type Vault struct {
+	path       string
+	passphrase string
+
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// OpenVault decrypts the vault at path. A missing file is an empty vault,
+// created on the first Save.
+func OpenVault(path, passphrase string) (*Vault, error) {
+	if len(passphrase) < minPassLength {
+		return nil, fmt.Errorf("vault passphrase must be at least %d characters", minPassLength)
+	}
+	v := &Vault{path: path, passphrase: passphrase, secrets: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return v, nil
+		}
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse vault: %w", err)
+	}
+	if file.Version != vaultVersion || file.KDF.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault format %d/%s", file.Version, file.KDF.Name)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), file.KDF.Salt, file.KDF.N, file.KDF.R, file.KDF.P, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, &v.secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse vault contents: %w", err)
+	}
+	return v, nil
+}
+
+// Get returns a secret by name
+func (v *Vault) Get(_ context.Context, name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, ok := v.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found in vault", name)
+	}
+	return value, nil
+}
+
+// Set stores a secret; call Save to persist it
+func (v *Vault) Set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets[name] = value
+}
+
+// Delete removes a secret and reports whether it existed; call Save to
+// persist the change
+func (v *Vault) Delete(name string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.secrets[name]
+	delete(v.secrets, name)
+	return ok
+}
+
+// Names returns the names of all secrets, sorted
+func (v *Vault) Names() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the secrets with a fresh salt and nonce and writes the vault
+func (v *Vault) Save() error {
+	v.mu.Lock()
+	plaintext, err := json.Marshal(v.secrets)
+	v.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode vault: %w", err)
+	}
+
+	file := vaultFile{
+		Version: vaultVersion,
+		KDF:     kdf{Name: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, saltLength)},
+	}
+	if _, err := rand.Read(file.KDF.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := scrypt.Key([]byte(v.passphrase), file.KDF.Salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Ciphertext = gcm.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vault: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0750); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	// Write a temporary file first so a failed write keeps the old vault
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := os.Rename(tmp, v.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return gcm, nil
+}
+
+// VaultProvider opens a vault on first use, so the passphrase is only
+// needed when a task actually references the vault
+type VaultProvider struct {
+	path       string
+	passphrase func() (string, error)
+
+	once  sync.Once
+	vault *Vault
+	err   error
+}
+
+// NewVaultProvider creates a provider for the vault at path. passphrase is
+// called once, when the first secret is looked up.
+func NewVaultProvider(path string, passphrase func() (string, error)) *VaultProvider {
+	return &VaultProvider{path: path, passphrase: passphrase}
+}
+
+// Get returns a secret from the vault
+func (p *VaultProvider) Get(ctx context.Context, name string) (string, error) {
+	p.once.Do(func() {
+		var passphrase string
+		passphrase, p.err = p.passphrase()
+		if p.err != nil {
+			return
+		}
+		p.vault, p.err = OpenVault(p.path, passphrase)
+	})
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.vault.Get(ctx, name)
+}
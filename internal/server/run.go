@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+// RunStatus is the state of a run triggered through the API
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunCompleted RunStatus = "completed"
+	RunFailed    RunStatus = "failed"
+	RunCanceled  RunStatus = "canceled"
+)
+
+// RunRequest is the body of a request to start a run
+type RunRequest struct {
+	TaskID string            `json:"task_id,omitempty"`
+	Vars   map[string]string `json:"vars,omitempty"`
+}
+
+// RunInfo is the JSON representation of a run
+type RunInfo struct {
+	ID        string            `json:"id"`
+	TaskID    string            `json:"task_id,omitempty"`
+	Vars      map[string]string `json:"vars,omitempty"`
+	Status    RunStatus         `json:"status"`
+	StartTime time.Time         `json:"start_time"`
+	EndTime   *time.Time        `json:"end_time,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Results   []ResultInfo      `json:"results"`
+}
+
+// ResultInfo is the JSON representation of a task result
+type ResultInfo struct {
+	TaskID     string `json:"task_id"`
+	Success    bool   `json:"success"`
+	DurationMS int64  `json:"duration_ms"`
+	ExitCode   int    `json:"exit_code"`
+	Error      string `json:"error,omitempty"`
+	Output     string `json:"output,omitempty"`
+}
+
+// Run is a single execution of tasks started through the API. It collects
+// the log lines written by its executor so they can be streamed to clients.
+type Run struct {
+	id        string
+	request   RunRequest
+	executor  *task.Executor
+	cancel    context.CancelFunc
+	startTime time.Time
+	history   *task.History
+	trigger   string
+	// lockPath is the run lock held while the tasks run, if any
+	lockPath string
+	// secrets names the run variables the tasks declare as secret
+	secrets map[string]bool
+
+	mu       sync.Mutex
+	status   RunStatus
+	endTime  time.Time
+	err      error
+	lines    []string
+	changed  chan struct{}
+	finished bool
+	done     chan struct{}
+}
+
+func newRun(id string, request RunRequest, executor *task.Executor) *Run {
+	return &Run{
+		id:        id,
+		request:   request,
+		executor:  executor,
+		startTime: time.Now(),
+		status:    RunRunning,
+		changed:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// execute runs the requested tasks and records the outcome
+func (r *Run) execute(ctx context.Context) {
+	ctx = task.WithVars(ctx, r.request.Vars)
+
+	// Runs take turns with the other runs of the workspace
+	if r.lockPath != "" {
+		lock, err := task.AcquireLock(ctx, r.lockPath, fmt.Sprintf("%s run %s", r.trigger, r.id), true)
+		if err != nil {
+			status := RunFailed
+			if ctx.Err() != nil {
+				status = RunCanceled
+			}
+			r.finish(status, fmt.Errorf("failed to acquire run lock: %w", err))
+			return
+		}
+		defer func() {
+			if err := lock.Release(); err != nil {
+				_, _ = r.Write([]byte("failed to release run lock: " + err.Error() + "\n"))
+			}
+		}()
+	}
+
+	var err error
+	if r.request.TaskID != "" {
+		_, err = r.executor.ExecuteTask(ctx, r.request.TaskID)
+	} else {
+		err = r.executor.ExecuteAll(ctx)
+	}
+
+	status := RunCompleted
+	switch {
+	case ctx.Err() != nil:
+		status = RunCanceled
+	case err != nil:
+		status = RunFailed
+	default:
+		for _, result := range r.executor.GetResults() {
+			if !result.Success {
+				status = RunFailed
+			}
+		}
+	}
+
+	r.record()
+	r.finish(status, err)
+}
+
+// finish records the outcome of the run and wakes up everyone waiting
+func (r *Run) finish(status RunStatus, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+	r.err = err
+	r.endTime = time.Now()
+	r.finished = true
+	r.notify()
+	close(r.done)
+}
+
+// record appends the task results to history, if enabled
+func (r *Run) record() {
+	if r.history == nil {
+		return
+	}
+	for _, result := range r.executor.GetResults() {
+		if err := r.history.Append(task.NewHistoryRecord(r.id, r.trigger, result)); err != nil {
+			_, _ = r.Write([]byte("failed to record history: " + err.Error() + "\n"))
+			return
+		}
+	}
+}
+
+// Write appends log lines written by the executor
+func (r *Run) Write(p []byte) (int, error) {
+	text := strings.TrimSuffix(string(p), "\n")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, strings.Split(text, "\n")...)
+	r.notify()
+	return len(p), nil
+}
+
+// notify wakes up log followers; r.mu must be held
+func (r *Run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// linesSince returns the log lines from index on, a channel closed when more
+// lines arrive, and whether the run has finished with no lines left to read.
+func (r *Run) linesSince(index int) ([]string, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lines []string
+	if index < len(r.lines) {
+		lines = append(lines, r.lines[index:]...)
+	}
+	return lines, r.changed, r.finished
+}
+
+// Cancel stops the run
+func (r *Run) Cancel() {
+	r.cancel()
+}
+
+// Wait blocks until the run is over
+func (r *Run) Wait() {
+	<-r.done
+}
+
+// Finished reports whether the run is over
+func (r *Run) Finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finished
+}
+
+// maskedVars returns the run variables with secret values masked
+func (r *Run) maskedVars() map[string]string {
+	if r.request.Vars == nil {
+		return nil
+	}
+	vars := make(map[string]string, len(r.request.Vars))
+	for name, value := range r.request.Vars {
+		if r.secrets[name] {
+			value = task.MaskedValue
+		}
+		vars[name] = r.executor.Masker().Mask(value)
+	}
+	return vars
+}
+
+// Info returns a snapshot of the run
+func (r *Run) Info() RunInfo {
+	r.mu.Lock()
+	info := RunInfo{
+		ID:        r.id,
+		TaskID:    r.request.TaskID,
+		Vars:      r.maskedVars(),
+		Status:    r.status,
+		StartTime: r.startTime,
+		Results:   []ResultInfo{},
+	}
+	if r.finished {
+		end := r.endTime
+		info.EndTime = &end
+	}
+	if r.err != nil {
+		info.Error = r.err.Error()
+	}
+	r.mu.Unlock()
+
+	for id, result := range r.executor.GetResults() {
+		ri := ResultInfo{
+			TaskID:     id,
+			Success:    result.Success,
+			DurationMS: result.Duration.Milliseconds(),
+			ExitCode:   result.ExitCode,
+			Output:     result.Output,
+		}
+		if result.Error != nil {
+			ri.Error = result.Error.Error()
+		}
+		info.Results = append(info.Results, ri)
+	}
+	sort.Slice(info.Results, func(i, j int) bool {
+		return info.Results[i].TaskID < info.Results[j].TaskID
+	})
+	return info
+}
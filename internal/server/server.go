@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+// maxFinishedRuns bounds how many finished runs are kept for polling
+const maxFinishedRuns = 100
+
+// Server exposes task execution over an HTTP/JSON API
+type Server struct {
+	config  *task.Config
+	token   string
+	history *task.History
+
+	subscribers []task.Subscriber
+	secrets     task.SecretResolver
+	policy      *task.Policy
+	lockPath    string
+
+	mu    sync.RWMutex
+	runs  map[string]*Run
+	order []string
+}
+
+// New creates a server for the tasks in config. Requests must carry the
+// token as a bearer token; an empty token disables authentication.
+func New(config *task.Config, token string) *Server {
+	return &Server{
+		config: config,
+		token:  token,
+		runs:   make(map[string]*Run),
+	}
+}
+
+// SetHistory records the task results of every run in history
+func (s *Server) SetHistory(history *task.History) {
+	s.history = history
+}
+
+// Subscribe delivers the events of every run started from now on to sub
+func (s *Server) Subscribe(sub task.Subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, sub)
+}
+
+// SetSecretResolver sets how secret:// references in task env values are
+// resolved
+func (s *Server) SetSecretResolver(resolver task.SecretResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets = resolver
+}
+
+// SetPolicy makes every run check its tasks against policy before running
+// them
+func (s *Server) SetPolicy(policy *task.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// SetLockPath makes every run hold the run lock at path while its tasks
+// run, waiting for it if another run of the workspace holds it
+func (s *Server) SetLockPath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockPath = path
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tasks", s.handleTasks)
+	mux.HandleFunc("/api/runs", s.handleRuns)
+	mux.HandleFunc("/api/runs/", s.handleRun)
+	return s.authenticate(mux)
+}
+
+// StartRun triggers a run of all tasks, or of a single task by ID
+func (s *Server) StartRun(request RunRequest) (*Run, error) {
+	return s.startRun(request, task.TriggerAPI)
+}
+
+// startRun starts a run recorded in history with the given trigger
+func (s *Server) startRun(request RunRequest, trigger string) (*Run, error) {
+	if request.TaskID != "" && !s.hasTask(request.TaskID) {
+		return nil, fmt.Errorf("task %s not found", request.TaskID)
+	}
+
+	// Every run gets its own copies so runs never share task state
+	executor := task.NewExecutor(1, false)
+	for _, t := range s.config.Tasks {
+		if err := executor.AddTask(t.Clone()); err != nil {
+			return nil, err
+		}
+	}
+
+	executor.SetHooks(s.config.Hooks)
+
+	run := newRun(task.NewRunID(), request, executor)
+	run.history = s.history
+	run.trigger = trigger
+	run.secrets = make(map[string]bool)
+	for _, t := range s.config.Tasks {
+		for _, name := range t.Secrets {
+			run.secrets[name] = true
+		}
+	}
+	executor.SetRunID(run.id)
+	executor.Subscribe(task.NewConsoleSink(run, true))
+	s.mu.RLock()
+	for _, sub := range s.subscribers {
+		executor.Subscribe(sub)
+	}
+	if s.secrets != nil {
+		executor.SetSecretResolver(s.secrets)
+	}
+	executor.SetPolicy(s.policy)
+	run.lockPath = s.lockPath
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run.cancel = cancel
+
+	s.mu.Lock()
+	s.runs[run.id] = run
+	s.order = append(s.order, run.id)
+	s.pruneRuns()
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		run.execute(ctx)
+	}()
+	return run, nil
+}
+
+// Run returns a run by ID
+func (s *Server) Run(id string) (*Run, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.runs[id]
+	return run, ok
+}
+
+// Close cancels all runs still in progress
+func (s *Server) Close() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, run := range s.runs {
+		run.Cancel()
+	}
+}
+
+// pruneRuns drops the oldest finished runs beyond the limit; s.mu must be held
+func (s *Server) pruneRuns() {
+	finished := 0
+	for _, id := range s.order {
+		if s.runs[id].Finished() {
+			finished++
+		}
+	}
+
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if finished > maxFinishedRuns && s.runs[id].Finished() {
+			delete(s.runs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+func (s *Server) hasTask(id string) bool {
+	for _, t := range s.config.Tasks {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate rejects requests without the configured bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleTasks lists the configured tasks
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	tasks := make([]*task.Task, 0, len(s.config.Tasks))
+	for _, t := range s.config.Tasks {
+		tasks = append(tasks, t.Redacted())
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+// handleRuns lists runs or starts a new one
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.RLock()
+		infos := make([]RunInfo, 0, len(s.order))
+		for _, id := range s.order {
+			infos = append(infos, s.runs[id].Info())
+		}
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, infos)
+
+	case http.MethodPost:
+		var request RunRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+				return
+			}
+		}
+		run, err := s.StartRun(request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, run.Info())
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleRun serves /api/runs/{id}, /api/runs/{id}/logs and /api/runs/{id}/cancel
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/runs/"), "/")
+	run, ok := s.Run(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "run "+id+" not found")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, run.Info())
+	case action == "logs" && r.Method == http.MethodGet:
+		s.streamLogs(w, r, run)
+	case action == "cancel" && r.Method == http.MethodPost:
+		run.Cancel()
+		writeJSON(w, http.StatusAccepted, run.Info())
+	case action == "" || action == "logs" || action == "cancel":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// streamLogs sends the run's log as Server-Sent Events, one event per line,
+// followed by an "end" event carrying the final status.
+func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request, run *Run) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	next := 0
+	for {
+		lines, changed, finished := run.linesSince(next)
+		for _, line := range lines {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+		next += len(lines)
+
+		if finished {
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", run.Info().Status)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+const testToken = "s3cret"
+
+func newTestServer(t *testing.T, tasks ...*task.Task) *httptest.Server {
+	t.Helper()
+	api := New(&task.Config{Version: "1.0", Tasks: tasks}, testToken)
+	ts := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		api.Close()
+		ts.Close()
+	})
+	return ts
+}
+
+func doRequest(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func waitForRun(t *testing.T, baseURL, id string) RunInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		resp := doRequest(t, http.MethodGet, baseURL+"/api/runs/"+id, "")
+		var info RunInfo
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		resp.Body.Close()
+		if info.Status != RunRunning {
+			return info
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("run %s did not finish", id)
+	return RunInfo{}
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/tasks")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodGet, ts.URL+"/api/tasks", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_ListTasksRedactsSecrets(t *testing.T) {
+	ts := newTestServer(t, &task.Task{
+		ID: "deploy", Name: "Deploy", Type: task.TaskTypeCommand, Command: "go version",
+		Env: map[string]string{"API_TOKEN": "tok-12345"}, Secrets: []string{"API_TOKEN"},
+	})
+
+	resp := doRequest(t, http.MethodGet, ts.URL+"/api/tasks", "")
+	defer resp.Body.Close()
+	var tasks []task.Task
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tasks))
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task.MaskedValue, tasks[0].Env["API_TOKEN"])
+}
+
+func TestServer_RunInfoMasksSecretVars(t *testing.T) {
+	ts := newTestServer(t, &task.Task{
+		ID: "deploy", Name: "Deploy", Type: task.TaskTypeCommand, Command: "go version",
+		Secrets: []string{"API_TOKEN"},
+	})
+
+	resp := doRequest(t, http.MethodPost, ts.URL+"/api/runs", `{"task_id":"deploy","vars":{"API_TOKEN":"tok-12345","REF":"main"}}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var started RunInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&started))
+	resp.Body.Close()
+	assert.Equal(t, map[string]string{"API_TOKEN": task.MaskedValue, "REF": "main"}, started.Vars)
+
+	info := waitForRun(t, ts.URL, started.ID)
+	assert.Equal(t, task.MaskedValue, info.Vars["API_TOKEN"])
+
+	resp = doRequest(t, http.MethodGet, ts.URL+"/api/runs", "")
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "tok-12345")
+}
+
+func TestServer_RunTaskWithVars(t *testing.T) {
+	ts := newTestServer(t,
+		&task.Task{ID: "goos", Name: "GOOS", Type: task.TaskTypeCommand, Command: "go", Args: []string{"env", "${VAR_NAME}"}},
+		&task.Task{ID: "other", Name: "Other", Type: task.TaskTypeCommand, Command: "go version"},
+	)
+
+	resp := doRequest(t, http.MethodPost, ts.URL+"/api/runs", `{"task_id":"goos","vars":{"VAR_NAME":"GOOS"}}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var started RunInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&started))
+	resp.Body.Close()
+
+	info := waitForRun(t, ts.URL, started.ID)
+	assert.Equal(t, RunCompleted, info.Status)
+	require.Len(t, info.Results, 1)
+	assert.Equal(t, "goos", info.Results[0].TaskID)
+	assert.Contains(t, info.Results[0].Output, runtime.GOOS)
+
+	// The log stream replays the whole run and ends with its status
+	resp = doRequest(t, http.MethodGet, ts.URL+"/api/runs/"+started.ID+"/logs", "")
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			events = append(events, line)
+		}
+	}
+	assert.Contains(t, events, "data: [goos] "+runtime.GOOS)
+	assert.Equal(t, "data: completed", events[len(events)-1])
+}
+
+func TestServer_Policy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("forbidden_args: ['^GOOS$']\n"), 0600))
+	policy, err := task.LoadPolicy(path)
+	require.NoError(t, err)
+
+	api := New(&task.Config{Version: "1.0", Tasks: []*task.Task{
+		{ID: "env", Name: "Env", Type: task.TaskTypeCommand, Command: "go", Args: []string{"env", "${VAR_NAME}"}},
+	}}, "")
+	defer api.Close()
+	api.SetPolicy(policy)
+
+	// Run variables are checked along with the task
+	run, err := api.StartRun(RunRequest{TaskID: "env", Vars: map[string]string{"VAR_NAME": "GOOS"}})
+	require.NoError(t, err)
+	run.Wait()
+	info := run.Info()
+	assert.Equal(t, RunFailed, info.Status)
+	require.Len(t, info.Results, 1)
+	assert.Contains(t, info.Results[0].Error, "task env denied by policy")
+
+	run, err = api.StartRun(RunRequest{TaskID: "env", Vars: map[string]string{"VAR_NAME": "GOARCH"}})
+	require.NoError(t, err)
+	run.Wait()
+	assert.Equal(t, RunCompleted, run.Info().Status)
+}
+
+func TestServer_RunLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+	api := New(&task.Config{Version: "1.0", Tasks: []*task.Task{
+		{ID: "version", Name: "Version", Type: task.TaskTypeCommand, Command: "go version"},
+	}}, "")
+	defer api.Close()
+	api.SetLockPath(path)
+
+	held, err := task.AcquireLock(context.Background(), path, "task run", false)
+	require.NoError(t, err)
+	run, err := api.StartRun(RunRequest{})
+	require.NoError(t, err)
+
+	// The run waits for the lock held by another run of the workspace
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, RunRunning, run.Info().Status)
+	assert.Empty(t, run.Info().Results)
+
+	require.NoError(t, held.Release())
+	run.Wait()
+	assert.Equal(t, RunCompleted, run.Info().Status)
+	assert.NoFileExists(t, path)
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	api := New(&task.Config{Version: "1.0", Tasks: []*task.Task{
+		{ID: "version", Name: "Version", Type: task.TaskTypeCommand, Command: "go version"},
+	}}, "")
+	defer api.Close()
+
+	var mu sync.Mutex
+	var types []task.EventType
+	api.Subscribe(task.SubscriberFunc(func(event task.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		types = append(types, event.Type)
+	}))
+
+	run, err := api.StartRun(RunRequest{})
+	require.NoError(t, err)
+	run.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, types, task.EventTaskSucceeded)
+	assert.Equal(t, task.EventRunFinished, types[len(types)-1])
+}
+
+func TestServer_UnknownTask(t *testing.T) {
+	ts := newTestServer(t, &task.Task{ID: "a", Name: "A", Type: task.TaskTypeCommand, Command: "go version"})
+
+	resp := doRequest(t, http.MethodPost, ts.URL+"/api/runs", `{"task_id":"missing"}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodGet, ts.URL+"/api/runs/nope", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServer_CancelRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	ts := newTestServer(t, &task.Task{ID: "slow", Name: "Slow", Type: task.TaskTypeCommand, Command: "sleep 30"})
+
+	resp := doRequest(t, http.MethodPost, ts.URL+"/api/runs", `{}`)
+	var started RunInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&started))
+	resp.Body.Close()
+
+	resp = doRequest(t, http.MethodPost, ts.URL+"/api/runs/"+started.ID+"/cancel", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	info := waitForRun(t, ts.URL, started.ID)
+	assert.Equal(t, RunCanceled, info.Status)
+}
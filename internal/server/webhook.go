@@ -0,0 +1,217 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+// maxPayloadSize bounds the webhook request body
+const maxPayloadSize = 1 << 20
+
+// maxVarSize bounds a single WEBHOOK_* variable, well below the 128 KB
+// Linux allows for one environment string
+const maxVarSize = 32 << 10
+
+// webhookRoute is a task triggered by requests to a path
+type webhookRoute struct {
+	taskID  string
+	trigger task.Trigger
+}
+
+// WebhookResponse is returned for an accepted webhook
+type WebhookResponse struct {
+	Runs []string `json:"runs"`
+}
+
+// WebhookHandler serves the webhook triggers declared by the tasks. A
+// request to a trigger's path starts a run of every task on that path whose
+// secret it matches, with the JSON payload exposed as WEBHOOK_* variables.
+func (s *Server) WebhookHandler() (http.Handler, error) {
+	routes := make(map[string][]webhookRoute)
+	for _, t := range s.config.Tasks {
+		for _, trigger := range t.Triggers {
+			if trigger.Type != task.TriggerTypeWebhook {
+				continue
+			}
+			routes[trigger.Path] = append(routes[trigger.Path], webhookRoute{taskID: t.ID, trigger: trigger})
+		}
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no tasks declare webhook triggers")
+	}
+
+	mux := http.NewServeMux()
+	for path, pathRoutes := range routes {
+		pathRoutes := pathRoutes
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			s.handleWebhook(w, r, pathRoutes)
+		})
+	}
+	return mux, nil
+}
+
+// WebhookPaths returns the paths served by WebhookHandler
+func (s *Server) WebhookPaths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, t := range s.config.Tasks {
+		for _, trigger := range t.Triggers {
+			if trigger.Type == task.TriggerTypeWebhook && !seen[trigger.Path] {
+				seen[trigger.Path] = true
+				paths = append(paths, trigger.Path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request, routes []webhookRoute) {
+	if r.URL.Path != routes[0].trigger.Path {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if len(body) > maxPayloadSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+
+	// Tasks sharing a path may use different secrets, so each route is
+	// verified on its own
+	var verified []webhookRoute
+	for _, route := range routes {
+		if verifyWebhook(r, body, route.trigger.WebhookSecret()) {
+			verified = append(verified, route)
+		}
+	}
+	if len(verified) == 0 {
+		writeError(w, http.StatusUnauthorized, "invalid webhook signature")
+		return
+	}
+
+	vars, err := webhookVars(r, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := WebhookResponse{}
+	for _, route := range verified {
+		run, err := s.startRun(RunRequest{TaskID: route.taskID, Vars: vars}, task.TriggerWebhook)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response.Runs = append(response.Runs, run.id)
+	}
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+// verifyWebhook checks a GitHub "X-Hub-Signature-256" HMAC or a GitLab
+// "X-Gitlab-Token" against the secret. Without a secret any request passes.
+func verifyWebhook(r *http.Request, body []byte, secret string) bool {
+	if secret == "" {
+		return true
+	}
+
+	if signature := r.Header.Get("X-Hub-Signature-256"); signature != "" {
+		digest, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(digest, mac.Sum(nil))
+	}
+
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+
+	return false
+}
+
+// webhookVars exposes the request to the task: WEBHOOK_EVENT holds the
+// event name, WEBHOOK_PAYLOAD the raw body, and every scalar of a JSON
+// payload is flattened into a variable, e.g. {"repository": {"name": "x"}}
+// becomes WEBHOOK_REPOSITORY_NAME=x. Values over maxVarSize are left out,
+// as the task could not be started with them in its environment.
+func webhookVars(r *http.Request, body []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	if len(body) <= maxVarSize {
+		vars["WEBHOOK_PAYLOAD"] = string(body)
+	}
+	for _, header := range []string{"X-GitHub-Event", "X-Gitlab-Event"} {
+		if event := r.Header.Get(header); event != "" {
+			vars["WEBHOOK_EVENT"] = event
+		}
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 || !strings.Contains(r.Header.Get("Content-Type"), "json") {
+		return vars, nil
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid JSON payload: %w", err)
+	}
+	flattenPayload("WEBHOOK", payload, vars)
+	return vars, nil
+}
+
+func flattenPayload(prefix string, value interface{}, vars map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenPayload(prefix+"_"+envName(key), child, vars)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenPayload(prefix+"_"+strconv.Itoa(i), child, vars)
+		}
+	case string:
+		if len(v) <= maxVarSize {
+			vars[prefix] = v
+		}
+	case nil:
+		vars[prefix] = ""
+	default:
+		data, _ := json.Marshal(v)
+		vars[prefix] = string(data)
+	}
+}
+
+// envName turns a JSON key into an environment variable name fragment
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookVars(t *testing.T) {
+	body := `{"ref":"refs/heads/main","repository":{"full_name":"acme/app"},"commits":[{"id":"abc"}],"forced":false}`
+	req := httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+
+	vars, err := webhookVars(req, []byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, "refs/heads/main", vars["WEBHOOK_REF"])
+	assert.Equal(t, "acme/app", vars["WEBHOOK_REPOSITORY_FULL_NAME"])
+	assert.Equal(t, "abc", vars["WEBHOOK_COMMITS_0_ID"])
+	assert.Equal(t, "false", vars["WEBHOOK_FORCED"])
+	assert.Equal(t, "push", vars["WEBHOOK_EVENT"])
+	assert.Equal(t, body, vars["WEBHOOK_PAYLOAD"])
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := `{"ref":"main"}`
+	tests := []struct {
+		name    string
+		headers map[string]string
+		secret  string
+		want    bool
+	}{
+		{name: "no secret", want: true},
+		{name: "valid signature", secret: "s", headers: map[string]string{"X-Hub-Signature-256": sign("s", body)}, want: true},
+		{name: "wrong signature", secret: "s", headers: map[string]string{"X-Hub-Signature-256": sign("other", body)}},
+		{name: "valid gitlab token", secret: "s", headers: map[string]string{"X-Gitlab-Token": "s"}, want: true},
+		{name: "wrong gitlab token", secret: "s", headers: map[string]string{"X-Gitlab-Token": "x"}},
+		{name: "missing signature", secret: "s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, verifyWebhook(req, []byte(body), tt.secret))
+		})
+	}
+}
+
+func TestWebhookHandler_StartsRun(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.jsonl")
+	hooks := New(&task.Config{Version: "1.0", Tasks: []*task.Task{
+		{
+			ID:       "deploy",
+			Name:     "Deploy",
+			Type:     task.TaskTypeCommand,
+			Command:  "go",
+			Args:     []string{"env", "${WEBHOOK_VAR}"},
+			Triggers: []task.Trigger{{Type: task.TriggerTypeWebhook, Path: "/deploy", Secret: "s"}},
+		},
+	}}, "")
+	hooks.SetHistory(task.NewHistory(historyPath))
+	defer hooks.Close()
+
+	handler, err := hooks.WebhookHandler()
+	require.NoError(t, err)
+
+	body := `{"var":"GOOS"}`
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", sign("s", body))
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var response WebhookResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Runs, 1)
+
+	run, ok := hooks.Run(response.Runs[0])
+	require.True(t, ok)
+	run.Wait()
+	info := run.Info()
+	assert.Equal(t, RunCompleted, info.Status)
+	assert.Equal(t, "GOOS", info.Vars["WEBHOOK_VAR"])
+
+	records, err := task.NewHistory(historyPath).Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, task.TriggerWebhook, records[0].Trigger)
+}
+
+func TestWebhookVars_Large(t *testing.T) {
+	large := strings.Repeat("x", maxVarSize+1)
+	body := `{"ref":"main","message":"` + large + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	vars, err := webhookVars(req, []byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, "main", vars["WEBHOOK_REF"])
+	assert.NotContains(t, vars, "WEBHOOK_MESSAGE")
+	assert.NotContains(t, vars, "WEBHOOK_PAYLOAD")
+}
+
+func TestWebhookHandler_SharedPath(t *testing.T) {
+	hooks := New(&task.Config{Version: "1.0", Tasks: []*task.Task{
+		{
+			ID: "staging", Name: "Staging", Type: task.TaskTypeCommand, Command: "go version",
+			Triggers: []task.Trigger{{Type: task.TriggerTypeWebhook, Path: "/deploy", Secret: "staging"}},
+		},
+		{
+			ID: "production", Name: "Production", Type: task.TaskTypeCommand, Command: "go version",
+			Triggers: []task.Trigger{{Type: task.TriggerTypeWebhook, Path: "/deploy", Secret: "production"}},
+		},
+	}}, "")
+	defer hooks.Close()
+
+	handler, err := hooks.WebhookHandler()
+	require.NoError(t, err)
+
+	body := `{"ref":"main"}`
+	for _, secret := range []string{"staging", "production"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", sign(secret, body))
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		var response WebhookResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Runs, 1)
+		run, ok := hooks.Run(response.Runs[0])
+		require.True(t, ok)
+		run.Wait()
+		assert.Equal(t, secret, run.Info().TaskID)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/deploy", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", sign("other", body))
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -3,8 +3,10 @@ package task
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/yourusername/go-cli-tool/internal/notify"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,46 +15,196 @@ type Config struct {
 	Version  string       `yaml:"version"`
 	Tasks    []*Task      `yaml:"tasks"`
 	Defaults TaskDefaults `yaml:"defaults"`
+	// Resources declares named resource pools and their capacity; tasks
+	// hold amounts of them with uses
+	Resources map[string]int `yaml:"resources"`
+
+	// Hooks run before and after every run
+	Hooks *RunHooks `yaml:"hooks,omitempty"`
+	// Notify lists where to send notifications about finished runs
+	Notify []notify.Channel `yaml:"notify,omitempty"`
+	// Templates holds partial tasks that tasks inherit from with extends.
+	// LoadConfig resolves them into Tasks.
+	Templates map[string]*yaml.Node `yaml:"templates,omitempty"`
 }
 
-// TaskDefaults contains default values for tasks
+// TaskDefaults contains default values for tasks. A default applies to
+// every task that leaves the key out; a task setting the key, even to zero
+// or null, keeps its own value.
 type TaskDefaults struct {
-	Timeout    time.Duration `yaml:"timeout"`
-	RetryCount int           `yaml:"retry_count"`
-	WorkDir    string        `yaml:"workdir"`
+	Timeout     time.Duration `yaml:"timeout"`
+	RetryCount  int           `yaml:"retry_count"`
+	WorkDir     string        `yaml:"workdir"`
+	Shell       string        `yaml:"shell"`
+	Interpreter string        `yaml:"interpreter"`
+	Output      OutputMode    `yaml:"output"`
+	OnFailure   FailurePolicy `yaml:"on_failure"`
+	// EnvFile lists dotenv files loaded into every task's environment
+	EnvFile StringList        `yaml:"env_file"`
+	Env     map[string]string `yaml:"env"`
+	// EnvInherit, EnvRemove and PathPrepend control the environment tasks
+	// inherit from the go-cli-tool process
+	EnvInherit  EnvInherit `yaml:"env_inherit,omitempty"`
+	EnvRemove   []string   `yaml:"env_remove"`
+	PathPrepend []string   `yaml:"path_prepend"`
+	// Limits applies to every task without limits of its own
+	Limits *Limits `yaml:"limits"`
 }
 
 // LoadConfig loads task configuration from a YAML file
-func LoadConfig(filepath string) (*Config, error) {
-	data, err := os.ReadFile(filepath)
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return ParseConfig(data, path)
+}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+// ParseConfig parses data as the content of the configuration file at path
+func ParseConfig(data []byte, path string) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if err := resolveTemplates(&doc); err != nil {
+		return nil, fmt.Errorf("failed to resolve templates: %w", err)
+	}
+
+	var config Config
+	if doc.Kind != 0 {
+		if err := doc.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
 
 	// Apply defaults to tasks
 	for _, task := range config.Tasks {
-		if task.Timeout == 0 && config.Defaults.Timeout > 0 {
-			task.Timeout = config.Defaults.Timeout
-		}
-		if task.RetryCount == 0 && config.Defaults.RetryCount > 0 {
-			task.RetryCount = config.Defaults.RetryCount
-		}
-		if task.WorkDir == "" && config.Defaults.WorkDir != "" {
-			task.WorkDir = config.Defaults.WorkDir
-		}
+		config.Defaults.apply(task)
 		if task.Status == "" {
 			task.Status = StatusPending
 		}
 	}
 
+	if config.Hooks != nil {
+		base := config.Hooks.baseTask()
+		config.Defaults.apply(base)
+		config.Hooks.base = base
+	}
+
+	if err := config.expandMatrices(); err != nil {
+		return nil, err
+	}
+	if err := config.mergeEnv(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+// apply sets the defaults on a task for every key the task left out
+func (d TaskDefaults) apply(task *Task) {
+	if !task.isSet("timeout") {
+		task.Timeout = d.Timeout
+	}
+	if !task.isSet("retry_count") {
+		task.RetryCount = d.RetryCount
+	}
+	if !task.isSet("workdir") {
+		task.WorkDir = d.WorkDir
+	}
+	if !task.isSet("shell") {
+		task.Shell = d.Shell
+	}
+	if !task.isSet("interpreter") {
+		task.Interpreter = d.Interpreter
+	}
+	if !task.isSet("output") {
+		task.OutputMode = d.Output
+	}
+	if !task.isSet("on_failure") {
+		task.OnFailure = d.OnFailure
+	}
+	if !task.isSet("env_inherit") {
+		task.EnvInherit = d.EnvInherit
+	}
+	if !task.isSet("limits") && d.Limits != nil {
+		limits := *d.Limits
+		task.Limits = &limits
+	}
+	task.EnvRemove = append(append([]string(nil), d.EnvRemove...), task.EnvRemove...)
+	task.PathPrepend = append(append([]string(nil), task.PathPrepend...), d.PathPrepend...)
+}
+
+// mergeEnv builds each task's environment from the env files and env maps
+// of the defaults and the task. Later layers take precedence: defaults
+// env_file, defaults env, task env_file, task env. Env file paths are
+// relative to baseDir.
+func (c *Config) mergeEnv(baseDir string) error {
+	defaults, err := LoadEnvFiles(c.Defaults.EnvFile, baseDir, os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	for k, v := range c.Defaults.Env {
+		defaults[k] = v
+	}
+
+	for _, task := range c.envTasks() {
+		lookup := func(name string) (string, bool) {
+			if v, ok := defaults[name]; ok {
+				return v, true
+			}
+			return os.LookupEnv(name)
+		}
+		files, err := LoadEnvFiles(task.EnvFile, baseDir, lookup)
+		if err != nil {
+			return fmt.Errorf("task %s: %w", task.ID, err)
+		}
+
+		env := make(map[string]string, len(defaults)+len(files)+len(task.Env))
+		for k, v := range defaults {
+			env[k] = v
+		}
+		for k, v := range files {
+			env[k] = v
+		}
+		for k, v := range task.Env {
+			env[k] = v
+		}
+		if len(env) > 0 {
+			task.Env = env
+		}
+	}
+	return nil
+}
+
+// ApplyEnvFiles loads dotenv files and sets their entries on every task,
+// overriding all env values from the configuration file
+func (c *Config) ApplyEnvFiles(paths []string) error {
+	env, err := LoadEnvFiles(paths, "", os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	for _, task := range c.envTasks() {
+		if task.Env == nil {
+			task.Env = make(map[string]string, len(env))
+		}
+		for k, v := range env {
+			task.Env[k] = v
+		}
+	}
+	return nil
+}
+
+// envTasks returns the tasks whose env comes from the configuration: all
+// tasks and the base of run hook commands
+func (c *Config) envTasks() []*Task {
+	tasks := append([]*Task(nil), c.Tasks...)
+	if c.Hooks != nil && c.Hooks.base != nil {
+		tasks = append(tasks, c.Hooks.base)
+	}
+	return tasks
+}
+
 // SaveConfig saves task configuration to a YAML file
 func SaveConfig(filepath string, config *Config) error {
 	data, err := yaml.Marshal(config)
@@ -97,5 +249,16 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := c.validateResources(); err != nil {
+		return err
+	}
+	if err := c.validateHooks(); err != nil {
+		return err
+	}
+	for i, channel := range c.Notify {
+		if err := channel.Validate(); err != nil {
+			return fmt.Errorf("notify[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
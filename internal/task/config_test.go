@@ -0,0 +1,75 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`version: "1.0"
+defaults:
+  timeout: 30s
+  retry_count: 2
+  workdir: build
+  shell: bash
+  interpreter: python3
+  output: stream
+  on_failure: continue
+tasks:
+  - id: inherits
+    name: Inherits
+    type: command
+    command: make
+  - id: overrides
+    name: Overrides
+    type: command
+    command: make
+    timeout: 0s
+    retry_count: 0
+    workdir: ""
+    shell: ~
+    output: buffered
+    on_failure: stop
+`), 0600))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+	inherits, overrides := config.Tasks[0], config.Tasks[1]
+
+	assert.Equal(t, 30*time.Second, inherits.Timeout)
+	assert.Equal(t, 2, inherits.RetryCount)
+	assert.Equal(t, "build", inherits.WorkDir)
+	assert.Equal(t, "bash", inherits.Shell)
+	assert.Equal(t, "python3", inherits.Interpreter)
+	assert.Equal(t, OutputStream, inherits.OutputMode)
+	assert.Equal(t, FailureContinue, inherits.OnFailure)
+
+	assert.Zero(t, overrides.Timeout)
+	assert.Zero(t, overrides.RetryCount)
+	assert.Empty(t, overrides.WorkDir)
+	assert.Empty(t, overrides.Shell)
+	assert.Equal(t, "python3", overrides.Interpreter)
+	assert.Equal(t, OutputBuffered, overrides.OutputMode)
+	assert.Equal(t, FailureStop, overrides.OnFailure)
+}
+
+func TestConfig_ValidateModes(t *testing.T) {
+	base := func() *Task {
+		return &Task{ID: "t", Name: "T", Type: TaskTypeCommand, Command: "go version"}
+	}
+
+	task := base()
+	task.OutputMode = "loud"
+	assert.ErrorContains(t, task.Validate(), "unknown output mode")
+
+	task = base()
+	task.OnFailure = "ignore"
+	assert.ErrorContains(t, task.Validate(), "unknown failure policy")
+}
@@ -0,0 +1,335 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay lets editors finish writing the config before it is reloaded
+const reloadDelay = 200 * time.Millisecond
+
+// Daemon runs scheduled tasks in-process and reloads the configuration
+// file when it changes
+type Daemon struct {
+	configPath string
+	history    *History
+	logger     *slog.Logger
+	verbose    bool
+
+	subscribers []Subscriber
+	secrets     SecretResolver
+	policy      *Policy
+	check       func(data []byte) error
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// scheduledTask is a task together with its next activation time
+type scheduledTask struct {
+	task     *Task
+	schedule Schedule
+	next     time.Time
+	// runHooks are the run hooks of the configuration
+	runHooks *RunHooks
+	// hookTasks are the tasks the task's hooks and the run hooks run
+	hookTasks []*Task
+}
+
+// NewDaemon creates a daemon for the given configuration file. Every run is
+// recorded in history and progress is logged to logger.
+func NewDaemon(configPath string, history *History, logger *slog.Logger, verbose bool) *Daemon {
+	return &Daemon{
+		configPath: configPath,
+		history:    history,
+		logger:     logger,
+		verbose:    verbose,
+		running:    make(map[string]bool),
+	}
+}
+
+// Subscribe delivers the events of every scheduled run to sub. It must be
+// called before Run.
+func (d *Daemon) Subscribe(sub Subscriber) {
+	d.subscribers = append(d.subscribers, sub)
+}
+
+// SetSecretResolver sets how secret:// references in task env values are
+// resolved. It must be called before Run.
+func (d *Daemon) SetSecretResolver(resolver SecretResolver) {
+	d.secrets = resolver
+}
+
+// SetPolicy makes every run check its tasks against policy, and refuses
+// configs, at startup and on reload, with tasks the policy denies. It must
+// be called before Run.
+func (d *Daemon) SetPolicy(policy *Policy) {
+	d.policy = policy
+}
+
+// SetConfigCheck makes the daemon pass the content of the configuration
+// file to check before loading it, at startup and on every reload, and
+// refuse it if check returns an error. It must be called before Run.
+func (d *Daemon) SetConfigCheck(check func(data []byte) error) {
+	d.check = check
+}
+
+// Run schedules tasks until ctx is done, then waits for in-flight runs
+func (d *Daemon) Run(ctx context.Context) error {
+	config, err := d.loadConfig()
+	if err != nil {
+		return err
+	}
+	entries := d.buildSchedule(config, time.Now())
+	d.logger.Info("scheduled tasks", "config", d.configPath, "tasks", len(entries))
+
+	configAbs, err := filepath.Abs(d.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer fsw.Close()
+	// Watch the directory, editors often replace the file on save
+	if err := fsw.Add(filepath.Dir(configAbs)); err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	var reloadC <-chan time.Time
+	for {
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if next := earliest(entries); !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			timerC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			d.wg.Wait()
+			return nil
+
+		case <-timerC:
+			d.triggerDue(ctx, entries, time.Now())
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == configAbs && event.Op != fsnotify.Chmod {
+				reloadC = time.After(reloadDelay)
+			}
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			d.logger.Error("config watcher failed", "error", err)
+
+		case <-reloadC:
+			reloadC = nil
+			config, err := d.loadConfig()
+			if err != nil {
+				d.logger.Error("failed to reload config, keeping previous schedule", "config", d.configPath, "error", err)
+				break
+			}
+			entries = d.buildSchedule(config, time.Now())
+			d.logger.Info("reloaded config", "config", d.configPath, "tasks", len(entries))
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// triggerDue triggers the entries due at now and computes their next
+// activation. Entries without one never trigger.
+func (d *Daemon) triggerDue(ctx context.Context, entries []*scheduledTask, now time.Time) {
+	for _, entry := range entries {
+		if entry.next.IsZero() || entry.next.After(now) {
+			continue
+		}
+		d.trigger(ctx, entry.task, entry.runHooks, entry.hookTasks)
+		entry.next = entry.schedule.Next(now)
+	}
+}
+
+// trigger starts a run of the task between the run hooks, with the tasks
+// the hooks run, unless the previous one is still going
+func (d *Daemon) trigger(ctx context.Context, t *Task, runHooks *RunHooks, hookTasks []*Task) {
+	d.mu.Lock()
+	if d.running[t.ID] {
+		d.mu.Unlock()
+		d.logger.Warn("task is still running, skipping this activation", "task_id", t.ID)
+		return
+	}
+	d.running[t.ID] = true
+	d.mu.Unlock()
+
+	// Run a copy so reloads never touch an in-flight execution
+	task := t.Clone()
+	runID := NewRunID()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			d.mu.Lock()
+			delete(d.running, task.ID)
+			d.mu.Unlock()
+		}()
+
+		logger := d.logger.With("run_id", runID, "task_id", task.ID)
+		executor := NewExecutor(1, d.verbose)
+		executor.SetRunID(runID)
+		executor.SetLogger(d.logger)
+		for _, sub := range d.subscribers {
+			executor.Subscribe(sub)
+		}
+		if d.secrets != nil {
+			executor.SetSecretResolver(d.secrets)
+		}
+		executor.SetPolicy(d.policy)
+		executor.SetHooks(runHooks)
+		if err := executor.AddTask(task); err != nil {
+			logger.Error("failed to schedule task", "error", err)
+			return
+		}
+		for _, t := range hookTasks {
+			if err := executor.AddTask(t.Clone()); err != nil {
+				logger.Error("failed to schedule task", "error", err)
+				return
+			}
+		}
+
+		// Runs of the daemon and of task run take turns in the workspace
+		lock, err := AcquireLock(ctx, ConfigLockPath(DefaultLockDir, d.configPath), "task daemon, task "+task.ID, true)
+		if err != nil {
+			logger.Error("failed to acquire run lock", "error", err)
+			return
+		}
+		defer func() {
+			if err := lock.Release(); err != nil {
+				logger.Warn("failed to release run lock", "error", err)
+			}
+		}()
+
+		logger.Info("starting scheduled task")
+		result, err := executor.ExecuteTask(ctx, task.ID)
+		if result == nil {
+			logger.Error("failed to run task", "error", err)
+			return
+		}
+		if err != nil {
+			logger.Warn("task hooks failed", "error", err)
+		}
+
+		record := NewHistoryRecord(runID, TriggerSchedule, result)
+		if err := d.history.Append(record); err != nil {
+			logger.Error("failed to record run", "error", err)
+		}
+		if result.Success {
+			logger.Info("scheduled task completed", "duration", result.Duration)
+		} else {
+			logger.Warn("scheduled task failed", "duration", result.Duration, "error", result.Error)
+		}
+	}()
+}
+
+// loadConfig loads and validates the configuration file and checks it
+// with the config check and the policy, if any
+func (d *Daemon) loadConfig() (*Config, error) {
+	data, err := os.ReadFile(d.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if d.check != nil {
+		if err := d.check(data); err != nil {
+			return nil, err
+		}
+	}
+	config, err := ParseConfig(data, d.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if d.policy != nil {
+		tasks := append(append([]*Task(nil), config.Tasks...), config.HookCommands()...)
+		if denials := d.policy.CheckAll(context.Background(), tasks); len(denials) > 0 {
+			return nil, errors.Join(denials...)
+		}
+	}
+	return config, nil
+}
+
+// buildSchedule computes the next activation of every scheduled task
+func (d *Daemon) buildSchedule(config *Config, now time.Time) []*scheduledTask {
+	var entries []*scheduledTask
+	for _, t := range config.Tasks {
+		if t.Schedule == "" {
+			continue
+		}
+		// Validate already checked the schedule
+		schedule, err := ParseSchedule(t.Schedule)
+		if err != nil {
+			continue
+		}
+		var hookTasks []*Task
+		seen := make(map[string]bool)
+		for _, lists := range []map[HookKind]HookList{t.Hooks.lists(), config.Hooks.lists()} {
+			for _, hooks := range lists {
+				for _, hook := range hooks {
+					if hook.Task == "" {
+						continue
+					}
+					for _, target := range hookTargets(hook.Task, config.Tasks) {
+						// A hook running the scheduled task itself reuses it
+						if target.ID != t.ID && !seen[target.ID] {
+							seen[target.ID] = true
+							hookTasks = append(hookTasks, target)
+						}
+					}
+				}
+			}
+		}
+		entries = append(entries, &scheduledTask{
+			task:      t,
+			schedule:  schedule,
+			next:      schedule.Next(now),
+			runHooks:  config.Hooks,
+			hookTasks: hookTasks,
+		})
+	}
+	return entries
+}
+
+// earliest returns the soonest activation time, or zero if none
+func earliest(entries []*scheduledTask) time.Time {
+	var next time.Time
+	for _, entry := range entries {
+		if entry.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || entry.next.Before(next) {
+			next = entry.next
+		}
+	}
+	return next
+}
@@ -0,0 +1,344 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writers
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHistory_AppendAndRecords(t *testing.T) {
+	history := NewHistory(filepath.Join(t.TempDir(), "state", "history.jsonl"))
+
+	records, err := history.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	require.NoError(t, history.Append(HistoryRecord{RunID: "r1", TaskID: "a", Status: StatusCompleted}))
+	require.NoError(t, history.Append(HistoryRecord{RunID: "r2", TaskID: "a", Status: StatusFailed, Error: "boom"}))
+
+	records, err = history.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "r1", records[0].RunID)
+	assert.Equal(t, StatusFailed, records[1].Status)
+	assert.Equal(t, "boom", records[1].Error)
+}
+
+func TestDaemon_RunsScheduledTasks(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`version: "1.0"
+tasks:
+  - id: tick
+    name: Tick
+    type: command
+    command: go version
+    schedule: "@every 1s"
+  - id: manual
+    name: Manual
+    type: command
+    command: go version
+`), 0600))
+
+	history := NewHistory(filepath.Join(dir, "history.jsonl"))
+	out := &syncBuffer{}
+	daemon := NewDaemon(configPath, history, slog.New(slog.NewTextHandler(out, nil)), false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	require.NoError(t, daemon.Run(ctx))
+
+	records, err := history.Records()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		assert.Equal(t, "tick", record.TaskID)
+		assert.Equal(t, TriggerSchedule, record.Trigger)
+		assert.Equal(t, StatusCompleted, record.Status)
+		assert.NotEmpty(t, record.RunID)
+	}
+	assert.Contains(t, out.String(), "msg=\"scheduled tasks\" config="+configPath+" tasks=1")
+}
+
+func TestDaemon_SkipsOverlappingRuns(t *testing.T) {
+	out := &syncBuffer{}
+	daemon := NewDaemon("unused.yaml", NewHistory(filepath.Join(t.TempDir(), "history.jsonl")), slog.New(slog.NewTextHandler(out, nil)), false)
+	daemon.running["busy"] = true
+	daemon.trigger(context.Background(), &Task{ID: "busy", Name: "Busy", Type: TaskTypeCommand, Command: "go version"}, nil, nil)
+	daemon.wg.Wait()
+
+	assert.Contains(t, out.String(), "still running")
+}
+
+func TestDaemon_ReloadsConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tasks.yaml")
+	config := `version: "1.0"
+tasks:
+  - id: tick
+    name: Tick
+    type: command
+    command: go version
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0600))
+
+	history := NewHistory(filepath.Join(dir, "history.jsonl"))
+	out := &syncBuffer{}
+	daemon := NewDaemon(configPath, history, slog.New(slog.NewTextHandler(out, nil)), false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.WriteFile(configPath, []byte(config+"    schedule: \"@every 1s\"\n"), 0600)
+	}()
+	require.NoError(t, daemon.Run(ctx))
+
+	records, err := history.Records()
+	require.NoError(t, err)
+	assert.NotEmpty(t, records)
+	assert.Contains(t, out.String(), "reloaded config")
+}
+
+func TestDaemon_Policy(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tasks.yaml")
+	config := `version: "1.0"
+tasks:
+  - id: tick
+    name: Tick
+    type: command
+    command: go version
+    schedule: "@every 1s"
+`
+	denied := config + `  - id: fetch
+    name: Fetch
+    type: command
+    command: curl example.com
+    schedule: "@every 1s"
+`
+	policy, err := loadPolicyString(t, "allowed_executables: [go]\n")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(configPath, []byte(denied), 0600))
+	daemon := NewDaemon(configPath, NewHistory(filepath.Join(dir, "history.jsonl")), slog.New(slog.NewTextHandler(&syncBuffer{}, nil)), false)
+	daemon.SetPolicy(policy)
+	assert.ErrorContains(t, daemon.Run(context.Background()), "task fetch denied by policy")
+
+	// A reload to a config the policy denies keeps the previous schedule
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0600))
+	history := NewHistory(filepath.Join(dir, "history.jsonl"))
+	out := &syncBuffer{}
+	daemon = NewDaemon(configPath, history, slog.New(slog.NewTextHandler(out, nil)), false)
+	daemon.SetPolicy(policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.WriteFile(configPath, []byte(denied), 0600)
+	}()
+	require.NoError(t, daemon.Run(ctx))
+
+	assert.Contains(t, out.String(), "keeping previous schedule")
+	records, err := history.Records()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		assert.Equal(t, "tick", record.TaskID)
+		assert.Equal(t, StatusCompleted, record.Status)
+	}
+}
+
+func TestDaemon_ConfigCheck(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tasks.yaml")
+	config := `version: "1.0"
+tasks:
+  - id: tick
+    name: Tick
+    type: command
+    command: go version
+    schedule: "@every 1s"
+`
+	changed := strings.Replace(config, "go version", "go env", 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0600))
+
+	history := NewHistory(filepath.Join(dir, "history.jsonl"))
+	out := &syncBuffer{}
+	daemon := NewDaemon(configPath, history, slog.New(slog.NewTextHandler(out, nil)), false)
+	var checked []string
+	var mu sync.Mutex
+	daemon.SetConfigCheck(func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		checked = append(checked, string(data))
+		if string(data) != config {
+			return errors.New("config changed since it was trusted")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.WriteFile(configPath, []byte(changed), 0600)
+	}()
+	require.NoError(t, daemon.Run(ctx))
+
+	assert.Contains(t, out.String(), "config changed since it was trusted")
+	mu.Lock()
+	require.GreaterOrEqual(t, len(checked), 2)
+	assert.Equal(t, config, checked[0])
+	assert.Equal(t, changed, checked[len(checked)-1])
+	mu.Unlock()
+	records, err := history.Records()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		assert.Equal(t, "tick", record.TaskID)
+	}
+}
+
+func TestDaemon_RunHooks(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`version: "1.0"
+hooks:
+  before_all: go version
+  after_all:
+    task: cleanup
+tasks:
+  - id: tick
+    name: Tick
+    type: command
+    command: go version
+    schedule: "@every 1s"
+  - id: cleanup
+    name: Cleanup
+    type: command
+    command: go env GOOS
+`), 0600))
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+
+	out := &syncBuffer{}
+	daemon := NewDaemon(configPath, NewHistory(filepath.Join(dir, "history.jsonl")), slog.New(slog.NewTextHandler(out, nil)), false)
+	var mu sync.Mutex
+	var succeeded []string
+	daemon.Subscribe(SubscriberFunc(func(event Event) {
+		if event.Type == EventTaskSucceeded {
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded = append(succeeded, event.TaskID)
+		}
+	}))
+
+	entries := daemon.buildSchedule(config, time.Now())
+	require.Len(t, entries, 1)
+	daemon.trigger(context.Background(), entries[0].task, entries[0].runHooks, entries[0].hookTasks)
+	daemon.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"before_all", "tick", "cleanup"}, succeeded)
+	assert.NotContains(t, out.String(), "task hooks failed")
+}
+
+func TestDaemon_RunHookOnScheduledTask(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`version: "1.0"
+hooks:
+  on_failure:
+    task: tick
+tasks:
+  - id: tick
+    name: Tick
+    type: command
+    command: go version
+    schedule: "@every 1s"
+`), 0600))
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+
+	out := &syncBuffer{}
+	daemon := NewDaemon(configPath, NewHistory(filepath.Join(dir, "history.jsonl")), slog.New(slog.NewTextHandler(out, nil)), false)
+	var mu sync.Mutex
+	var succeeded []string
+	daemon.Subscribe(SubscriberFunc(func(event Event) {
+		if event.Type == EventTaskSucceeded {
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded = append(succeeded, event.TaskID)
+		}
+	}))
+
+	entries := daemon.buildSchedule(config, time.Now())
+	require.Len(t, entries, 1)
+	assert.Empty(t, entries[0].hookTasks, "the scheduled task is not added twice")
+	for i := 0; i < 2; i++ {
+		daemon.trigger(context.Background(), entries[0].task, entries[0].runHooks, entries[0].hookTasks)
+		daemon.wg.Wait()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"tick", "tick"}, succeeded)
+	assert.NotContains(t, out.String(), "failed to schedule task")
+}
+
+// neverSchedule is a schedule without any activation
+type neverSchedule struct{}
+
+func (neverSchedule) Next(time.Time) time.Time { return time.Time{} }
+
+func TestDaemon_SkipsSchedulesThatNeverMatch(t *testing.T) {
+	history := NewHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	daemon := NewDaemon("unused.yaml", history, slog.New(slog.NewTextHandler(&syncBuffer{}, nil)), false)
+	schedule, err := ParseSchedule("@every 1s")
+	require.NoError(t, err)
+	now := time.Now()
+	entries := []*scheduledTask{
+		{task: &Task{ID: "tick", Name: "Tick", Type: TaskTypeCommand, Command: "go version"}, schedule: schedule, next: now},
+		{task: &Task{ID: "never", Name: "Never", Type: TaskTypeCommand, Command: "go version"}, schedule: neverSchedule{}},
+	}
+
+	daemon.triggerDue(context.Background(), entries, now)
+	daemon.wg.Wait()
+
+	records, err := history.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "tick", records[0].TaskID)
+	assert.True(t, entries[1].next.IsZero())
+}
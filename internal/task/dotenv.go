@@ -0,0 +1,215 @@
+package task
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StringList is a list of strings that can be written in YAML as a single
+// scalar or as a sequence
+type StringList []string
+
+// UnmarshalYAML accepts a scalar or a sequence of scalars
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			*l = nil
+			return nil
+		}
+		*l = StringList{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	default:
+		return fmt.Errorf("line %d: expected a string or a list of strings", node.Line)
+	}
+}
+
+// LoadEnvFiles reads dotenv files in order, later files overriding earlier
+// ones. Relative paths are resolved against baseDir. References in values
+// are expanded from earlier entries, then from lookup.
+func LoadEnvFiles(paths []string, baseDir string, lookup func(string) (string, bool)) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, path := range paths {
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open env file: %w", err)
+		}
+		err = parseDotenv(f, path, env, lookup)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// parseDotenv parses KEY=VALUE lines into env. Supported syntax: comments,
+// an optional "export " prefix, single-quoted literal values, double-quoted
+// values with escapes that may span lines, and unquoted values with
+// trailing " #" comments. $NAME, ${NAME} and ${NAME:-default} are expanded
+// in unquoted and double-quoted values; unknown names are left as they are
+// so run variables can still fill them in.
+func parseDotenv(r io.Reader, name string, env map[string]string, lookup func(string) (string, bool)) error {
+	resolve := func(key string) (string, bool) {
+		if v, ok := env[key]; ok {
+			return v, true
+		}
+		if lookup != nil {
+			return lookup(key)
+		}
+		return "", false
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isEnvName(key) {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", name, lineNo)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return fmt.Errorf("%s:%d: unterminated single-quoted value", name, lineNo)
+			}
+			env[key] = value[1 : end+1]
+
+		case strings.HasPrefix(value, `"`):
+			start := lineNo
+			raw := value[1:]
+			for closingQuote(raw) < 0 {
+				if !scanner.Scan() {
+					return fmt.Errorf("%s:%d: unterminated double-quoted value", name, start)
+				}
+				lineNo++
+				raw += "\n" + scanner.Text()
+			}
+			raw = raw[:closingQuote(raw)]
+			env[key] = expandEnv(unescape(raw), resolve)
+
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			env[key] = expandEnv(value, resolve)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+	return nil
+}
+
+// closingQuote returns the index of the first unescaped double quote
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+var dotenvEscapes = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, "\x00")
+
+// unescape resolves backslash escapes. An escaped $ is kept out of variable
+// expansion by a placeholder that expandEnv turns back into $.
+func unescape(s string) string {
+	return dotenvEscapes.Replace(s)
+}
+
+// expandEnv expands $NAME, ${NAME} and ${NAME:-default} references whose
+// names resolve, leaving other references untouched
+func expandEnv(s string, resolve func(string) (string, bool)) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if s[i+1] == '{' {
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteByte(s[i])
+				continue
+			}
+			ref := s[i+2 : i+end]
+			name, fallback, hasDefault := strings.Cut(ref, ":-")
+			if value, ok := resolve(name); ok && (value != "" || !hasDefault) {
+				b.WriteString(value)
+			} else if hasDefault {
+				b.WriteString(fallback)
+			} else {
+				b.WriteString(s[i : i+end+1])
+			}
+			i += end
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && isEnvNameByte(s[j], j == i+1) {
+			j++
+		}
+		if value, ok := resolve(s[i+1 : j]); ok && j > i+1 {
+			b.WriteString(value)
+			i = j - 1
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return strings.ReplaceAll(b.String(), "\x00", "$")
+}
+
+// isEnvName reports whether s is a valid environment variable name
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isEnvNameByte(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameByte(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
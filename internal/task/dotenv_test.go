@@ -0,0 +1,130 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "HOME_DIR" {
+			return "/home/ci", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "plain and export",
+			input: "# comment\n\nA=1\nexport B = two \n",
+			want:  map[string]string{"A": "1", "B": "two"},
+		},
+		{
+			name:  "inline comment",
+			input: "A=value # note\nB=a#b",
+			want:  map[string]string{"A": "value", "B": "a#b"},
+		},
+		{
+			name:  "single quotes are literal",
+			input: `A='$HOME_DIR \n # x'`,
+			want:  map[string]string{"A": `$HOME_DIR \n # x`},
+		},
+		{
+			name:  "double quotes with escapes",
+			input: `A="tab\there \"q\" \$HOME_DIR"`,
+			want:  map[string]string{"A": "tab\there \"q\" $HOME_DIR"},
+		},
+		{
+			name:  "multi-line double quotes",
+			input: "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1",
+			want:  map[string]string{"KEY": "-----BEGIN-----\nabc\n-----END-----", "NEXT": "1"},
+		},
+		{
+			name:  "expansion",
+			input: "BASE=${HOME_DIR}/app\nBIN=$BASE/bin\nMODE=${MODE:-dev}\nKEEP=${version}-$UNKNOWN",
+			want: map[string]string{
+				"BASE": "/home/ci/app",
+				"BIN":  "/home/ci/app/bin",
+				"MODE": "dev",
+				"KEEP": "${version}-$UNKNOWN",
+			},
+		},
+		{name: "missing equals", input: "A=1\nBROKEN", wantErr: "test.env:2"},
+		{name: "invalid name", input: "1A=x", wantErr: "test.env:1"},
+		{name: "unterminated quote", input: "A=\"open\nB=1", wantErr: "test.env:1: unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := make(map[string]string)
+			err := parseDotenv(strings.NewReader(tt.input), "test.env", env, lookup)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, env)
+		})
+	}
+}
+
+func TestLoadConfig_EnvFilePrecedence(t *testing.T) {
+	t.Setenv("DOTENV_TEST_OS", "os")
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	write("base.env", "LEVEL=defaults-file\nFROM_OS=${DOTENV_TEST_OS}\nDEFAULT_ONLY=1\n")
+	write("task.env", "LEVEL=task-file\nTASK_FILE=${DEFAULT_ENV}\n")
+	write("tasks.yaml", `version: "1.0"
+defaults:
+  env_file: base.env
+  env:
+    LEVEL: defaults-env
+    DEFAULT_ENV: from-defaults
+tasks:
+  - id: a
+    type: command
+    command: go
+    env_file: [task.env]
+  - id: b
+    type: command
+    command: go
+    env_file: task.env
+    env:
+      LEVEL: task-env
+  - id: c
+    type: command
+    command: go
+`)
+
+	config, err := LoadConfig(filepath.Join(dir, "tasks.yaml"))
+	require.NoError(t, err)
+	a, b, c := config.Tasks[0], config.Tasks[1], config.Tasks[2]
+
+	assert.Equal(t, StringList{"task.env"}, a.EnvFile)
+	assert.Equal(t, "task-file", a.Env["LEVEL"])
+	assert.Equal(t, "from-defaults", a.Env["TASK_FILE"])
+	assert.Equal(t, "os", a.Env["FROM_OS"])
+	assert.Equal(t, "1", a.Env["DEFAULT_ONLY"])
+	assert.Equal(t, "task-env", b.Env["LEVEL"])
+	assert.Equal(t, "defaults-env", c.Env["LEVEL"])
+
+	override := filepath.Join(dir, "override.env")
+	require.NoError(t, os.WriteFile(override, []byte("LEVEL=override\n"), 0600))
+	require.NoError(t, config.ApplyEnvFiles([]string{override}))
+	assert.Equal(t, "override", b.Env["LEVEL"])
+
+	write("missing.yaml", "version: \"1.0\"\ndefaults:\n  env_file: nope.env\n")
+	_, err = LoadConfig(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "nope.env")
+}
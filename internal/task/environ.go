@@ -0,0 +1,240 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvInherit modes
+const (
+	EnvInheritAll  = "all"
+	EnvInheritNone = "none"
+)
+
+// EnvInherit controls which variables of the go-cli-tool process a task
+// inherits. It is written in YAML as "all", "none" or a list of allowed
+// names; names ending in * match by prefix. The zero value inherits all.
+type EnvInherit struct {
+	Mode  string
+	Allow []string
+}
+
+// IsZero reports whether the inheritance was left unset
+func (e EnvInherit) IsZero() bool {
+	return e.Mode == "" && e.Allow == nil
+}
+
+// UnmarshalYAML accepts "all", "none" or a list of names
+func (e *EnvInherit) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Value {
+		case EnvInheritAll, EnvInheritNone:
+			*e = EnvInherit{Mode: node.Value}
+			return nil
+		}
+		return fmt.Errorf("line %d: env_inherit must be %q, %q or a list of names", node.Line, EnvInheritAll, EnvInheritNone)
+	case yaml.SequenceNode:
+		var allow []string
+		if err := node.Decode(&allow); err != nil {
+			return err
+		}
+		*e = EnvInherit{Allow: append([]string{}, allow...)}
+		return nil
+	default:
+		return fmt.Errorf("line %d: env_inherit must be %q, %q or a list of names", node.Line, EnvInheritAll, EnvInheritNone)
+	}
+}
+
+// MarshalYAML writes the mode or the allowlist, and nothing when unset so
+// the zero value does not load back as an empty allowlist
+func (e EnvInherit) MarshalYAML() (interface{}, error) {
+	if e.IsZero() {
+		return nil, nil
+	}
+	if e.Mode != "" {
+		return e.Mode, nil
+	}
+	return e.Allow, nil
+}
+
+// MarshalJSON writes the mode or the allowlist
+func (e EnvInherit) MarshalJSON() ([]byte, error) {
+	if e.Mode != "" || e.Allow == nil {
+		return json.Marshal(e.Mode)
+	}
+	return json.Marshal(e.Allow)
+}
+
+// UnmarshalJSON accepts "all", "none", "" or a list of names
+func (e *EnvInherit) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		switch mode {
+		case "", EnvInheritAll, EnvInheritNone:
+			*e = EnvInherit{Mode: mode}
+			return nil
+		}
+		return fmt.Errorf("env_inherit must be %q, %q or a list of names", EnvInheritAll, EnvInheritNone)
+	}
+	var allow []string
+	if err := json.Unmarshal(data, &allow); err != nil {
+		return fmt.Errorf("env_inherit must be %q, %q or a list of names", EnvInheritAll, EnvInheritNone)
+	}
+	*e = EnvInherit{Allow: append([]string{}, allow...)}
+	return nil
+}
+
+// inherits reports whether a variable of the parent process is passed on
+func (e EnvInherit) inherits(name string) bool {
+	switch {
+	case e.Mode == EnvInheritNone:
+		return false
+	case e.Allow != nil:
+		return matchEnvName(e.Allow, name)
+	default:
+		return true
+	}
+}
+
+// matchEnvName reports whether name matches one of the patterns. Names are
+// case-insensitive on Windows.
+func matchEnvName(patterns []string, name string) bool {
+	if runtime.GOOS == "windows" {
+		name = strings.ToUpper(name)
+	}
+	for _, pattern := range patterns {
+		if runtime.GOOS == "windows" {
+			pattern = strings.ToUpper(pattern)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEnvControls reports whether the task changes the inherited environment
+func (t *Task) hasEnvControls() bool {
+	return !t.EnvInherit.IsZero() || len(t.EnvRemove) > 0 || len(t.PathPrepend) > 0
+}
+
+// environ builds the environment of the task's command. Inherited variables
+// are filtered by env_inherit and env_remove, then run variables and the
+// task env are added and path_prepend is put in front of PATH. It returns
+// nil when the command should simply inherit the parent environment, and
+// never nil otherwise, even if nothing is left to pass on.
+func (t *Task) environ(ctx context.Context, cmd *exec.Cmd, vars map[string]string) []string {
+	if len(t.Env) == 0 && len(vars) == 0 && !t.hasEnvControls() {
+		return nil
+	}
+
+	env := []string{}
+	for _, kv := range cmd.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !t.EnvInherit.inherits(name) || matchEnvName(t.EnvRemove, name) {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	// Run variables first so task env wins. Variables that are not valid
+	// env names, such as matrix.<name>, are only used for ${} expansion.
+	for k, v := range vars {
+		if isEnvName(k) {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	secretEnv := secretEnvFrom(ctx)
+	for k, v := range t.Env {
+		if secret, ok := secretEnv[k]; ok {
+			v = secret
+		} else {
+			v = expandVars(v, vars)
+		}
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	if len(t.PathPrepend) > 0 {
+		dirs := t.pathPrepend(cmd.Dir, vars)
+		path := strings.Join(dirs, string(os.PathListSeparator))
+		if current := lookupEnv(env, "PATH"); current != "" {
+			path += string(os.PathListSeparator) + current
+		}
+		env = append(env, "PATH="+path)
+
+		// exec resolves the command with the PATH of this process, so look
+		// it up in the prepended directories first
+		if !strings.ContainsAny(cmd.Args[0], `/\`) {
+			if found := lookPathIn(cmd.Args[0], dirs); found != "" {
+				cmd.Path = found
+				cmd.Err = nil
+			}
+		}
+	}
+	return env
+}
+
+// pathPrepend returns the path_prepend directories as absolute paths,
+// relative ones resolved against the command's working directory
+func (t *Task) pathPrepend(dir string, vars map[string]string) []string {
+	dirs := make([]string, 0, len(t.PathPrepend))
+	for _, d := range t.PathPrepend {
+		d = expandVars(d, vars)
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+			if abs, err := filepath.Abs(d); err == nil {
+				d = abs
+			}
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs
+}
+
+// lookupEnv returns the last value of name in env, as exec does
+func lookupEnv(env []string, name string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		k, v, _ := strings.Cut(env[i], "=")
+		if k == name || (runtime.GOOS == "windows" && strings.EqualFold(k, name)) {
+			return v
+		}
+	}
+	return ""
+}
+
+// lookPathIn searches dirs for an executable file named name
+func lookPathIn(name string, dirs []string) string {
+	candidates := []string{name}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		candidates = nil
+		for _, ext := range []string{".com", ".exe", ".bat", ".cmd"} {
+			candidates = append(candidates, name+ext)
+		}
+	}
+	for _, dir := range dirs {
+		for _, candidate := range candidates {
+			path := filepath.Join(dir, candidate)
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" || info.Mode()&0111 != 0 {
+				return path
+			}
+		}
+	}
+	return ""
+}
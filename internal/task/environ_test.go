@@ -0,0 +1,149 @@
+package task
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvInherit_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    EnvInherit
+		wantErr bool
+	}{
+		{"env_inherit: all", EnvInherit{Mode: EnvInheritAll}, false},
+		{"env_inherit: none", EnvInherit{Mode: EnvInheritNone}, false},
+		{"env_inherit: [PATH, LC_*]", EnvInherit{Allow: []string{"PATH", "LC_*"}}, false},
+		{"env_inherit: []", EnvInherit{Allow: []string{}}, false},
+		{"env_inherit: some", EnvInherit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var task Task
+			err := yaml.Unmarshal([]byte(tt.input), &task)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, task.EnvInherit)
+
+			data, err := yaml.Marshal(&task)
+			require.NoError(t, err)
+			var again Task
+			require.NoError(t, yaml.Unmarshal(data, &again))
+			assert.Equal(t, tt.want, again.EnvInherit)
+		})
+	}
+}
+
+func TestTask_Environ(t *testing.T) {
+	t.Setenv("ENVIRON_TEST_KEEP", "keep")
+	t.Setenv("ENVIRON_TEST_SECRET", "secret")
+	t.Setenv("LC_ENVIRON_TEST", "C")
+
+	tests := []struct {
+		name    string
+		task    Task
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "inherit all by default",
+			task:    Task{EnvRemove: []string{"ENVIRON_TEST_SECRET"}},
+			present: []string{"ENVIRON_TEST_KEEP=keep", "LC_ENVIRON_TEST=C"},
+			absent:  []string{"ENVIRON_TEST_SECRET"},
+		},
+		{
+			name:    "inherit none keeps explicit env",
+			task:    Task{EnvInherit: EnvInherit{Mode: EnvInheritNone}, Env: map[string]string{"OWN": "1"}},
+			present: []string{"OWN=1"},
+			absent:  []string{"ENVIRON_TEST_KEEP", "LC_ENVIRON_TEST"},
+		},
+		{
+			name:    "allowlist with prefix",
+			task:    Task{EnvInherit: EnvInherit{Allow: []string{"ENVIRON_TEST_KEEP", "LC_*"}}},
+			present: []string{"ENVIRON_TEST_KEEP=keep", "LC_ENVIRON_TEST=C"},
+			absent:  []string{"ENVIRON_TEST_SECRET"},
+		},
+		{
+			name:   "empty allowlist",
+			task:   Task{EnvInherit: EnvInherit{Allow: []string{}}},
+			absent: []string{"ENVIRON_TEST_KEEP", "LC_ENVIRON_TEST"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("go")
+			env := strings.Join(tt.task.environ(context.Background(), cmd, nil), "\n") + "\n"
+			for _, kv := range tt.present {
+				assert.Contains(t, env, kv+"\n")
+			}
+			for _, name := range tt.absent {
+				assert.NotContains(t, env, name+"=")
+			}
+		})
+	}
+}
+
+func TestTask_EnvironInheritNone(t *testing.T) {
+	task := Task{EnvInherit: EnvInherit{Mode: EnvInheritNone}}
+	env := task.environ(context.Background(), exec.Command("go"), nil)
+	assert.NotNil(t, env, "a nil environment would inherit everything")
+	assert.Empty(t, env)
+}
+
+func TestEnvInherit_RoundTrip(t *testing.T) {
+	config := &Config{
+		Version: "1.0",
+		Tasks: []*Task{
+			{ID: "unset", Name: "Unset", Type: TaskTypeCommand, Command: "go version", Env: map[string]string{"X": "1"}},
+			{ID: "none", Name: "None", Type: TaskTypeCommand, Command: "go version", EnvInherit: EnvInherit{Mode: EnvInheritNone}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+	require.NoError(t, SaveConfig(path, config))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "env_inherit"))
+
+	loaded, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.True(t, loaded.Tasks[0].EnvInherit.IsZero())
+	assert.True(t, loaded.Defaults.EnvInherit.IsZero())
+	assert.Equal(t, EnvInherit{Mode: EnvInheritNone}, loaded.Tasks[1].EnvInherit)
+
+	env := strings.Join(loaded.Tasks[0].environ(context.Background(), exec.Command("go"), nil), "\n")
+	assert.Contains(t, env, "PATH=")
+}
+
+func TestTask_PathPrepend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(bin, 0750))
+	// #nosec G306 -- the test script must be executable
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "environ-test-tool"), []byte("#!/bin/sh\necho \"tool $PATH\"\n"), 0700))
+
+	task := &Task{
+		ID: "tool", Name: "Tool", Type: TaskTypeCommand,
+		Command:     "environ-test-tool",
+		WorkDir:     dir,
+		PathPrepend: []string{"bin"},
+	}
+	result := task.Execute(context.Background())
+	require.NoError(t, result.Error)
+	assert.Contains(t, result.Output, "tool "+bin+string(os.PathListSeparator))
+}
@@ -0,0 +1,164 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType identifies what happened during a run
+type EventType string
+
+const (
+	EventRunStarted    EventType = "run_started"
+	EventTaskQueued    EventType = "task_queued"
+	EventTaskStarted   EventType = "task_started"
+	EventTaskOutput    EventType = "task_output"
+	EventTaskRetrying  EventType = "task_retrying"
+	EventTaskSucceeded EventType = "task_succeeded"
+	EventTaskFailed    EventType = "task_failed"
+	EventTaskSkipped   EventType = "task_skipped"
+	EventRunFinished   EventType = "run_finished"
+)
+
+// Event is emitted by the executor as a run progresses
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"run_id"`
+	TaskID   string    `json:"task_id,omitempty"`
+	TaskName string    `json:"task_name,omitempty"`
+	// Attempt is the 1-based attempt number of task events
+	Attempt     int `json:"attempt,omitempty"`
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Line is a line of task output, without the trailing newline
+	Line     string        `json:"line,omitempty"`
+	Status   TaskStatus    `json:"status,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	ExitCode int           `json:"exit_code,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	// FailureReason tells why a failed task failed, if known
+	FailureReason FailureReason `json:"failure_reason,omitempty"`
+	// TaskIDs lists the tasks of a run in run_started events
+	TaskIDs []string `json:"task_ids,omitempty"`
+}
+
+// Subscriber receives executor events. Events are delivered synchronously
+// and in order, so subscribers should return quickly.
+type Subscriber interface {
+	Handle(event Event)
+}
+
+// SubscriberFunc adapts a callback to the Subscriber interface
+type SubscriberFunc func(event Event)
+
+// Handle calls f(event)
+func (f SubscriberFunc) Handle(event Event) {
+	f(event)
+}
+
+// ConsoleSink prints human-readable progress messages
+type ConsoleSink struct {
+	w          io.Writer
+	showOutput bool
+}
+
+// NewConsoleSink creates a sink writing progress to w. With showOutput,
+// task output lines are printed too, prefixed with the task ID.
+func NewConsoleSink(w io.Writer, showOutput bool) *ConsoleSink {
+	return &ConsoleSink{w: w, showOutput: showOutput}
+}
+
+// Handle prints the event, if it is of interest on a console
+func (s *ConsoleSink) Handle(event Event) {
+	var msg string
+	switch event.Type {
+	case EventTaskStarted:
+		msg = fmt.Sprintf("Executing task %s (attempt %d/%d)...", event.TaskName, event.Attempt, event.MaxAttempts)
+	case EventTaskSucceeded:
+		msg = fmt.Sprintf("Task %s completed successfully (%.2fs)", event.TaskName, event.Duration.Seconds())
+	case EventTaskRetrying:
+		msg = fmt.Sprintf("Task %s failed, retrying... (%s)", event.TaskName, event.Error)
+	case EventTaskFailed:
+		msg = fmt.Sprintf("Task %s failed after %d attempts", event.TaskName, event.Attempt)
+	case EventTaskSkipped:
+		msg = fmt.Sprintf("Task %s skipped: %s", event.TaskName, event.Error)
+	case EventTaskOutput:
+		if s.showOutput {
+			fmt.Fprintf(s.w, "[%s] %s\n", event.TaskID, event.Line)
+		}
+		return
+	default:
+		return
+	}
+	fmt.Fprintf(s.w, "[%s] %s\n", event.Time.Format("15:04:05"), msg)
+}
+
+// JSONLinesSink writes every event as a JSON object per line
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesSink creates a sink writing events to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// OpenJSONLinesFile creates a sink appending events to the file at path
+func OpenJSONLinesFile(path string) (*JSONLinesSink, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create events directory: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events file: %w", err)
+	}
+	return &JSONLinesSink{w: f, closer: f}, nil
+}
+
+// Handle writes the event as one JSON line
+func (s *JSONLinesSink) Handle(event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(append(data, '\n'))
+}
+
+// Close closes the underlying file, if the sink opened one
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// eventWriter turns the complete lines written by a lineWriter into
+// task_output events
+type eventWriter struct {
+	executor *Executor
+	task     *Task
+	attempt  int
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	w.executor.emit(Event{
+		Type:     EventTaskOutput,
+		TaskID:   w.task.ID,
+		TaskName: w.task.Name,
+		Attempt:  w.attempt,
+		Line:     strings.TrimRight(string(p), "\r\n"),
+	})
+	return len(p), nil
+}
@@ -0,0 +1,141 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_EmitsEvents(t *testing.T) {
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "goos", Name: "GOOS", Type: TaskTypeCommand, Command: "go", Args: []string{"env", "${WHICH}"}},
+		{ID: "broken", Name: "Broken", Type: TaskTypeCommand, Command: "nonexistent-command-12345", DependsOn: []string{"goos"}, RetryCount: 1},
+		{ID: "after", Name: "After", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"broken"}},
+	}))
+
+	var events []Event
+	executor.SetRunID("run-1")
+	executor.Subscribe(SubscriberFunc(func(event Event) {
+		events = append(events, event)
+	}))
+
+	ctx := WithVars(context.Background(), map[string]string{"WHICH": "GOOS"})
+	require.NoError(t, executor.ExecuteAll(ctx))
+	assert.Equal(t, "run-1", executor.RunID())
+
+	var types []EventType
+	for _, event := range events {
+		assert.Equal(t, "run-1", event.RunID)
+		assert.False(t, event.Time.IsZero())
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []EventType{
+		EventRunStarted,
+		EventTaskQueued, EventTaskQueued, EventTaskQueued,
+		EventTaskStarted, EventTaskOutput, EventTaskSucceeded,
+		EventTaskStarted, EventTaskRetrying, EventTaskStarted, EventTaskFailed,
+		EventTaskSkipped,
+		EventRunFinished,
+	}, types)
+
+	assert.Equal(t, runtime.GOOS, events[5].Line)
+	assert.Equal(t, 2, events[10].Attempt)
+	assert.Equal(t, StatusFailed, events[len(events)-1].Status)
+}
+
+func TestConsoleSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewConsoleSink(&out, true)
+
+	sink.Handle(Event{Type: EventTaskStarted, TaskName: "Build", Attempt: 1, MaxAttempts: 2})
+	sink.Handle(Event{Type: EventTaskOutput, TaskID: "build", Line: "compiling"})
+	sink.Handle(Event{Type: EventRunStarted})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "Executing task Build (attempt 1/2)...")
+	assert.Equal(t, "[build] compiling", lines[1])
+
+	out.Reset()
+	NewConsoleSink(&out, false).Handle(Event{Type: EventTaskOutput, TaskID: "build", Line: "compiling"})
+	assert.Empty(t, out.String())
+}
+
+func TestOutputSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewOutputSink(&out, []*Task{
+		{ID: "live", OutputMode: OutputStream},
+		{ID: "block", OutputMode: OutputBuffered},
+		{ID: "hidden", OutputMode: OutputNone},
+	}, OutputStream)
+
+	sink.Handle(Event{Type: EventTaskOutput, TaskID: "block", Line: "b1"})
+	sink.Handle(Event{Type: EventTaskOutput, TaskID: "live", Line: "l1"})
+	sink.Handle(Event{Type: EventTaskOutput, TaskID: "hidden", Line: "h1"})
+	sink.Handle(Event{Type: EventTaskOutput, TaskID: "other", Line: "o1"})
+	sink.Handle(Event{Type: EventTaskOutput, TaskID: "block", Line: "b2"})
+	assert.Equal(t, "[live] l1\n[other] o1\n", out.String())
+
+	out.Reset()
+	sink.Handle(Event{Type: EventTaskSucceeded, TaskID: "block"})
+	assert.Equal(t, "[block] b1\n[block] b2\n", out.String())
+}
+
+func TestExecutor_FailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy FailurePolicy
+		stops  bool
+	}{
+		{"", true},
+		{FailureStop, true},
+		{FailureContinue, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			executor := NewExecutor(1, false)
+			require.NoError(t, executor.AddTasks([]*Task{
+				{ID: "broken", Name: "Broken", Type: TaskTypeCommand, Command: "nonexistent-command-12345", OnFailure: tt.policy},
+				{ID: "other", Name: "Other", Type: TaskTypeCommand, Command: "go version"},
+				{ID: "dependent", Name: "Dependent", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"broken"}},
+			}))
+
+			err := executor.ExecuteAll(context.Background())
+			assert.ErrorContains(t, err, "task broken failed")
+
+			// The dependent task always comes after the failed one, so it
+			// only gets a (skipped) result when the run goes on
+			result, reached := executor.GetResult("dependent")
+			assert.Equal(t, !tt.stops, reached)
+			if reached {
+				assert.False(t, result.Success)
+				result, ok := executor.GetResult("other")
+				require.True(t, ok)
+				assert.True(t, result.Success)
+			}
+		})
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewJSONLinesSink(&out)
+
+	sink.Handle(Event{Type: EventTaskSucceeded, RunID: "r", TaskID: "a", ExitCode: 0})
+	sink.Handle(Event{Type: EventTaskFailed, RunID: "r", TaskID: "b", ExitCode: 2, Error: "exit status 2"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, EventTaskFailed, event.Type)
+	assert.Equal(t, 2, event.ExitCode)
+	assert.Equal(t, "exit status 2", event.Error)
+}
@@ -3,8 +3,13 @@ package task
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/yourusername/go-cli-tool/internal/secrets"
 )
 
 // Executor manages and executes tasks
@@ -14,6 +19,35 @@ type Executor struct {
 	mu          sync.RWMutex
 	concurrency int
 	verbose     bool
+
+	subMu       sync.Mutex
+	subscribers []Subscriber
+	runMu       sync.Mutex
+	runID       string
+	nextRunID   string
+	logger      *slog.Logger
+	tracer      Tracer
+	masker      *Masker
+	secrets     SecretResolver
+
+	// foreachChildren holds the IDs of the child tasks generated by each
+	// foreach task in its latest run
+	foreachChildren map[string][]string
+
+	// index holds the order tasks were added in, the tie-breaker between
+	// ready tasks
+	index        map[string]int
+	scheduleMode ScheduleMode
+	estimates    map[string]time.Duration
+	schedule     []ScheduleEntry
+	resources    map[string]int
+	lockDir      string
+	lockWait     bool
+	policy       *Policy
+	hooks        *RunHooks
+	hookResults  []HookResult
+	// cancels stops the in-flight execution of each task
+	cancels map[string]context.CancelFunc
 }
 
 // NewExecutor creates a new task executor
@@ -21,12 +55,147 @@ func NewExecutor(concurrency int, verbose bool) *Executor {
 	if concurrency <= 0 {
 		concurrency = 1
 	}
-	return &Executor{
+	e := &Executor{
 		tasks:       make(map[string]*Task),
 		results:     make(map[string]*TaskResult),
 		concurrency: concurrency,
 		verbose:     verbose,
+		masker:      NewMasker(),
+		secrets:     secrets.NewResolver(),
+
+		foreachChildren: make(map[string][]string),
+		index:           make(map[string]int),
+		cancels:         make(map[string]context.CancelFunc),
+		lockDir:         DefaultLockDir,
+		lockWait:        true,
+	}
+	if verbose {
+		e.Subscribe(NewConsoleSink(os.Stdout, false))
+	}
+	return e
+}
+
+// SetLogger sets the logger for executor and task records. Records carry
+// the run ID and, for task records, the task ID. Defaults to slog.Default().
+func (e *Executor) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+// log returns the executor's logger, masking secrets
+func (e *Executor) log() *slog.Logger {
+	logger := e.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return slog.New(&maskingHandler{handler: logger.Handler(), masker: e.masker})
+}
+
+// SetSecretResolver sets how secret:// references in task env values are
+// resolved. Defaults to the env and file providers.
+func (e *Executor) SetSecretResolver(resolver SecretResolver) {
+	e.secrets = resolver
+}
+
+// Masker returns the masker redacting secrets from the output, events and
+// logs of the executor's runs. Values added to it are masked from then on.
+func (e *Executor) Masker() *Masker {
+	return e.masker
+}
+
+// SetTracer wraps the following runs and their task attempts in spans
+func (e *Executor) SetTracer(tracer Tracer) {
+	e.tracer = tracer
+}
+
+// trace returns the executor's tracer
+func (e *Executor) trace() Tracer {
+	if e.tracer == nil {
+		return noopTracer{}
 	}
+	return e.tracer
+}
+
+// Subscribe registers a subscriber for the events of all following runs.
+// Subscribers must not call Subscribe themselves.
+func (e *Executor) Subscribe(s Subscriber) {
+	e.subMu.Lock()
+	defer e.subMu.Unlock()
+	e.subscribers = append(e.subscribers, s)
+}
+
+// SetRunID sets the ID of the next run instead of generating one
+func (e *Executor) SetRunID(id string) {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+	e.nextRunID = id
+}
+
+// RunID returns the ID of the current or most recent run
+func (e *Executor) RunID() string {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+	return e.runID
+}
+
+// emit delivers an event to all subscribers
+func (e *Executor) emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.RunID = e.RunID()
+	event.Line = e.masker.Mask(event.Line)
+	event.Error = e.masker.Mask(event.Error)
+	logEvent(e.log(), event)
+
+	e.subMu.Lock()
+	defer e.subMu.Unlock()
+	for _, s := range e.subscribers {
+		s.Handle(event)
+	}
+}
+
+// beginRun starts a new run of the given tasks
+func (e *Executor) beginRun(taskIDs []string) {
+	e.runMu.Lock()
+	e.runID = e.nextRunID
+	e.nextRunID = ""
+	if e.runID == "" {
+		e.runID = NewRunID()
+	}
+	e.runMu.Unlock()
+
+	e.emit(Event{Type: EventRunStarted, TaskIDs: taskIDs})
+	for _, id := range taskIDs {
+		e.mu.RLock()
+		task := e.tasks[id]
+		e.mu.RUnlock()
+		e.emit(Event{Type: EventTaskQueued, TaskID: id, TaskName: task.Name})
+	}
+}
+
+// endRun finishes the current run. The run failed if err is set or any of
+// its tasks did not succeed; the returned error reports either.
+func (e *Executor) endRun(taskIDs []string, start time.Time, err error) error {
+	event := Event{
+		Type:     EventRunFinished,
+		Status:   StatusCompleted,
+		Duration: time.Since(start),
+	}
+	for _, id := range taskIDs {
+		if result, ok := e.GetResult(id); !ok || !result.Success {
+			event.Status = StatusFailed
+		}
+	}
+	if err != nil {
+		event.Status = StatusFailed
+		event.Error = err.Error()
+	}
+	e.emit(event)
+
+	if err == nil && event.Status == StatusFailed {
+		err = fmt.Errorf("one or more tasks failed")
+	}
+	return err
 }
 
 // AddTask adds a task to the executor
@@ -43,6 +212,7 @@ func (e *Executor) AddTask(task *Task) error {
 	}
 
 	e.tasks[task.ID] = task
+	e.index[task.ID] = len(e.index)
 	return nil
 }
 
@@ -72,42 +242,77 @@ func (e *Executor) ExecuteAll(ctx context.Context) error {
 		return fmt.Errorf("failed to build execution order: %w", err)
 	}
 
-	// Execute tasks in order
-	for _, taskID := range executionOrder {
-		e.mu.RLock()
-		task := e.tasks[taskID]
-		e.mu.RUnlock()
+	// Tasks run by hooks only run as hooks
+	e.mu.RLock()
+	hooks := e.hooks
+	e.mu.RUnlock()
+	refs := hookReferences(hooks, e.taskList())
+	order := make([]string, 0, len(executionOrder))
+	for _, id := range executionOrder {
+		if !refs[id] {
+			order = append(order, id)
+		}
+	}
 
-		// Check if dependencies succeeded
-		if !e.checkDependencies(task) {
-			task.Status = StatusSkipped
-			e.mu.Lock()
-			e.results[taskID] = &TaskResult{
-				Task:    task,
-				Success: false,
-				Error:   fmt.Errorf("dependencies failed"),
-			}
-			e.mu.Unlock()
-			continue
+	return e.run(ctx, order)
+}
+
+// ExecuteTasks executes the given tasks in dependency order. Dependencies
+// outside the given set are not run again; their previous results decide
+// whether the dependent task may run.
+func (e *Executor) ExecuteTasks(ctx context.Context, taskIDs []string) error {
+	selected := make(map[string]bool, len(taskIDs))
+	e.mu.RLock()
+	for _, id := range taskIDs {
+		if _, exists := e.tasks[id]; !exists {
+			e.mu.RUnlock()
+			return fmt.Errorf("task %s not found", id)
 		}
+		selected[id] = true
+	}
+	e.mu.RUnlock()
 
-		// Execute task with retry
-		result := e.executeWithRetry(ctx, task)
+	if len(selected) == 0 {
+		return fmt.Errorf("no tasks to execute")
+	}
 
-		e.mu.Lock()
-		e.results[taskID] = result
-		e.mu.Unlock()
+	executionOrder, err := e.buildExecutionOrder()
+	if err != nil {
+		return fmt.Errorf("failed to build execution order: %w", err)
+	}
 
-		// Stop if task failed and no retry
-		if !result.Success && task.RetryCount == 0 {
-			return fmt.Errorf("task %s failed: %w", taskID, result.Error)
+	order := make([]string, 0, len(selected))
+	for _, id := range executionOrder {
+		if selected[id] {
+			order = append(order, id)
 		}
 	}
 
-	return nil
+	// Forget stale results so tasks that never get to run in this pass
+	// (e.g. because the run was cancelled) are not reported as done.
+	e.mu.Lock()
+	for _, id := range order {
+		delete(e.results, id)
+	}
+	e.mu.Unlock()
+
+	return e.run(ctx, order)
 }
 
-// ExecuteTask executes a specific task by ID
+// run executes the tasks in order as one run, between the run hooks
+func (e *Executor) run(ctx context.Context, order []string) error {
+	start := time.Now()
+	e.beginRun(order)
+	ctx, endSpan := e.trace().StartRun(ctx, e.RunID(), order)
+	err := e.withRunHooks(ctx, order, func(ctx context.Context) error {
+		return e.executeOrder(ctx, order)
+	})
+	endSpan(e.endRun(order, start, err))
+	return err
+}
+
+// ExecuteTask executes a specific task by ID, between the run hooks. The
+// error reports a task that was not found or a hook that failed.
 func (e *Executor) ExecuteTask(ctx context.Context, taskID string) (*TaskResult, error) {
 	e.mu.RLock()
 	task, exists := e.tasks[taskID]
@@ -117,13 +322,71 @@ func (e *Executor) ExecuteTask(ctx context.Context, taskID string) (*TaskResult,
 		return nil, fmt.Errorf("task %s not found", taskID)
 	}
 
-	result := e.executeWithRetry(ctx, task)
+	start := time.Now()
+	e.beginRun([]string{taskID})
+	ctx, endSpan := e.trace().StartRun(ctx, e.RunID(), []string{taskID})
+	err := e.withRunHooks(ctx, []string{taskID}, func(ctx context.Context) error {
+		result := e.execute(ctx, task)
+		e.mu.Lock()
+		e.results[taskID] = result
+		e.mu.Unlock()
+		return nil
+	})
+	result, _ := e.GetResult(taskID)
+	endSpan(e.endRun([]string{taskID}, start, err))
+
+	return result, err
+}
 
+// execute runs a task between its hooks, fanning it out first if it is a
+// foreach task. The task's lock, if any, is held for the whole execution.
+func (e *Executor) execute(ctx context.Context, task *Task) *TaskResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	e.mu.Lock()
-	e.results[taskID] = result
+	e.cancels[task.ID] = cancel
 	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.cancels, task.ID)
+		e.mu.Unlock()
+	}()
 
-	return result, nil
+	if task.Lock != "" {
+		lock, err := e.acquireTaskLock(ctx, task)
+		if err != nil {
+			result := failedResult(task, err)
+			e.emit(e.resultEvent(EventTaskFailed, result, 1, 1))
+			return result
+		}
+		defer func() {
+			if err := lock.Release(); err != nil {
+				e.log().Warn("failed to release task lock", "run_id", e.RunID(), "task_id", task.ID, "error", err)
+			}
+		}()
+	}
+	return e.withTaskHooks(ctx, task, func() *TaskResult {
+		if task.Foreach != nil {
+			return e.executeForeach(ctx, task)
+		}
+		return e.executeWithRetry(ctx, task)
+	})
+}
+
+// CancelTasks stops the in-flight executions of the given tasks, which fail
+// as cancelled, and reports whether any was running. The rest of the run
+// goes on as it would after any failure.
+func (e *Executor) CancelTasks(taskIDs []string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	canceled := false
+	for _, id := range taskIDs {
+		if cancel, ok := e.cancels[id]; ok {
+			cancel()
+			canceled = true
+		}
+	}
+	return canceled
 }
 
 // executeWithRetry executes a task with retry logic
@@ -131,39 +394,117 @@ func (e *Executor) executeWithRetry(ctx context.Context, task *Task) *TaskResult
 	var result *TaskResult
 	maxAttempts := task.RetryCount + 1
 
+	// A denial does not change between attempts, so it is not retried
+	if err := e.checkPolicy(ctx, task); err != nil {
+		result = failedResult(task, err)
+		e.emit(e.resultEvent(EventTaskFailed, result, 1, 1))
+		return result
+	}
+
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		if e.verbose {
-			fmt.Printf("[%s] Executing task %s (attempt %d/%d)...\n",
-				time.Now().Format("15:04:05"), task.Name, attempt, maxAttempts)
-		}
+		e.emit(Event{
+			Type:        EventTaskStarted,
+			TaskID:      task.ID,
+			TaskName:    task.Name,
+			Attempt:     attempt,
+			MaxAttempts: maxAttempts,
+		})
 
-		result = task.Execute(ctx)
+		result = e.executeAttempt(ctx, task, attempt)
 
 		if result.Success {
-			if e.verbose {
-				fmt.Printf("[%s] Task %s completed successfully (%.2fs)\n",
-					time.Now().Format("15:04:05"), task.Name, result.Duration.Seconds())
-			}
+			e.emit(e.resultEvent(EventTaskSucceeded, result, attempt, maxAttempts))
 			return result
 		}
 
 		if attempt < maxAttempts {
-			if e.verbose {
-				fmt.Printf("[%s] Task %s failed, retrying... (%v)\n",
-					time.Now().Format("15:04:05"), task.Name, result.Error)
+			e.emit(e.resultEvent(EventTaskRetrying, result, attempt, maxAttempts))
+			// Wait before retry
+			select {
+			case <-ctx.Done():
+				e.emit(e.resultEvent(EventTaskFailed, result, attempt, maxAttempts))
+				return result
+			case <-time.After(time.Second * 2):
 			}
-			time.Sleep(time.Second * 2) // Wait before retry
 		}
 	}
 
-	if e.verbose {
-		fmt.Printf("[%s] Task %s failed after %d attempts\n",
-			time.Now().Format("15:04:05"), task.Name, maxAttempts)
+	e.emit(e.resultEvent(EventTaskFailed, result, maxAttempts, maxAttempts))
+	return result
+}
+
+// checkPolicy checks the task against the executor's policy, if any
+func (e *Executor) checkPolicy(ctx context.Context, task *Task) error {
+	e.mu.RLock()
+	policy := e.policy
+	e.mu.RUnlock()
+	if policy == nil {
+		return nil
+	}
+	return policy.Check(ctx, task)
+}
+
+// executeAttempt runs the task once, turning its output into events
+func (e *Executor) executeAttempt(ctx context.Context, task *Task, attempt int) *TaskResult {
+	e.masker.Add(task.SecretValues(varsFrom(ctx))...)
+	secretEnv, err := resolveSecretEnv(ctx, e.secrets, task)
+	if err != nil {
+		return failedResult(task, err)
+	}
+	for _, value := range secretEnv {
+		e.masker.Add(value)
 	}
+	ctx = withSecretEnv(ctx, secretEnv)
 
+	lw := newLineWriter(&eventWriter{executor: e, task: task, attempt: attempt}, "")
+	logger := e.log().With("run_id", e.RunID(), "task_id", task.ID, "attempt", attempt)
+	ctx = WithLogger(withOutput(ctx, lw), logger)
+
+	traceparent, endSpan := e.trace().StartAttempt(ctx, task, attempt)
+	if traceparent != "" {
+		ctx = WithVars(ctx, map[string]string{TraceparentEnv: traceparent})
+	}
+
+	result := task.Execute(ctx)
+	_ = lw.Flush()
+
+	result.Output = e.masker.Mask(result.Output)
+	result.Error = e.masker.maskError(result.Error)
+	task.Output = result.Output
+	task.Error = e.masker.Mask(task.Error)
+	endSpan(result)
 	return result
 }
 
+// failedResult fails a task attempt that could not be started
+func failedResult(task *Task, err error) *TaskResult {
+	now := time.Now()
+	task.Status = StatusFailed
+	task.StartTime, task.EndTime = now, now
+	task.Error = err.Error()
+	return &TaskResult{Task: task, Error: err}
+}
+
+// resultEvent describes the outcome of a task attempt
+func (e *Executor) resultEvent(eventType EventType, result *TaskResult, attempt, maxAttempts int) Event {
+	event := Event{
+		Type:        eventType,
+		TaskID:      result.Task.ID,
+		TaskName:    result.Task.Name,
+		Attempt:     attempt,
+		MaxAttempts: maxAttempts,
+		Status:      result.Task.Status,
+		Duration:    result.Duration,
+		ExitCode:    result.ExitCode,
+
+		FailureReason: result.FailureReason,
+	}
+	if result.Error != nil {
+		event.Error = result.Error.Error()
+	}
+	return event
+}
+
 // checkDependencies checks if all dependencies completed successfully
 func (e *Executor) checkDependencies(task *Task) bool {
 	e.mu.RLock()
@@ -222,6 +563,39 @@ func (e *Executor) buildExecutionOrder() ([]string, error) {
 	return order, nil
 }
 
+// Dependents returns the given task IDs together with every task that
+// depends on them, directly or transitively.
+func (e *Executor) Dependents(taskIDs []string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	dependents := make(map[string][]string)
+	for id, task := range e.tasks {
+		for _, depID := range task.DependsOn {
+			dependents[depID] = append(dependents[depID], id)
+		}
+	}
+
+	seen := make(map[string]bool)
+	queue := append([]string{}, taskIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, dependents[id]...)
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // GetResults returns all task results
 func (e *Executor) GetResults() map[string]*TaskResult {
 	e.mu.RLock()
@@ -250,4 +624,7 @@ func (e *Executor) Clear() {
 
 	e.tasks = make(map[string]*Task)
 	e.results = make(map[string]*TaskResult)
+	e.index = make(map[string]int)
+	e.schedule = nil
+	e.hookResults = nil
 }
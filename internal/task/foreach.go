@@ -0,0 +1,259 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Foreach item formats
+const (
+	ForeachLines = "lines"
+	ForeachJSON  = "json"
+)
+
+// ForeachVarPrefix prefixes the run variables of a foreach child task:
+// ${foreach.item} and ${foreach.index}
+const ForeachVarPrefix = "foreach."
+
+// maxItemIDLength is the longest item used as is in a child task ID
+const maxItemIDLength = 64
+
+// Foreach fans a task out into one child task per item. The items come
+// from exactly one source, computed when the task is about to run.
+type Foreach struct {
+	// Items is a static list of items
+	Items []string `yaml:"items,omitempty" json:"items,omitempty"`
+	// Glob lists the matching paths, relative to the task's workdir
+	Glob string `yaml:"glob,omitempty" json:"glob,omitempty"`
+	// Command lists the items printed by a command, run like the task
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	// From lists the items printed by a task this task depends on
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+	// Format is how command and task output is split: lines (default) or a
+	// JSON array
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+// validate checks the foreach definition of task t
+func (f *Foreach) validate(t *Task) error {
+	sources := 0
+	for _, set := range []bool{f.Items != nil, f.Glob != "", f.Command != "", f.From != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("foreach needs exactly one of items, glob, command or from")
+	}
+	switch f.Format {
+	case "", ForeachLines, ForeachJSON:
+	default:
+		return fmt.Errorf("unknown foreach format: %s", f.Format)
+	}
+	if f.From != "" && !containsString(t.DependsOn, f.From) {
+		return fmt.Errorf("foreach from %s must be listed in depends_on", f.From)
+	}
+	return nil
+}
+
+// parseItems splits command or task output into items
+func (f *Foreach) parseItems(output string) ([]string, error) {
+	if f.Format == ForeachJSON {
+		var values []json.RawMessage
+		if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &values); err != nil {
+			return nil, fmt.Errorf("foreach output is not a JSON array: %w", err)
+		}
+		items := make([]string, len(values))
+		for i, value := range values {
+			var s string
+			if err := json.Unmarshal(value, &s); err == nil {
+				items[i] = s
+			} else {
+				items[i] = string(value)
+			}
+		}
+		return items, nil
+	}
+
+	var items []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
+
+// foreachItems computes the items of a foreach task
+func (e *Executor) foreachItems(ctx context.Context, t *Task) ([]string, error) {
+	f := t.Foreach
+	vars := varsFrom(WithVars(ctx, t.Vars))
+	switch {
+	case f.Items != nil:
+		items := make([]string, len(f.Items))
+		for i, item := range f.Items {
+			items[i] = expandVars(item, vars)
+		}
+		return items, nil
+
+	case f.Glob != "":
+		dir := expandVars(t.WorkDir, vars)
+		matches, err := filepath.Glob(filepath.Join(dir, expandVars(f.Glob, vars)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid foreach glob: %w", err)
+		}
+		items := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if dir != "" {
+				if rel, err := filepath.Rel(dir, match); err == nil {
+					match = rel
+				}
+			}
+			items = append(items, filepath.ToSlash(match))
+		}
+		sort.Strings(items)
+		return items, nil
+
+	case f.Command != "":
+		// Run the command like the task itself, so it sees the same
+		// shell, workdir and env; its output is part of the task's log
+		lister := t.Clone()
+		lister.Type = TaskTypeCommand
+		lister.Command = f.Command
+		lister.Args = nil
+		lister.Foreach = nil
+		if err := e.checkPolicy(ctx, lister); err != nil {
+			return nil, err
+		}
+		result := e.executeAttempt(ctx, lister, 1)
+		if !result.Success {
+			return nil, fmt.Errorf("foreach command failed: %w", result.Error)
+		}
+		return f.parseItems(result.Output)
+
+	default:
+		result, ok := e.GetResult(f.From)
+		if !ok || !result.Success {
+			return nil, fmt.Errorf("foreach task %s did not succeed", f.From)
+		}
+		return f.parseItems(result.Output)
+	}
+}
+
+// children returns one child task per item
+func (t *Task) children(items []string) []*Task {
+	counts := make(map[string]int, len(items))
+	for _, item := range items {
+		counts[item]++
+	}
+
+	children := make([]*Task, len(items))
+	for i, item := range items {
+		label := item
+		if counts[item] > 1 || item == "" || len(item) > maxItemIDLength || strings.ContainsAny(item, "[]\n") {
+			// Ambiguous or unwieldy items are numbered instead
+			label = "#" + strconv.Itoa(i)
+		}
+
+		child := t.Clone()
+		child.Foreach = nil
+		child.Group = t.ID
+		child.ID = t.ID + "[" + label + "]"
+		child.Name = strings.TrimSpace(t.Name + " [" + label + "]")
+		child.Vars = make(map[string]string, len(t.Vars)+2)
+		for k, v := range t.Vars {
+			child.Vars[k] = v
+		}
+		child.Vars[ForeachVarPrefix+"item"] = item
+		child.Vars[ForeachVarPrefix+"index"] = strconv.Itoa(i)
+		env := make(map[string]string, len(t.Env)+2)
+		for k, v := range t.Env {
+			env[k] = v
+		}
+		env["FOREACH_ITEM"] = item
+		env["FOREACH_INDEX"] = strconv.Itoa(i)
+		child.Env = env
+		children[i] = child
+	}
+	return children
+}
+
+// executeForeach computes the items of a foreach task and runs one child
+// task per item. Children have their own results; the task succeeds when
+// all of them do.
+func (e *Executor) executeForeach(ctx context.Context, t *Task) *TaskResult {
+	start := time.Now()
+	t.Status = StatusRunning
+	t.StartTime = start
+	e.emit(Event{Type: EventTaskStarted, TaskID: t.ID, TaskName: t.Name, Attempt: 1, MaxAttempts: 1})
+
+	result := &TaskResult{Task: t}
+	finish := func() *TaskResult {
+		t.EndTime = time.Now()
+		result.Duration = t.EndTime.Sub(start)
+		if result.Success {
+			t.Status = StatusCompleted
+			e.emit(e.resultEvent(EventTaskSucceeded, result, 1, 1))
+		} else {
+			t.Status = StatusFailed
+			t.Error = result.Error.Error()
+			e.emit(e.resultEvent(EventTaskFailed, result, 1, 1))
+		}
+		return result
+	}
+
+	items, err := e.foreachItems(ctx, t)
+	if err != nil {
+		result.Error = e.masker.maskError(err)
+		return finish()
+	}
+
+	children := t.children(items)
+	e.mu.Lock()
+	for _, id := range e.foreachChildren[t.ID] {
+		delete(e.results, id)
+	}
+	e.foreachChildren[t.ID] = nil
+	for _, child := range children {
+		e.foreachChildren[t.ID] = append(e.foreachChildren[t.ID], child.ID)
+	}
+	e.mu.Unlock()
+	for _, child := range children {
+		e.emit(Event{Type: EventTaskQueued, TaskID: child.ID, TaskName: child.Name})
+	}
+
+	failed := 0
+	for _, child := range children {
+		if ctx.Err() != nil {
+			break
+		}
+		childResult := e.executeWithRetry(ctx, child)
+		e.mu.Lock()
+		e.results[child.ID] = childResult
+		e.mu.Unlock()
+		if !childResult.Success {
+			failed++
+			if t.OnFailure == FailureStop {
+				break
+			}
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		result.Error = ctx.Err()
+	case failed > 0:
+		result.Error = fmt.Errorf("%d of %d items failed", failed, len(children))
+	default:
+		result.Success = true
+		result.Output = fmt.Sprintf("%d items\n", len(children))
+	}
+	t.Output = result.Output
+	return finish()
+}
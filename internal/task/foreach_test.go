@@ -0,0 +1,139 @@
+package task
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForeach_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		foreach Foreach
+		deps    []string
+		wantErr string
+	}{
+		{name: "items", foreach: Foreach{Items: []string{"a"}}},
+		{name: "from dependency", foreach: Foreach{From: "list", Format: ForeachJSON}, deps: []string{"list"}},
+		{name: "no source", foreach: Foreach{}, wantErr: "exactly one of"},
+		{name: "two sources", foreach: Foreach{Glob: "*", Command: "ls"}, wantErr: "exactly one of"},
+		{name: "unknown format", foreach: Foreach{Command: "ls", Format: "csv"}, wantErr: "unknown foreach format"},
+		{name: "from without dependency", foreach: Foreach{From: "list"}, wantErr: "must be listed in depends_on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			foreach := tt.foreach
+			task := &Task{ID: "t", Name: "T", Type: TaskTypeCommand, Command: "go version", DependsOn: tt.deps, Foreach: &foreach}
+			err := task.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForeach_ParseItems(t *testing.T) {
+	items, err := (&Foreach{}).parseItems("api\n\n  web \n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "web"}, items)
+
+	items, err = (&Foreach{Format: ForeachJSON}).parseItems(`["api", 2, {"name": "web"}]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "2", `{"name": "web"}`}, items)
+
+	_, err = (&Foreach{Format: ForeachJSON}).parseItems("api")
+	assert.ErrorContains(t, err, "not a JSON array")
+}
+
+func TestTask_Children(t *testing.T) {
+	task := &Task{ID: "deploy", Name: "Deploy", Env: map[string]string{"A": "1"}}
+	children := task.children([]string{"api", "web", "web", "a[1]"})
+	var ids []string
+	for _, child := range children {
+		ids = append(ids, child.ID)
+	}
+	assert.Equal(t, []string{"deploy[api]", "deploy[#1]", "deploy[#2]", "deploy[#3]"}, ids)
+	assert.Equal(t, "deploy", children[0].Group)
+	assert.Equal(t, "api", children[0].Vars["foreach.item"])
+	assert.Equal(t, "0", children[0].Vars["foreach.index"])
+	assert.Equal(t, map[string]string{"A": "1", "FOREACH_ITEM": "api", "FOREACH_INDEX": "0"}, children[0].Env)
+	assert.NotContains(t, task.Env, "FOREACH_ITEM")
+}
+
+func TestExecutor_Foreach(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.txt", "a.txt", "c.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "names", Name: "Names", Type: TaskTypeCommand, Command: "go env GOOS GOARCH"},
+		{
+			ID: "items", Name: "Items", Type: TaskTypeCommand,
+			Command: "go", Args: []string{"env", "${foreach.item}"},
+			DependsOn: []string{"names"},
+			Foreach:   &Foreach{Items: []string{"GOOS", "GOARCH"}},
+		},
+		{
+			ID: "files", Name: "Files", Type: TaskTypeCommand, Command: "go version", WorkDir: dir,
+			Foreach: &Foreach{Glob: "*.txt"},
+		},
+		{
+			ID: "listed", Name: "Listed", Type: TaskTypeCommand, Command: "go version",
+			Foreach: &Foreach{Command: "go env GOOS"},
+		},
+		{
+			ID: "upstream", Name: "Upstream", Type: TaskTypeCommand, Command: "go version",
+			DependsOn: []string{"names"},
+			Foreach:   &Foreach{From: "names"},
+		},
+	}))
+
+	var started []string
+	executor.Subscribe(SubscriberFunc(func(event Event) {
+		if event.Type == EventTaskStarted {
+			started = append(started, event.TaskID)
+		}
+	}))
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+
+	result, ok := executor.GetResult("items[GOOS]")
+	require.True(t, ok)
+	assert.Contains(t, result.Output, runtime.GOOS)
+	result, ok = executor.GetResult("items")
+	require.True(t, ok)
+	assert.True(t, result.Success)
+
+	for _, id := range []string{"files[a.txt]", "files[b.txt]", "listed[" + runtime.GOOS + "]", "upstream[" + runtime.GOARCH + "]"} {
+		result, ok := executor.GetResult(id)
+		require.True(t, ok, id)
+		assert.True(t, result.Success, id)
+		assert.Contains(t, started, id)
+	}
+	_, ok = executor.GetResult("files[c.log]")
+	assert.False(t, ok)
+}
+
+func TestExecutor_ForeachFailure(t *testing.T) {
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTask(&Task{
+		ID: "run", Name: "Run", Type: TaskTypeCommand, Command: "${foreach.item} version",
+		Foreach: &Foreach{Items: []string{"go", "nonexistent-command-12345"}},
+	}))
+
+	result, err := executor.ExecuteTask(context.Background(), "run")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.EqualError(t, result.Error, "1 of 2 items failed")
+	child, ok := executor.GetResult("run[go]")
+	require.True(t, ok)
+	assert.True(t, child.Success)
+}
@@ -0,0 +1,145 @@
+package task
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateDir is the workspace directory holding run history and other state
+const StateDir = ".task"
+
+// DefaultHistoryFile is where run history is recorded by default
+var DefaultHistoryFile = filepath.Join(StateDir, "history.jsonl")
+
+// Run triggers recorded in history
+const (
+	TriggerManual   = "manual"
+	TriggerSchedule = "schedule"
+	TriggerAPI      = "api"
+	TriggerWebhook  = "webhook"
+)
+
+// HistoryRecord describes one execution of a task
+type HistoryRecord struct {
+	RunID     string        `json:"run_id"`
+	TaskID    string        `json:"task_id"`
+	Trigger   string        `json:"trigger"`
+	Status    TaskStatus    `json:"status"`
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Duration  time.Duration `json:"duration"`
+	ExitCode  int           `json:"exit_code"`
+	Error     string        `json:"error,omitempty"`
+	// FailureReason tells why a failed task failed, if known
+	FailureReason FailureReason `json:"failure_reason,omitempty"`
+}
+
+// NewHistoryRecord builds a history record from a task result
+func NewHistoryRecord(runID, trigger string, result *TaskResult) HistoryRecord {
+	record := HistoryRecord{
+		RunID:     runID,
+		TaskID:    result.Task.ID,
+		Trigger:   trigger,
+		Status:    StatusCompleted,
+		StartTime: result.Task.StartTime,
+		EndTime:   result.Task.EndTime,
+		Duration:  result.Duration,
+		ExitCode:  result.ExitCode,
+
+		FailureReason: result.FailureReason,
+	}
+	if !result.Success {
+		record.Status = StatusFailed
+		if result.Task.Status == StatusSkipped {
+			record.Status = StatusSkipped
+		}
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	return record
+}
+
+// History is an append-only JSON-lines log of task executions
+type History struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewHistory creates a history backed by the given file
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// Append writes a record to the history file
+func (h *History) Append(record HistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0750); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+	return nil
+}
+
+// Records reads all records, oldest first. A missing file is an empty history.
+func (h *History) Records() ([]HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.Open(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse history record: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return records, nil
+}
+
+// NewRunID returns a unique, time-sortable identifier for a run
+func NewRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102-150405.000000")
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,465 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HookKind names when a hook runs
+type HookKind string
+
+const (
+	HookBeforeAll HookKind = "before_all" // Before the first task of a run
+	HookAfterAll  HookKind = "after_all"  // After a run, whatever its outcome
+	HookOnSuccess HookKind = "on_success" // After a run whose tasks all succeeded
+	HookOnFailure HookKind = "on_failure" // After a run that failed
+	HookBefore    HookKind = "before"     // Before a task runs
+	HookAfter     HookKind = "after"      // After a task succeeded
+	HookFinally   HookKind = "finally"    // After a task, whatever its outcome
+)
+
+// Hook is a step run by a hook: a command, or another task of the
+// configuration
+type Hook struct {
+	Command string   `yaml:"command,omitempty" json:"command,omitempty"`
+	Args    []string `yaml:"args,omitempty" json:"args,omitempty"`
+	Task    string   `yaml:"task,omitempty" json:"task,omitempty"`
+}
+
+// UnmarshalYAML reads a hook written as a plain command, or as a map
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*h = Hook{Command: node.Value}
+		return nil
+	}
+	type plain Hook
+	return node.Decode((*plain)(h))
+}
+
+func (h Hook) validate() error {
+	switch {
+	case h.Command == "" && h.Task == "":
+		return fmt.Errorf("hook needs a command or a task")
+	case h.Command != "" && h.Task != "":
+		return fmt.Errorf("hook has both a command and a task")
+	case h.Task != "" && len(h.Args) > 0:
+		return fmt.Errorf("hook running task %s cannot have args", h.Task)
+	}
+	return nil
+}
+
+// HookList is a list of hooks that can be written in YAML as a single hook
+type HookList []Hook
+
+// UnmarshalYAML accepts a single hook or a list
+func (l *HookList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var hooks []Hook
+		if err := node.Decode(&hooks); err != nil {
+			return err
+		}
+		*l = hooks
+		return nil
+	}
+	var hook Hook
+	if err := node.Decode(&hook); err != nil {
+		return err
+	}
+	*l = HookList{hook}
+	return nil
+}
+
+// RunHooks are the hooks of every run of a configuration
+type RunHooks struct {
+	BeforeAll HookList `yaml:"before_all,omitempty"`
+	AfterAll  HookList `yaml:"after_all,omitempty"`
+	OnSuccess HookList `yaml:"on_success,omitempty"`
+	OnFailure HookList `yaml:"on_failure,omitempty"`
+
+	// base is the task hook commands are copied from, carrying the
+	// defaults and env of the configuration
+	base *Task
+}
+
+// lists returns the hooks of each kind
+func (h *RunHooks) lists() map[HookKind]HookList {
+	if h == nil {
+		return nil
+	}
+	return map[HookKind]HookList{
+		HookBeforeAll: h.BeforeAll,
+		HookAfterAll:  h.AfterAll,
+		HookOnSuccess: h.OnSuccess,
+		HookOnFailure: h.OnFailure,
+	}
+}
+
+// baseTask returns the task hook commands are copied from
+func (h *RunHooks) baseTask() *Task {
+	if h.base == nil {
+		return &Task{ID: "hooks", Name: "hooks", Type: TaskTypeCommand, Status: StatusPending}
+	}
+	return h.base
+}
+
+// TaskHooks are the hooks of a task
+type TaskHooks struct {
+	Before  HookList `yaml:"before,omitempty" json:"before,omitempty"`
+	After   HookList `yaml:"after,omitempty" json:"after,omitempty"`
+	Finally HookList `yaml:"finally,omitempty" json:"finally,omitempty"`
+}
+
+// lists returns the hooks of each kind
+func (h *TaskHooks) lists() map[HookKind]HookList {
+	if h == nil {
+		return nil
+	}
+	return map[HookKind]HookList{
+		HookBefore:  h.Before,
+		HookAfter:   h.After,
+		HookFinally: h.Finally,
+	}
+}
+
+func (h *TaskHooks) validate() error {
+	for _, kind := range sortedHookKinds(h.lists()) {
+		for _, hook := range h.lists()[kind] {
+			if err := hook.validate(); err != nil {
+				return fmt.Errorf("%s hook: %w", kind, err)
+			}
+		}
+	}
+	return nil
+}
+
+// HookResult is the result of a hook that ran in a run
+type HookResult struct {
+	// ID names the hook, e.g. build:finally or after_all[2]
+	ID   string
+	Kind HookKind
+	// Owner is the ID of the task the hook belongs to; empty for run hooks
+	Owner  string
+	Hook   Hook
+	Result *TaskResult
+}
+
+// hookID names the i-th of n hooks of kind, of task owner or of the run
+func hookID(owner string, kind HookKind, i, n int) string {
+	id := string(kind)
+	if owner != "" {
+		id = owner + ":" + id
+	}
+	if n > 1 {
+		id += "[" + strconv.Itoa(i+1) + "]"
+	}
+	return id
+}
+
+// hookTask returns the task running the command of a hook: a copy of base,
+// which is the task the hook belongs to or the base of run hooks, running
+// the hook's command once
+func hookTask(base *Task, id string, hook Hook) *Task {
+	t := base.Clone()
+	t.ID, t.Name = id, id
+	t.Type = TaskTypeCommand
+	t.Command, t.Args = hook.Command, append([]string(nil), hook.Args...)
+	t.RetryCount = 0
+	t.DependsOn, t.Sources, t.Watch, t.Triggers, t.Schedule = nil, nil, nil, nil, ""
+	t.Uses, t.Exclusive, t.Lock = nil, false, ""
+	t.Foreach, t.Matrix, t.Hooks = nil, nil, nil
+	return t
+}
+
+// hookTargets returns the tasks a hook reference runs: the task with that
+// ID, or all instances of the matrix task with that ID
+func hookTargets(ref string, tasks []*Task) []*Task {
+	var instances []*Task
+	for _, t := range tasks {
+		if t.ID == ref {
+			return []*Task{t}
+		}
+		if t.Group == ref {
+			instances = append(instances, t)
+		}
+	}
+	return instances
+}
+
+// hookReferences returns the IDs of the tasks run by the hooks of the run
+// or of tasks
+func hookReferences(run *RunHooks, tasks []*Task) map[string]bool {
+	refs := make(map[string]bool)
+	add := func(lists map[HookKind]HookList) {
+		for _, hooks := range lists {
+			for _, hook := range hooks {
+				if hook.Task == "" {
+					continue
+				}
+				for _, t := range hookTargets(hook.Task, tasks) {
+					refs[t.ID] = true
+				}
+			}
+		}
+	}
+	add(run.lists())
+	for _, t := range tasks {
+		add(t.Hooks.lists())
+	}
+	return refs
+}
+
+// HookTaskIDs returns the IDs of the tasks run by hooks. They only run as
+// hooks when all tasks run.
+func (c *Config) HookTaskIDs() []string {
+	var ids []string
+	refs := hookReferences(c.Hooks, c.Tasks)
+	for _, t := range c.Tasks {
+		if refs[t.ID] {
+			ids = append(ids, t.ID)
+		}
+	}
+	return ids
+}
+
+// HookCommands returns the tasks running the command hooks of the run and
+// of every task, e.g. to check them against a policy
+func (c *Config) HookCommands() []*Task {
+	var tasks []*Task
+	add := func(owner string, base *Task, lists map[HookKind]HookList) {
+		for _, kind := range sortedHookKinds(lists) {
+			hooks := lists[kind]
+			for i, hook := range hooks {
+				if hook.Command != "" {
+					tasks = append(tasks, hookTask(base, hookID(owner, kind, i, len(hooks)), hook))
+				}
+			}
+		}
+	}
+	if c.Hooks != nil {
+		add("", c.Hooks.baseTask(), c.Hooks.lists())
+	}
+	for _, t := range c.Tasks {
+		add(t.ID, t, t.Hooks.lists())
+	}
+	return tasks
+}
+
+// validateHooks checks the run hooks and that hooks only reference tasks
+// that exist and that nothing depends on
+func (c *Config) validateHooks() error {
+	for _, kind := range sortedHookKinds(c.Hooks.lists()) {
+		for _, hook := range c.Hooks.lists()[kind] {
+			if err := hook.validate(); err != nil {
+				return fmt.Errorf("hooks.%s: %w", kind, err)
+			}
+			if hook.Task != "" && len(hookTargets(hook.Task, c.Tasks)) == 0 {
+				return fmt.Errorf("hooks.%s references non-existent task: %s", kind, hook.Task)
+			}
+		}
+	}
+	for _, t := range c.Tasks {
+		for _, kind := range sortedHookKinds(t.Hooks.lists()) {
+			for _, hook := range t.Hooks.lists()[kind] {
+				if hook.Task == "" {
+					continue
+				}
+				if hook.Task == t.ID || (t.Group != "" && hook.Task == t.Group) {
+					return fmt.Errorf("task %s: %s hook cannot run the task itself", t.ID, kind)
+				}
+				if len(hookTargets(hook.Task, c.Tasks)) == 0 {
+					return fmt.Errorf("task %s: %s hook references non-existent task: %s", t.ID, kind, hook.Task)
+				}
+			}
+		}
+	}
+
+	refs := hookReferences(c.Hooks, c.Tasks)
+	for _, t := range c.Tasks {
+		for _, dep := range t.DependsOn {
+			if refs[dep] && !refs[t.ID] {
+				return fmt.Errorf("task %s depends on %s, which only runs as a hook", t.ID, dep)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedHookKinds(lists map[HookKind]HookList) []HookKind {
+	kinds := make([]HookKind, 0, len(lists))
+	for kind := range lists {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// SetHooks sets the hooks of the following runs
+func (e *Executor) SetHooks(hooks *RunHooks) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hooks = hooks
+}
+
+// HookResults returns the results of the hooks of the latest run, in the
+// order they finished
+func (e *Executor) HookResults() []HookResult {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]HookResult(nil), e.hookResults...)
+}
+
+// taskList returns the executor's tasks in the order they were added
+func (e *Executor) taskList() []*Task {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	tasks := make([]*Task, 0, len(e.tasks))
+	for _, t := range e.tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return e.index[tasks[i].ID] < e.index[tasks[j].ID] })
+	return tasks
+}
+
+// withRunHooks runs body, the tasks ids of a run, between the run hooks.
+// If a before_all hook fails the tasks are skipped. The hooks after the
+// run also run when it was cancelled. The error of body is returned, or
+// else an error for the first hook of the run that failed.
+func (e *Executor) withRunHooks(ctx context.Context, ids []string, body func(context.Context) error) error {
+	e.mu.Lock()
+	hooks := e.hooks
+	e.hookResults = nil
+	e.mu.Unlock()
+
+	if hooks == nil {
+		return e.hookFailure(body(ctx))
+	}
+
+	err := e.runHooks(ctx, nil, HookBeforeAll, hooks.BeforeAll)
+	if err == nil {
+		err = body(ctx)
+	} else {
+		for _, id := range ids {
+			e.mu.RLock()
+			t := e.tasks[id]
+			e.mu.RUnlock()
+			e.skip(t, "before_all hook failed")
+		}
+	}
+
+	after := context.WithoutCancel(ctx)
+	succeeded := err == nil
+	for _, id := range ids {
+		if result, ok := e.GetResult(id); !ok || !result.Success {
+			succeeded = false
+		}
+	}
+	if succeeded {
+		e.runHooks(after, nil, HookOnSuccess, hooks.OnSuccess)
+	} else {
+		e.runHooks(after, nil, HookOnFailure, hooks.OnFailure)
+	}
+	e.runHooks(after, nil, HookAfterAll, hooks.AfterAll)
+	return e.hookFailure(err)
+}
+
+// hookFailure returns err, or else an error for the first hook of the run
+// that failed
+func (e *Executor) hookFailure(err error) error {
+	if err != nil {
+		return err
+	}
+	for _, hr := range e.HookResults() {
+		if !hr.Result.Success {
+			return fmt.Errorf("hook %s failed: %w", hr.ID, resultError(hr.Result))
+		}
+	}
+	return nil
+}
+
+// withTaskHooks runs body, the execution of task, between the task's
+// hooks. If a before hook fails the task fails without running. Its after
+// hooks run if it succeeded, its finally hooks in any case, even if the
+// run was cancelled. Their failures do not change the task's result.
+func (e *Executor) withTaskHooks(ctx context.Context, task *Task, body func() *TaskResult) *TaskResult {
+	if task.Hooks == nil {
+		return body()
+	}
+
+	var result *TaskResult
+	if err := e.runHooks(ctx, task, HookBefore, task.Hooks.Before); err != nil {
+		result = failedResult(task, err)
+		e.emit(e.resultEvent(EventTaskFailed, result, 1, 1))
+	} else {
+		result = body()
+		if result.Success {
+			e.runHooks(ctx, task, HookAfter, task.Hooks.After)
+		}
+	}
+	e.runHooks(context.WithoutCancel(ctx), task, HookFinally, task.Hooks.Finally)
+	return result
+}
+
+// runHooks runs hooks of kind in order, owned by task owner or, if nil, by
+// the run, and records their results. Before hooks stop at the first
+// failure, other hooks all run. It returns an error for the first hook
+// that failed.
+func (e *Executor) runHooks(ctx context.Context, owner *Task, kind HookKind, hooks HookList) error {
+	ownerID := ""
+	if owner != nil {
+		ownerID = owner.ID
+	}
+	var failure error
+	for i, hook := range hooks {
+		id := hookID(ownerID, kind, i, len(hooks))
+		for _, result := range e.runHook(ctx, owner, id, hook) {
+			e.mu.Lock()
+			e.hookResults = append(e.hookResults, HookResult{ID: id, Kind: kind, Owner: ownerID, Hook: hook, Result: result})
+			e.mu.Unlock()
+			if !result.Success && failure == nil {
+				failure = fmt.Errorf("hook %s failed: %w", id, resultError(result))
+			}
+		}
+		if failure != nil && (kind == HookBefore || kind == HookBeforeAll) {
+			break
+		}
+	}
+	return failure
+}
+
+// runHook runs one hook, returning the result of its command or of each
+// task it runs. Tasks run by a hook run without their own hooks.
+func (e *Executor) runHook(ctx context.Context, owner *Task, id string, hook Hook) []*TaskResult {
+	if hook.Task == "" {
+		base := owner
+		if base == nil {
+			e.mu.RLock()
+			base = e.hooks.baseTask()
+			e.mu.RUnlock()
+		}
+		return []*TaskResult{e.executeWithRetry(ctx, hookTask(base, id, hook))}
+	}
+
+	targets := hookTargets(hook.Task, e.taskList())
+	if len(targets) == 0 {
+		return []*TaskResult{failedResult(&Task{ID: hook.Task, Name: hook.Task}, fmt.Errorf("task %s not found", hook.Task))}
+	}
+	results := make([]*TaskResult, 0, len(targets))
+	for _, target := range targets {
+		t := target.Clone()
+		t.Hooks = nil
+		results = append(results, e.execute(ctx, t))
+	}
+	return results
+}
+
+// resultError returns the error of a failed result
+func resultError(result *TaskResult) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	return fmt.Errorf("task %s failed", result.Task.ID)
+}
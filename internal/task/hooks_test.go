@@ -0,0 +1,178 @@
+package task
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_Hooks(t *testing.T) {
+	config, err := loadConfigString(t, `version: "1.0"
+defaults:
+  env:
+    STAGE: test
+hooks:
+  before_all: go version
+  after_all:
+    - task: cleanup
+    - command: go
+      args: [env, GOOS]
+tasks:
+  - id: build
+    name: Build
+    type: command
+    command: go version
+    hooks:
+      finally: go env GOARCH
+  - id: cleanup
+    name: Cleanup
+    type: command
+    command: go env GOCACHE
+`)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+
+	assert.Equal(t, HookList{{Command: "go version"}}, config.Hooks.BeforeAll)
+	assert.Equal(t, HookList{{Task: "cleanup"}, {Command: "go", Args: []string{"env", "GOOS"}}}, config.Hooks.AfterAll)
+	assert.Equal(t, HookList{{Command: "go env GOARCH"}}, config.Tasks[0].Hooks.Finally)
+	assert.Equal(t, []string{"cleanup"}, config.HookTaskIDs())
+
+	commands := config.HookCommands()
+	require.Len(t, commands, 3)
+	assert.Equal(t, "after_all[2]", commands[0].ID)
+	assert.Equal(t, "before_all", commands[1].ID)
+	assert.Equal(t, "test", commands[1].Env["STAGE"])
+	assert.Equal(t, "build:finally", commands[2].ID)
+}
+
+func TestConfig_ValidateHooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		runHooks *RunHooks
+		hooks    *TaskHooks
+		deps     []string
+		wantErr  string
+	}{
+		{name: "valid", runHooks: &RunHooks{AfterAll: HookList{{Task: "cleanup"}}}, hooks: &TaskHooks{Before: HookList{{Command: "go version"}}}},
+		{name: "empty", hooks: &TaskHooks{After: HookList{{}}}, wantErr: "after hook: hook needs a command or a task"},
+		{name: "command and task", runHooks: &RunHooks{BeforeAll: HookList{{Command: "go version", Task: "cleanup"}}}, wantErr: "hooks.before_all: hook has both a command and a task"},
+		{name: "task with args", hooks: &TaskHooks{Finally: HookList{{Task: "cleanup", Args: []string{"x"}}}}, wantErr: "cannot have args"},
+		{name: "missing task", runHooks: &RunHooks{OnFailure: HookList{{Task: "missing"}}}, wantErr: "hooks.on_failure references non-existent task: missing"},
+		{name: "itself", hooks: &TaskHooks{Finally: HookList{{Task: "build"}}}, wantErr: "task build: finally hook cannot run the task itself"},
+		{name: "depends on hook task", runHooks: &RunHooks{AfterAll: HookList{{Task: "cleanup"}}}, deps: []string{"cleanup"}, wantErr: "task build depends on cleanup, which only runs as a hook"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Version: "1.0",
+				Hooks:   tt.runHooks,
+				Tasks: []*Task{
+					{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version", Hooks: tt.hooks, DependsOn: tt.deps},
+					{ID: "cleanup", Name: "Cleanup", Type: TaskTypeCommand, Command: "go version"},
+				},
+			}
+			err := config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func hookIDs(results []HookResult) []string {
+	ids := make([]string, len(results))
+	for i, hr := range results {
+		ids[i] = hr.ID
+	}
+	return ids
+}
+
+func TestExecutor_RunHooks(t *testing.T) {
+	executor := NewExecutor(1, false)
+	executor.SetHooks(&RunHooks{
+		BeforeAll: HookList{{Command: "go version"}},
+		OnSuccess: HookList{{Task: "cleanup"}},
+		OnFailure: HookList{{Command: "go env GOOS"}},
+		AfterAll:  HookList{{Command: "go nosuchcommand"}, {Command: "go env GOARCH"}},
+	})
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "cleanup", Name: "Cleanup", Type: TaskTypeCommand, Command: "go env GOCACHE"},
+	}))
+
+	err := executor.ExecuteAll(context.Background())
+	assert.ErrorContains(t, err, "hook after_all[1] failed")
+
+	results := executor.GetResults()
+	assert.Len(t, results, 1, "tasks run by hooks only run as hooks")
+	assert.True(t, results["build"].Success)
+
+	hooks := executor.HookResults()
+	assert.Equal(t, []string{"before_all", "on_success", "after_all[1]", "after_all[2]"}, hookIDs(hooks))
+	assert.Equal(t, "cleanup", hooks[1].Result.Task.ID)
+	assert.True(t, hooks[1].Result.Success)
+	assert.False(t, hooks[2].Result.Success)
+	assert.True(t, hooks[3].Result.Success, "after_all hooks all run")
+}
+
+func TestExecutor_BeforeAllFails(t *testing.T) {
+	executor := NewExecutor(1, false)
+	executor.SetHooks(&RunHooks{
+		BeforeAll: HookList{{Command: "go nosuchcommand"}, {Command: "go version"}},
+		OnSuccess: HookList{{Command: "go env GOOS"}},
+		OnFailure: HookList{{Command: "go env GOARCH"}},
+	})
+	require.NoError(t, executor.AddTask(&Task{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version"}))
+
+	result, err := executor.ExecuteTask(context.Background(), "build")
+	assert.ErrorContains(t, err, "hook before_all[1] failed")
+	require.NotNil(t, result)
+	assert.False(t, result.Success)
+	assert.Equal(t, StatusSkipped, result.Task.Status)
+	assert.Equal(t, []string{"before_all[1]", "on_failure"}, hookIDs(executor.HookResults()))
+}
+
+func TestExecutor_TaskHooks(t *testing.T) {
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{
+			ID: "ok", Name: "OK", Type: TaskTypeCommand, Command: "go version",
+			Hooks: &TaskHooks{After: HookList{{Command: "go nosuchcommand"}}, Finally: HookList{{Command: "go env GOOS"}}},
+		},
+		{
+			ID: "blocked", Name: "Blocked", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"ok"}, OnFailure: FailureContinue,
+			Hooks: &TaskHooks{Before: HookList{{Command: "go nosuchcommand"}}, Finally: HookList{{Task: "cleanup"}}},
+		},
+		{
+			ID: "failing", Name: "Failing", Type: TaskTypeCommand, Command: "go nosuchcommand", OnFailure: FailureContinue,
+			Hooks: &TaskHooks{After: HookList{{Command: "go version"}}, Finally: HookList{{Command: "go env GOARCH"}}},
+		},
+		{ID: "cleanup", Name: "Cleanup", Type: TaskTypeCommand, Command: "go env GOCACHE"},
+	}))
+
+	err := executor.ExecuteTasks(context.Background(), []string{"ok", "blocked", "failing"})
+	assert.Error(t, err)
+
+	ok, _ := executor.GetResult("ok")
+	assert.True(t, ok.Success, "a failed after hook does not fail the task")
+	blocked, _ := executor.GetResult("blocked")
+	assert.False(t, blocked.Success)
+	assert.ErrorContains(t, blocked.Error, "hook blocked:before failed")
+	assert.Empty(t, blocked.Output, "the task does not run when a before hook fails")
+
+	byID := make(map[string]HookResult)
+	for _, hr := range executor.HookResults() {
+		byID[hr.ID] = hr
+	}
+	assert.Contains(t, byID, "ok:after")
+	assert.Contains(t, byID, "ok:finally")
+	assert.Equal(t, "cleanup", byID["blocked:finally"].Result.Task.ID)
+	assert.NotContains(t, byID, "failing:after", "after hooks only run on success")
+	assert.Contains(t, byID, "failing:finally")
+	assert.Equal(t, HookFinally, byID["failing:finally"].Kind)
+	assert.Equal(t, "failing", byID["failing:finally"].Owner)
+}
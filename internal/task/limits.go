@@ -0,0 +1,130 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is an amount of memory in bytes, written in YAML as a number of
+// bytes or with a unit, e.g. 512M, 2GiB
+type ByteSize int64
+
+// byteUnits maps unit suffixes to their size; K, M and G are binary units
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseByteSize parses a size such as 1048576, 512M or 2GiB
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	size := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(unit.suffix)) {
+			s = strings.TrimSpace(s[:len(s)-len(unit.suffix)])
+			size = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return ByteSize(n * float64(size)), nil
+}
+
+// UnmarshalYAML reads a number of bytes or a size with a unit
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	size, err := ParseByteSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*b = size
+	return nil
+}
+
+// MarshalYAML writes the size in the largest unit that divides it
+func (b ByteSize) MarshalYAML() (interface{}, error) {
+	return b.String(), nil
+}
+
+// String formats the size, e.g. 512MiB
+func (b ByteSize) String() string {
+	for i := 3; i >= 0; i-- {
+		unit := byteUnits[i]
+		if int64(b) >= unit.size && int64(b)%unit.size == 0 {
+			return strconv.FormatInt(int64(b)/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// Limits caps the resources a task's processes may use. On Linux they are
+// applied as rlimits and, where cgroup v2 is available and delegated, by a
+// cgroup per task; other platforms ignore them.
+type Limits struct {
+	// Memory caps the memory of the task
+	Memory ByteSize `yaml:"memory,omitempty" json:"memory,omitempty"`
+	// CPUTime caps the CPU time of each process of the task
+	CPUTime time.Duration `yaml:"cpu_time,omitempty" json:"cpu_time,omitempty"`
+	// OpenFiles caps the open files of each process of the task
+	OpenFiles int `yaml:"open_files,omitempty" json:"open_files,omitempty"`
+	// Processes caps the number of processes of the task
+	Processes int `yaml:"processes,omitempty" json:"processes,omitempty"`
+}
+
+// validate checks that no limit is negative
+func (l *Limits) validate() error {
+	switch {
+	case l.Memory < 0:
+		return fmt.Errorf("memory limit must not be negative")
+	case l.CPUTime < 0:
+		return fmt.Errorf("cpu_time limit must not be negative")
+	case l.OpenFiles < 0:
+		return fmt.Errorf("open_files limit must not be negative")
+	case l.Processes < 0:
+		return fmt.Errorf("processes limit must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set
+func (l *Limits) IsZero() bool {
+	return l == nil || *l == Limits{}
+}
+
+// FailureReason tells why a task failed, when it is more specific than its
+// exit status
+type FailureReason string
+
+const (
+	ReasonMemoryLimit    FailureReason = "memory_limit"    // Killed for exceeding its memory limit
+	ReasonCPUTimeLimit   FailureReason = "cpu_time_limit"  // Killed for exceeding its CPU time limit
+	ReasonProcessesLimit FailureReason = "processes_limit" // Failed to start processes past its limit
+)
+
+// LimitError reports a task that failed because it hit one of its limits
+type LimitError struct {
+	Reason FailureReason
+	// Limit is the value of the limit, e.g. "512MiB"
+	Limit string
+	Err   error
+}
+
+func (e *LimitError) Error() string {
+	name := strings.TrimSuffix(string(e.Reason), "_limit")
+	return fmt.Sprintf("exceeded %s limit of %s: %v", strings.ReplaceAll(name, "_", " "), e.Limit, e.Err)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,366 @@
+//go:build linux
+
+package task
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupSeq numbers the cgroups created by this process
+var cgroupSeq atomic.Int64
+
+// cgroupControllers are the controllers the task cgroups use
+var cgroupControllers = []string{"memory", "pids"}
+
+// taskCgroupParent is the cgroup task cgroups are created in, set up once
+var taskCgroupParent struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// rlimitHelper is the argv[0] of a re-executed go-cli-tool process that sets
+// rlimits and then executes the task's command in its place, so the limits
+// hold from the command's first instruction, for every process it forks:
+//
+//	go-cli-tool-rlimit <resource>=<soft>:<hard>,... <path> <argv...>
+const rlimitHelper = "go-cli-tool-rlimit"
+
+// rlimitResources maps the resource names used by rlimitHelper
+var rlimitResources = map[string]int{
+	"cpu":    unix.RLIMIT_CPU,
+	"nofile": unix.RLIMIT_NOFILE,
+}
+
+func init() {
+	if len(os.Args) > 3 && os.Args[0] == rlimitHelper {
+		runRlimitHelper(os.Args[1], os.Args[2], os.Args[3:])
+	}
+}
+
+// runRlimitHelper sets the rlimits in spec and executes path with argv. It
+// only returns by exiting if that fails.
+func runRlimitHelper(spec, path string, argv []string) {
+	runtime.LockOSThread()
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "go-cli-tool: %v\n", err)
+		os.Exit(126)
+	}
+
+	type rlimit struct {
+		name     string
+		resource int
+		value    syscall.Rlimit
+	}
+	var limits []rlimit
+	for _, entry := range strings.Split(spec, ",") {
+		name, values, _ := strings.Cut(entry, "=")
+		soft, hard, _ := strings.Cut(values, ":")
+		resource, ok := rlimitResources[name]
+		cur, err1 := strconv.ParseUint(soft, 10, 64)
+		max, err2 := strconv.ParseUint(hard, 10, 64)
+		if !ok || err1 != nil || err2 != nil {
+			fail(fmt.Errorf("invalid rlimit %q", entry))
+		}
+		limits = append(limits, rlimit{name, resource, syscall.Rlimit{Cur: cur, Max: max}})
+	}
+
+	// Everything exec needs is allocated before the limits are set
+	pathp, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		fail(err)
+	}
+	argvp, err := syscall.SlicePtrFromStrings(argv)
+	if err != nil {
+		fail(err)
+	}
+	envp, err := syscall.SlicePtrFromStrings(os.Environ())
+	if err != nil {
+		fail(err)
+	}
+
+	for i := range limits {
+		if err := syscall.Setrlimit(limits[i].resource, &limits[i].value); err != nil {
+			fail(fmt.Errorf("failed to set %s limit: %w", limits[i].name, err))
+		}
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(pathp)), uintptr(unsafe.Pointer(&argvp[0])), uintptr(unsafe.Pointer(&envp[0])))
+	fail(fmt.Errorf("failed to execute %s: %w", path, errno))
+}
+
+// limiter applies a task's limits to its process and tells limit breaches
+// from other failures
+type limiter struct {
+	limits *Limits
+	logger *slog.Logger
+
+	// cgroup is the directory of the task's cgroup, if one was created
+	cgroup   string
+	cgroupFD int
+}
+
+// newLimiter prepares the limits of task t, or returns nil if it has none.
+// The memory and processes limits need a cgroup; without one they are not
+// enforced.
+func newLimiter(t *Task, logger *slog.Logger) *limiter {
+	if t.Limits.IsZero() {
+		return nil
+	}
+	l := &limiter{limits: t.Limits, logger: logger, cgroupFD: -1}
+	if t.Limits.Memory > 0 || t.Limits.Processes > 0 {
+		if err := l.createCgroup(); err != nil {
+			logger.Warn("memory and processes limits are not enforced without a cgroup v2 delegated to go-cli-tool", "error", err)
+		}
+	}
+	return l
+}
+
+// setupCgroupParent finds the cgroup v2 of process pid under root and makes
+// the memory and pids controllers available to its children. Controllers can
+// only be enabled in a cgroup that holds no processes, so unless they already
+// are, the process first moves into a leaf child of its cgroup. A cgroup
+// shared with other processes is left alone.
+func setupCgroupParent(root, procCgroup string, pid int) (string, error) {
+	data, err := os.ReadFile(procCgroup)
+	if err != nil {
+		return "", err
+	}
+	var parent string
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			parent = filepath.Join(root, path)
+		}
+	}
+	if parent == "" {
+		return "", fmt.Errorf("no cgroup v2 hierarchy")
+	}
+
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return "", err
+	}
+	available, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+	var enable []string
+	for _, controller := range cgroupControllers {
+		if containsString(strings.Fields(string(available)), controller) &&
+			!containsString(strings.Fields(string(enabled)), controller) {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) == 0 {
+		return parent, nil
+	}
+
+	procs, err := os.ReadFile(filepath.Join(parent, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	for _, proc := range strings.Fields(string(procs)) {
+		if proc != strconv.Itoa(pid) {
+			return "", fmt.Errorf("cgroup %s is shared with other processes", parent)
+		}
+	}
+	leaf := filepath.Join(parent, "go-cli-tool")
+	if err := os.Mkdir(leaf, 0750); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0); err != nil {
+		return "", fmt.Errorf("failed to move into %s: %w", leaf, err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0); err != nil {
+		return "", fmt.Errorf("failed to enable controllers: %w", err)
+	}
+	return parent, nil
+}
+
+// createCgroup creates a cgroup for the task holding the memory and
+// processes limits
+func (l *limiter) createCgroup() error {
+	taskCgroupParent.once.Do(func() {
+		taskCgroupParent.dir, taskCgroupParent.err = setupCgroupParent(cgroupRoot, "/proc/self/cgroup", os.Getpid())
+	})
+	parent, err := taskCgroupParent.dir, taskCgroupParent.err
+	if err != nil {
+		return err
+	}
+
+	var controllers []string
+	if l.limits.Memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if l.limits.Processes > 0 {
+		controllers = append(controllers, "pids")
+	}
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	for _, controller := range controllers {
+		if !containsString(strings.Fields(string(enabled)), controller) {
+			return fmt.Errorf("%s controller is not available in %s", controller, parent)
+		}
+	}
+
+	dir := filepath.Join(parent, fmt.Sprintf("go-cli-tool-%d-%d", os.Getpid(), cgroupSeq.Add(1)))
+	if err := os.Mkdir(dir, 0750); err != nil {
+		return err
+	}
+	l.cgroup = dir
+	settings := map[string]string{}
+	if l.limits.Memory > 0 {
+		settings["memory.max"] = strconv.FormatInt(int64(l.limits.Memory), 10)
+		settings["memory.swap.max"] = "0"
+	}
+	if l.limits.Processes > 0 {
+		settings["pids.max"] = strconv.Itoa(l.limits.Processes)
+	}
+	for name, value := range settings {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0)
+		if err != nil && name != "memory.swap.max" {
+			l.close()
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		l.close()
+		return err
+	}
+	l.cgroupFD = fd
+	return nil
+}
+
+// prepare makes cmd start in the task's cgroup and, if the task needs
+// rlimits, run through rlimitHelper so they are set before it starts
+func (l *limiter) prepare(cmd *exec.Cmd) {
+	if l == nil {
+		return
+	}
+	if l.cgroupFD >= 0 {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = l.cgroupFD
+	}
+
+	spec := l.rlimits()
+	if spec == "" || cmd.Err != nil {
+		return
+	}
+	// A missing program is left to fail to start as it would without limits
+	path := cmd.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cmd.Dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	cmd.Args = append([]string{rlimitHelper, spec, cmd.Path}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+}
+
+// rlimits returns the rlimitHelper spec of the limits set as rlimits, or ""
+// if there are none
+func (l *limiter) rlimits() string {
+	var spec []string
+	add := func(name string, soft, hard uint64) {
+		spec = append(spec, fmt.Sprintf("%s=%d:%d", name, soft, hard))
+	}
+	if l.limits.CPUTime > 0 {
+		// The process gets SIGXCPU at the soft limit and is killed a second later
+		seconds := uint64((l.limits.CPUTime + time.Second - 1) / time.Second)
+		add("cpu", seconds, seconds+1)
+	}
+	if l.limits.OpenFiles > 0 {
+		add("nofile", uint64(l.limits.OpenFiles), uint64(l.limits.OpenFiles))
+	}
+	return strings.Join(spec, ",")
+}
+
+// breach returns the limit the finished process exceeded, if any
+func (l *limiter) breach(state *os.ProcessState, err error) *LimitError {
+	if l == nil || state == nil {
+		return nil
+	}
+	if l.cgroup != "" {
+		if l.limits.Memory > 0 && cgroupEvent(l.cgroup, "memory.events", "oom_kill") > 0 {
+			return &LimitError{Reason: ReasonMemoryLimit, Limit: l.limits.Memory.String(), Err: err}
+		}
+		if l.limits.Processes > 0 && cgroupEvent(l.cgroup, "pids.events", "max") > 0 {
+			return &LimitError{Reason: ReasonProcessesLimit, Limit: strconv.Itoa(l.limits.Processes), Err: err}
+		}
+	}
+	if l.limits.CPUTime > 0 {
+		status, _ := state.Sys().(syscall.WaitStatus)
+		used := state.UserTime() + state.SystemTime()
+		xcpu := status.Signaled() && status.Signal() == syscall.SIGXCPU
+		// A shell reports a child killed by SIGXCPU as exit status 128+SIGXCPU
+		xcpu = xcpu || (status.Exited() && status.ExitStatus() == 128+int(syscall.SIGXCPU))
+		killed := status.Signaled() && status.Signal() == syscall.SIGKILL && used >= l.limits.CPUTime
+		if xcpu || killed {
+			return &LimitError{Reason: ReasonCPUTimeLimit, Limit: l.limits.CPUTime.String(), Err: err}
+		}
+	}
+	return nil
+}
+
+// close removes the task's cgroup, killing processes left in it
+func (l *limiter) close() {
+	if l == nil || l.cgroup == "" {
+		return
+	}
+	if l.cgroupFD >= 0 {
+		unix.Close(l.cgroupFD)
+		l.cgroupFD = -1
+	}
+	os.WriteFile(filepath.Join(l.cgroup, "cgroup.kill"), []byte("1"), 0)
+	for i := 0; i < 50; i++ {
+		if err := os.Remove(l.cgroup); err == nil || os.IsNotExist(err) {
+			l.cgroup = ""
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.logger.Warn("failed to remove task cgroup", "cgroup", l.cgroup)
+}
+
+// cgroupEvent reads a counter from a cgroup events file
+func cgroupEvent(dir, file, key string) int {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
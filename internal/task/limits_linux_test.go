@@ -0,0 +1,203 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_ExecuteLimits(t *testing.T) {
+	task := &Task{
+		ID: "limits", Name: "Limits", Type: TaskTypeCommand, Shell: "sh",
+		Command: "ulimit -n",
+		Limits:  &Limits{OpenFiles: 64},
+	}
+	result := task.Execute(context.Background())
+	require.NoError(t, result.Error)
+	assert.Equal(t, "64", strings.TrimSpace(result.Output))
+}
+
+func TestTask_ExecuteLimitsForkedChild(t *testing.T) {
+	// The child is forked before the task could do anything else, and must
+	// still start with the limits, as must the command itself
+	task := &Task{
+		ID: "fork", Name: "Fork", Type: TaskTypeCommand, Command: "sh",
+		Args:   []string{"-c", "sh -c 'ulimit -n; ulimit -t' & wait"},
+		Limits: &Limits{OpenFiles: 48, CPUTime: 5 * time.Second},
+	}
+	result := task.Execute(context.Background())
+	require.NoError(t, result.Error)
+	assert.Equal(t, "48\n5", strings.TrimSpace(result.Output))
+}
+
+func TestTask_ExecuteLimitsMissingProgram(t *testing.T) {
+	task := &Task{
+		ID: "missing", Name: "Missing", Type: TaskTypeCommand, Command: "./no-such-program",
+		WorkDir: t.TempDir(),
+		Limits:  &Limits{OpenFiles: 64},
+	}
+	result := task.Execute(context.Background())
+	require.Error(t, result.Error)
+	assert.ErrorContains(t, result.Error, "no such file or directory")
+}
+
+func TestTask_ExecuteCPUTimeLimit(t *testing.T) {
+	task := &Task{
+		ID: "spin", Name: "Spin", Type: TaskTypeCommand, Shell: "sh",
+		Command: "while :; do :; done",
+		Timeout: 30 * time.Second,
+		Limits:  &Limits{CPUTime: time.Second},
+	}
+	result := task.Execute(context.Background())
+	require.Error(t, result.Error)
+	assert.Equal(t, ReasonCPUTimeLimit, result.FailureReason)
+	var limitErr *LimitError
+	require.True(t, errors.As(result.Error, &limitErr))
+	assert.Equal(t, "1s", limitErr.Limit)
+	assert.Contains(t, result.Error.Error(), "exceeded cpu time limit of 1s")
+}
+
+func TestTask_ExecuteWithoutLimits(t *testing.T) {
+	task := &Task{ID: "fail", Name: "Fail", Type: TaskTypeCommand, Shell: "sh", Command: "exit 3"}
+	result := task.Execute(context.Background())
+	require.Error(t, result.Error)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Empty(t, result.FailureReason)
+}
+
+func TestLimiter_RlimitsLeaveMemoryAndProcessesToCgroups(t *testing.T) {
+	l := &limiter{limits: &Limits{Memory: 1 << 30, Processes: 10, OpenFiles: 64}, cgroupFD: -1}
+	assert.Equal(t, "nofile=64:64", l.rlimits())
+}
+
+func TestLimiter_WarnsWithoutCgroup(t *testing.T) {
+	if _, err := setupCgroupParent(cgroupRoot, "/proc/self/cgroup", os.Getpid()); err == nil {
+		t.Skip("cgroup v2 is delegated to this process")
+	}
+	var out bytes.Buffer
+	task := &Task{ID: "mem", Name: "Mem", Limits: &Limits{Memory: 1 << 30}}
+	l := newLimiter(task, slog.New(slog.NewTextHandler(&out, nil)))
+	require.NotNil(t, l)
+	assert.Contains(t, out.String(), "memory and processes limits are not enforced")
+}
+
+// fakeCgroup creates a directory laid out like a cgroup v2 with files
+func fakeCgroup(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestSetupCgroupParent(t *testing.T) {
+	pid := os.Getpid()
+	procCgroup := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(procCgroup, []byte("0::/\n"), 0600))
+
+	// Controllers already enabled are used as they are
+	root := fakeCgroup(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids", "cgroup.subtree_control": "memory pids",
+		"cgroup.procs": "1\n" + strconv.Itoa(pid) + "\n",
+	})
+	parent, err := setupCgroupParent(root, procCgroup, pid)
+	require.NoError(t, err)
+	assert.Equal(t, root, parent)
+	assert.NoDirExists(t, filepath.Join(root, "go-cli-tool"))
+
+	// A cgroup shared with other processes is left alone
+	root = fakeCgroup(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids", "cgroup.subtree_control": "",
+		"cgroup.procs": "1\n" + strconv.Itoa(pid) + "\n",
+	})
+	_, err = setupCgroupParent(root, procCgroup, pid)
+	assert.ErrorContains(t, err, "shared with other processes")
+	data, err := os.ReadFile(filepath.Join(root, "cgroup.subtree_control"))
+	require.NoError(t, err)
+	assert.Empty(t, string(data))
+	assert.NoDirExists(t, filepath.Join(root, "go-cli-tool"))
+
+	// A cgroup of its own is turned into a parent after moving to a leaf
+	root = fakeCgroup(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids", "cgroup.subtree_control": "",
+		"cgroup.procs": strconv.Itoa(pid) + "\n",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(root, "go-cli-tool"), 0750))
+	parent, err = setupCgroupParent(root, procCgroup, pid)
+	require.NoError(t, err)
+	assert.Equal(t, root, parent)
+	data, err = os.ReadFile(filepath.Join(root, "go-cli-tool", "cgroup.procs"))
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(pid), string(data))
+	data, err = os.ReadFile(filepath.Join(root, "cgroup.subtree_control"))
+	require.NoError(t, err)
+	assert.Equal(t, "+memory +pids", string(data))
+}
+
+// killedState returns the state of a process killed by SIGKILL
+func killedState(t *testing.T) *os.ProcessState {
+	cmd := exec.Command("sh", "-c", "kill -9 $$")
+	require.Error(t, cmd.Run())
+	return cmd.ProcessState
+}
+
+func TestLimiter_MemoryBreach(t *testing.T) {
+	dir := fakeCgroup(t, map[string]string{"memory.events": "low 0\nhigh 0\nmax 4\noom 1\noom_kill 1\n"})
+	l := &limiter{limits: &Limits{Memory: 64 << 20}, cgroup: dir, cgroupFD: -1}
+	breach := l.breach(killedState(t), errors.New("signal: killed"))
+	require.NotNil(t, breach)
+	assert.Equal(t, ReasonMemoryLimit, breach.Reason)
+	assert.Equal(t, l.limits.Memory.String(), breach.Limit)
+
+	l.cgroup = fakeCgroup(t, map[string]string{"memory.events": "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n"})
+	assert.Nil(t, l.breach(killedState(t), errors.New("signal: killed")))
+}
+
+func TestLimiter_ProcessesBreach(t *testing.T) {
+	dir := fakeCgroup(t, map[string]string{"pids.events": "max 3\n"})
+	l := &limiter{limits: &Limits{Processes: 5}, cgroup: dir, cgroupFD: -1}
+	breach := l.breach(killedState(t), errors.New("exit status 2"))
+	require.NotNil(t, breach)
+	assert.Equal(t, ReasonProcessesLimit, breach.Reason)
+	assert.Equal(t, "5", breach.Limit)
+
+	l.cgroup = fakeCgroup(t, map[string]string{"pids.events": "max 0\n"})
+	assert.Nil(t, l.breach(killedState(t), errors.New("exit status 2")))
+}
+
+func TestTask_ExecuteMemoryAndProcessesLimits(t *testing.T) {
+	if _, err := setupCgroupParent(cgroupRoot, "/proc/self/cgroup", os.Getpid()); err != nil {
+		t.Skipf("cgroup v2 is not delegated to this process: %v", err)
+	}
+
+	task := &Task{
+		ID: "memory", Name: "Memory", Type: TaskTypeCommand, Shell: "sh",
+		Command: `x=$(head -c 268435456 /dev/zero | tr '\0' a); echo ${#x}`,
+		Timeout: 30 * time.Second,
+		Limits:  &Limits{Memory: 16 << 20},
+	}
+	result := task.Execute(context.Background())
+	require.Error(t, result.Error)
+	assert.Equal(t, ReasonMemoryLimit, result.FailureReason)
+
+	task = &Task{
+		ID: "processes", Name: "Processes", Type: TaskTypeCommand, Shell: "sh",
+		Command: "for i in 1 2 3 4 5 6 7 8 9 10; do sleep 1 & done; wait",
+		Timeout: 30 * time.Second,
+		Limits:  &Limits{Processes: 4},
+	}
+	result = task.Execute(context.Background())
+	require.Error(t, result.Error)
+	assert.Equal(t, ReasonProcessesLimit, result.FailureReason)
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package task
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+)
+
+// limiter is a no-op outside Linux: limits are not enforced
+type limiter struct{}
+
+// newLimiter warns that the limits of task t are not enforced
+func newLimiter(t *Task, logger *slog.Logger) *limiter {
+	if !t.Limits.IsZero() {
+		logger.Warn("task limits are only enforced on Linux")
+	}
+	return nil
+}
+
+func (l *limiter) prepare(cmd *exec.Cmd) {}
+
+func (l *limiter) breach(state *os.ProcessState, err error) *LimitError { return nil }
+
+func (l *limiter) close() {}
@@ -0,0 +1,78 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ByteSize
+		wantErr bool
+	}{
+		{input: "1048576", want: 1 << 20},
+		{input: "512M", want: 512 << 20},
+		{input: "512mb", want: 512 << 20},
+		{input: "2GiB", want: 2 << 30},
+		{input: "1.5G", want: 3 << 29},
+		{input: "64 KiB", want: 64 << 10},
+		{input: "lots", wantErr: true},
+		{input: "-1G", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := ParseByteSize(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, size)
+		})
+	}
+	assert.Equal(t, "512MiB", ByteSize(512<<20).String())
+	assert.Equal(t, "1536MiB", ByteSize(3<<29).String())
+	assert.Equal(t, "1000B", ByteSize(1000).String())
+}
+
+func TestLoadConfig_Limits(t *testing.T) {
+	config, err := loadConfigString(t, `version: "1.0"
+defaults:
+  limits:
+    memory: 1G
+    processes: 100
+tasks:
+  - id: test
+    name: Test
+    type: command
+    command: go test ./...
+    limits:
+      memory: 2GiB
+      cpu_time: 10m
+      open_files: 1024
+  - id: lint
+    name: Lint
+    type: command
+    command: go vet ./...
+`)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+
+	test, lint := config.Tasks[0], config.Tasks[1]
+	assert.Equal(t, &Limits{Memory: 2 << 30, CPUTime: 10 * time.Minute, OpenFiles: 1024}, test.Limits)
+	assert.Equal(t, &Limits{Memory: 1 << 30, Processes: 100}, lint.Limits)
+	assert.NotSame(t, config.Defaults.Limits, lint.Limits)
+
+	lint.Limits.OpenFiles = -1
+	assert.ErrorContains(t, config.Validate(), "open_files limit must not be negative")
+}
+
+func TestLimitError(t *testing.T) {
+	err := &LimitError{Reason: ReasonCPUTimeLimit, Limit: "1s", Err: assert.AnError}
+	assert.EqualError(t, err, "exceeded cpu time limit of 1s: "+assert.AnError.Error())
+	assert.ErrorIs(t, err, assert.AnError)
+}
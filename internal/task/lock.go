@@ -0,0 +1,215 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultLockDir is where run and task lock files are kept by default
+var DefaultLockDir = filepath.Join(StateDir, "locks")
+
+// lockPollInterval is how often a waiting lock checks whether it is free
+var lockPollInterval = 200 * time.Millisecond
+
+// LockInfo describes the holder of a lock, as recorded in the lock file
+type LockInfo struct {
+	PID         int       `json:"pid"`
+	Host        string    `json:"host"`
+	Description string    `json:"description,omitempty"`
+	Acquired    time.Time `json:"acquired"`
+}
+
+// String describes the holder, e.g. "pid 42 on build-1 (task run) since 10:04:05"
+func (i LockInfo) String() string {
+	s := fmt.Sprintf("pid %d on %s", i.PID, i.Host)
+	if i.Description != "" {
+		s += " (" + i.Description + ")"
+	}
+	return s + " since " + i.Acquired.Format(time.TimeOnly)
+}
+
+// LockedError is returned when a lock is held by another live process
+type LockedError struct {
+	Path   string
+	Holder LockInfo
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("lock %s is held by %s", e.Path, e.Holder)
+}
+
+// Lock is an advisory lock file held by this process
+type Lock struct {
+	path string
+	// data is the content of the lock file this process created
+	data []byte
+}
+
+// AcquireLock takes the lock file at path. If another live process holds
+// it, AcquireLock polls until the lock is released or ctx is done when wait
+// is set, and fails with a *LockedError otherwise. Lock files left behind
+// by processes that are no longer running on this host are removed.
+func AcquireLock(ctx context.Context, path, description string, wait bool) (*Lock, error) {
+	host, _ := os.Hostname()
+	info := LockInfo{PID: os.Getpid(), Host: host, Description: description}
+
+	for {
+		info.Acquired = time.Now()
+		data, err := createLockFile(path, info)
+		if err == nil {
+			return &Lock{path: path, data: data}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		data, holder, stale := readLockFile(path, host)
+		if stale {
+			// Only remove the file if it was not replaced in the meantime
+			if current, err := os.ReadFile(path); err == nil && string(current) == string(data) {
+				os.Remove(path)
+			}
+			continue
+		}
+		if !wait {
+			return nil, &LockedError{Path: path, Holder: holder}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Release removes the lock file, unless it was reclaimed as stale and
+// another process holds it now
+func (l *Lock) Release() error {
+	current, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	if string(current) != string(l.data) {
+		return fmt.Errorf("failed to release lock: %s was taken over by another process", l.path)
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+// createLockFile atomically creates the lock file with info as content,
+// returning that content: it is written to a temporary file first, then
+// linked into place, which fails if the lock file exists
+func createLockFile(path string, info LockInfo) ([]byte, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lock info: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return nil, os.ErrExist
+		}
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	return data, nil
+}
+
+// readLockFile reads the holder of a lock. The lock is stale if its holder
+// ran on this host and is no longer alive, or the file is unreadable.
+func readLockFile(path, host string) ([]byte, LockInfo, bool) {
+	var info LockInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Released in the meantime, or not readable: try again
+		return nil, info, os.IsNotExist(err)
+	}
+	if err := json.Unmarshal(data, &info); err != nil || info.PID <= 0 {
+		return data, info, true
+	}
+	return data, info, info.Host == host && !processAlive(info.PID)
+}
+
+// ConfigLockPath returns the lock file guarding runs of the configuration
+// file at configPath, in dir
+func ConfigLockPath(dir, configPath string) string {
+	abs, err := filepath.Abs(configPath)
+	if err != nil {
+		abs = configPath
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, lockName(filepath.Base(configPath))+"-"+hex.EncodeToString(sum[:4])+".lock")
+}
+
+// TaskLockPath returns the lock file of the task lock name, in dir
+func TaskLockPath(dir, name string) string {
+	return filepath.Join(dir, "task-"+lockName(name)+".lock")
+}
+
+// lockName makes a name safe to use in a file name
+func lockName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 128 && (isEnvNameByte(byte(r), false) || r == '.' || r == '-') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// SetLocking sets the directory of task lock files and whether a task
+// waits for its lock when another process holds it, or fails. Defaults to
+// DefaultLockDir and waiting.
+func (e *Executor) SetLocking(dir string, wait bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lockDir = dir
+	e.lockWait = wait
+}
+
+// acquireTaskLock takes the lock named by the task
+func (e *Executor) acquireTaskLock(ctx context.Context, task *Task) (*Lock, error) {
+	e.mu.RLock()
+	path := TaskLockPath(e.lockDir, task.Lock)
+	wait := e.lockWait
+	e.mu.RUnlock()
+
+	description := fmt.Sprintf("task %s, run %s", task.ID, e.RunID())
+	lock, err := AcquireLock(ctx, path, description, false)
+	var locked *LockedError
+	if errors.As(err, &locked) && wait {
+		e.log().Info("waiting for task lock", "run_id", e.RunID(), "task_id", task.ID, "lock", task.Lock, "holder", locked.Holder.String())
+		lock, err = AcquireLock(ctx, path, description, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", task.Lock, err)
+	}
+	return lock, nil
+}
@@ -0,0 +1,142 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLockInfo(t *testing.T, path string, info LockInfo) {
+	t.Helper()
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "run.lock")
+	ctx := context.Background()
+
+	lock, err := AcquireLock(ctx, path, "first", false)
+	require.NoError(t, err)
+
+	_, err = AcquireLock(ctx, path, "second", false)
+	var locked *LockedError
+	require.True(t, errors.As(err, &locked))
+	assert.Equal(t, os.Getpid(), locked.Holder.PID)
+	assert.Equal(t, "first", locked.Holder.Description)
+
+	require.NoError(t, lock.Release())
+	lock, err = AcquireLock(ctx, path, "second", false)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+	assert.NoFileExists(t, path)
+}
+
+func TestAcquireLock_Wait(t *testing.T) {
+	defer func(interval time.Duration) { lockPollInterval = interval }(lockPollInterval)
+	lockPollInterval = 10 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "run.lock")
+
+	held, err := AcquireLock(context.Background(), path, "", false)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		held.Release()
+	}()
+	lock, err := AcquireLock(context.Background(), path, "", true)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = AcquireLock(ctx, path, "", true)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, lock.Release())
+}
+
+func TestAcquireLock_Stale(t *testing.T) {
+	dir := t.TempDir()
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	// A process that has exited
+	cmd := exec.Command("go", "version")
+	require.NoError(t, cmd.Run())
+	dead := cmd.Process.Pid
+
+	tests := []struct {
+		name      string
+		content   func(path string)
+		wantStale bool
+	}{
+		{name: "dead process", content: func(path string) { writeLockInfo(t, path, LockInfo{PID: dead, Host: host}) }, wantStale: true},
+		{name: "unreadable", content: func(path string) { require.NoError(t, os.WriteFile(path, []byte("garbage"), 0600)) }, wantStale: true},
+		{name: "other host", content: func(path string) { writeLockInfo(t, path, LockInfo{PID: dead, Host: host + "-other"}) }},
+		{name: "live process", content: func(path string) { writeLockInfo(t, path, LockInfo{PID: os.Getpid(), Host: host}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".lock")
+			tt.content(path)
+			lock, err := AcquireLock(context.Background(), path, "", false)
+			if !tt.wantStale {
+				var locked *LockedError
+				assert.True(t, errors.As(err, &locked))
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, lock.Release())
+		})
+	}
+}
+
+func TestLock_ReleaseTakenOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+	lock, err := AcquireLock(context.Background(), path, "first", false)
+	require.NoError(t, err)
+
+	// The lock was reclaimed as stale and is held by someone else now
+	writeLockInfo(t, path, LockInfo{PID: os.Getpid(), Host: "other", Description: "second"})
+	assert.ErrorContains(t, lock.Release(), "taken over")
+	assert.FileExists(t, path)
+
+	require.NoError(t, os.Remove(path))
+	assert.NoError(t, lock.Release(), "a lock already gone is released")
+}
+
+func TestConfigLockPath(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, ConfigLockPath(dir, "tasks.yaml"), ConfigLockPath(dir, "./tasks.yaml"))
+	assert.NotEqual(t, ConfigLockPath(dir, "tasks.yaml"), ConfigLockPath(dir, "ci/tasks.yaml"))
+	assert.Equal(t, filepath.Join(dir, "task-db_main.lock"), TaskLockPath(dir, "db/main"))
+}
+
+func TestExecutor_TaskLock(t *testing.T) {
+	dir := t.TempDir()
+	held, err := AcquireLock(context.Background(), TaskLockPath(dir, "db"), "other run", false)
+	require.NoError(t, err)
+
+	executor := NewExecutor(1, false)
+	executor.SetLocking(dir, false)
+	require.NoError(t, executor.AddTask(&Task{ID: "migrate", Name: "Migrate", Type: TaskTypeCommand, Command: "go version", Lock: "db"}))
+
+	result, err := executor.ExecuteTask(context.Background(), "migrate")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	var locked *LockedError
+	assert.True(t, errors.As(result.Error, &locked))
+
+	require.NoError(t, held.Release())
+	result, err = executor.ExecuteTask(context.Background(), "migrate")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.NoFileExists(t, TaskLockPath(dir, "db"))
+}
@@ -0,0 +1,80 @@
+package task
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a context whose tasks log to logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger carried by ctx, or the default logger
+// annotated with the task ID
+func loggerFrom(ctx context.Context, t *Task) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default().With("task_id", t.ID)
+}
+
+// logEvent records an executor event at debug level. Failures are already
+// reported by the execution summary of task run and by the daemon's own
+// records, so they are not repeated at higher levels.
+func logEvent(logger *slog.Logger, event Event) {
+	var msg string
+	switch event.Type {
+	case EventRunStarted:
+		msg = "run started"
+	case EventTaskQueued:
+		msg = "task queued"
+	case EventTaskStarted:
+		msg = "task started"
+	case EventTaskOutput:
+		msg = "task output"
+	case EventTaskSucceeded:
+		msg = "task succeeded"
+	case EventTaskRetrying:
+		msg = "task failed, retrying"
+	case EventTaskFailed:
+		msg = "task failed"
+	case EventTaskSkipped:
+		msg = "task skipped"
+	case EventRunFinished:
+		msg = "run finished"
+	default:
+		msg = string(event.Type)
+	}
+
+	ctx := context.Background()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("run_id", event.RunID)}
+	if event.TaskID != "" {
+		attrs = append(attrs, slog.String("task_id", event.TaskID))
+	}
+	if event.Attempt > 0 {
+		attrs = append(attrs, slog.Int("attempt", event.Attempt))
+	}
+	if event.Line != "" {
+		attrs = append(attrs, slog.String("line", event.Line))
+	}
+	if event.Status != "" {
+		attrs = append(attrs, slog.String("status", string(event.Status)))
+	}
+	if event.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+	if event.ExitCode != 0 {
+		attrs = append(attrs, slog.Int("exit_code", event.ExitCode))
+	}
+	if event.Error != "" {
+		attrs = append(attrs, slog.String("error", event.Error))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, msg, attrs...)
+}
@@ -0,0 +1,50 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_LogsWithRunAndTaskIDs(t *testing.T) {
+	var out bytes.Buffer
+	executor := NewExecutor(1, false)
+	executor.SetLogger(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	executor.SetRunID("run-42")
+	require.NoError(t, executor.AddTask(&Task{ID: "version", Name: "Version", Type: TaskTypeCommand, Command: "go version"}))
+
+	_, err := executor.ExecuteTask(context.Background(), "version")
+	require.NoError(t, err)
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, "run-42", record["run_id"], line)
+		if record["msg"] != "run started" && record["msg"] != "run finished" {
+			assert.Equal(t, "version", record["task_id"], line)
+		}
+		messages = append(messages, record["msg"].(string))
+	}
+	assert.Contains(t, messages, "running command")
+	assert.Contains(t, messages, "command finished")
+	assert.Contains(t, messages, "task succeeded")
+}
+
+func TestExecutor_FailuresNotLoggedAboveDebug(t *testing.T) {
+	var out bytes.Buffer
+	executor := NewExecutor(1, false)
+	executor.SetLogger(slog.New(slog.NewTextHandler(&out, nil)))
+	require.NoError(t, executor.AddTask(&Task{ID: "missing", Name: "Missing", Type: TaskTypeCommand, Command: "nonexistent-command-12345"}))
+
+	result, err := executor.ExecuteTask(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Empty(t, out.String(), "failures are reported by the summary, not the log")
+}
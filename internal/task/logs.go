@@ -0,0 +1,274 @@
+package task
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogDir is where task output is logged by default, one directory
+// per run
+var DefaultLogDir = filepath.Join(StateDir, "logs")
+
+// logTimeFormat prefixes every line of a task log
+const logTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// logFollowInterval is how often a followed log is checked for new lines
+const logFollowInterval = 200 * time.Millisecond
+
+// Every attempt log ends with a marker line "=== <status>[: <error>] ===".
+// A retrying marker means another attempt follows. Output lines that could
+// be read as a marker are escaped with a backslash, which is removed again
+// when the log is printed.
+const (
+	logMarkerPrefix = "=== "
+	logMarkerSuffix = " ==="
+	logRetrying     = "retrying"
+	logEscape       = `\`
+)
+
+// LogFileSink writes the output of every task attempt to
+// <dir>/<run-id>/<task-id>.<attempt>.log. It can be subscribed to any number
+// of executors.
+type LogFileSink struct {
+	dir string
+
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+// NewLogFileSink creates a sink writing attempt logs below dir
+func NewLogFileSink(dir string) *LogFileSink {
+	return &LogFileSink{dir: dir, files: make(map[string]*os.File)}
+}
+
+// Handle writes output lines and end markers to the attempt's log file
+func (s *LogFileSink) Handle(event Event) {
+	switch event.Type {
+	case EventTaskStarted:
+		s.open(event)
+	case EventTaskOutput:
+		s.write(event, escapeLogLine(event.Line))
+	case EventTaskRetrying:
+		s.close(event, marker(logRetrying, event.Error))
+	case EventTaskSucceeded, EventTaskFailed:
+		s.close(event, marker(string(event.Status), event.Error))
+	}
+}
+
+func (s *LogFileSink) open(event Event) {
+	path := attemptLogPath(s.dir, event.RunID, event.TaskID, event.Attempt)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		slog.Warn("failed to create task log directory", "error", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		slog.Warn("failed to open task log", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = f
+}
+
+func (s *LogFileSink) write(event Event, line string) {
+	path := attemptLogPath(s.dir, event.RunID, event.TaskID, event.Attempt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[path]; ok {
+		fmt.Fprintf(f, "%s %s\n", event.Time.Format(logTimeFormat), line)
+	}
+}
+
+func (s *LogFileSink) close(event Event, line string) {
+	s.write(event, line)
+	path := attemptLogPath(s.dir, event.RunID, event.TaskID, event.Attempt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[path]; ok {
+		_ = f.Close()
+		delete(s.files, path)
+	}
+}
+
+// marker formats the end marker of an attempt log
+func marker(status, errMsg string) string {
+	if errMsg != "" {
+		status += ": " + strings.ReplaceAll(errMsg, "\n", " ")
+	}
+	return logMarkerPrefix + status + logMarkerSuffix
+}
+
+// escapeLogLine escapes an output line that starts like a marker, or with
+// the escape itself
+func escapeLogLine(line string) string {
+	if strings.HasPrefix(line, logMarkerPrefix) || strings.HasPrefix(line, logEscape) {
+		return logEscape + line
+	}
+	return line
+}
+
+// unescapeLogLine reverses escapeLogLine on a timestamped log line
+func unescapeLogLine(line string) string {
+	stamp, text, ok := strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(text, logEscape) {
+		return line
+	}
+	return stamp + " " + strings.TrimPrefix(text, logEscape)
+}
+
+// attemptLogPath returns the log file of one attempt of a task. The task
+// ID is escaped so distinct IDs never share a file.
+func attemptLogPath(dir, runID, taskID string, attempt int) string {
+	return filepath.Join(dir, runID, url.PathEscape(taskID)+"."+strconv.Itoa(attempt)+".log")
+}
+
+// LogStore reads the task logs written by a LogFileSink
+type LogStore struct {
+	dir string
+}
+
+// NewLogStore creates a store reading logs below dir
+func NewLogStore(dir string) *LogStore {
+	return &LogStore{dir: dir}
+}
+
+// LatestRun returns the ID of the most recent run that logged the task
+func (s *LogStore) LatestRun(taskID string) (string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read log directory: %w", err)
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := os.Stat(attemptLogPath(s.dir, entry.Name(), taskID, 1))
+		if err != nil {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = entry.Name(), info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no logs found for task %s", taskID)
+	}
+	return latest, nil
+}
+
+// Print copies the logs of every attempt of a task in a run to w. With
+// follow, it keeps waiting for new lines and attempts until the last
+// attempt ends or ctx is done.
+func (s *LogStore) Print(ctx context.Context, runID, taskID string, w io.Writer, follow bool) error {
+	if !follow {
+		if _, err := os.Stat(attemptLogPath(s.dir, runID, taskID, 1)); err != nil {
+			return fmt.Errorf("no logs found for task %s in run %s", taskID, runID)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		path := attemptLogPath(s.dir, runID, taskID, attempt)
+		f, err := s.openLog(ctx, path, follow)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			return nil
+		}
+
+		status, err := copyLog(ctx, f, w, follow)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if follow && status != logRetrying {
+			return nil
+		}
+	}
+}
+
+// openLog opens a log file. With follow, it waits for the file to appear;
+// otherwise a missing file returns nil.
+func (s *LogStore) openLog(ctx context.Context, path string, follow bool) (*os.File, error) {
+	for {
+		f, err := os.Open(path)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to open task log: %w", err)
+		}
+		if !follow {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(logFollowInterval):
+		}
+	}
+}
+
+// copyLog copies a log to w and returns the status of its end marker.
+// With follow, it waits for more lines until the marker is read.
+func copyLog(ctx context.Context, r io.Reader, w io.Writer, follow bool) (string, error) {
+	reader := bufio.NewReader(r)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		partial += line
+		if err == nil {
+			if status, ok := parseMarker(partial); ok {
+				_, err := io.WriteString(w, partial)
+				return status, err
+			}
+			if _, err := io.WriteString(w, unescapeLogLine(partial)); err != nil {
+				return "", err
+			}
+			partial = ""
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read task log: %w", err)
+		}
+		if !follow {
+			_, err := io.WriteString(w, unescapeLogLine(partial))
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", nil
+		case <-time.After(logFollowInterval):
+		}
+	}
+}
+
+// parseMarker returns the status of an end marker line. Escaped output
+// lines never parse as markers.
+func parseMarker(line string) (string, bool) {
+	_, text, ok := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+	if !ok || !strings.HasPrefix(text, logMarkerPrefix) || !strings.HasSuffix(text, logMarkerSuffix) {
+		return "", false
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, logMarkerPrefix), logMarkerSuffix)
+	status, _, _ := strings.Cut(text, ":")
+	return status, true
+}
@@ -0,0 +1,158 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogFileSink_WritesAttemptLogs(t *testing.T) {
+	dir := t.TempDir()
+	executor := NewExecutor(1, false)
+	executor.SetRunID("run-1")
+	executor.Subscribe(NewLogFileSink(dir))
+	require.NoError(t, executor.AddTask(&Task{
+		ID: "goos", Name: "GOOS", Type: TaskTypeCommand, Command: "go", Args: []string{"env", "GOOS"},
+	}))
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+
+	data, err := os.ReadFile(filepath.Join(dir, "run-1", "goos.1.log"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	stamp, text, _ := strings.Cut(lines[0], " ")
+	_, err = time.Parse(logTimeFormat, stamp)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, text)
+	assert.True(t, strings.HasSuffix(lines[1], "=== completed ==="))
+}
+
+// writeAttempt simulates the events of one attempt
+func writeAttempt(sink *LogFileSink, attempt int, end EventType, status TaskStatus, lines ...string) {
+	event := Event{RunID: "run-1", TaskID: "build", Attempt: attempt, Time: time.Now()}
+	event.Type = EventTaskStarted
+	sink.Handle(event)
+	for _, line := range lines {
+		event.Type, event.Line = EventTaskOutput, line
+		sink.Handle(event)
+	}
+	event.Type, event.Status, event.Error = end, status, ""
+	if end != EventTaskSucceeded {
+		event.Error = "exit status 1"
+	}
+	sink.Handle(event)
+}
+
+func TestLogStore_Print(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewLogFileSink(dir)
+	writeAttempt(sink, 1, EventTaskRetrying, StatusFailed, "first")
+	writeAttempt(sink, 2, EventTaskSucceeded, StatusCompleted, "second")
+
+	store := NewLogStore(dir)
+	runID, err := store.LatestRun("build")
+	require.NoError(t, err)
+	assert.Equal(t, "run-1", runID)
+
+	var out bytes.Buffer
+	require.NoError(t, store.Print(context.Background(), runID, "build", &out, false))
+	text := out.String()
+	assert.Contains(t, text, " first\n")
+	assert.Contains(t, text, " === retrying: exit status 1 ===\n")
+	assert.Contains(t, text, " second\n")
+	assert.Contains(t, text, " === completed ===\n")
+
+	_, err = store.LatestRun("other")
+	assert.Error(t, err)
+	assert.Error(t, store.Print(context.Background(), "run-2", "build", &out, false))
+}
+
+func TestLogStore_DistinctTaskIDs(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewLogFileSink(dir)
+	for _, id := range []string{"a/b", "a_b", "a%2Fb"} {
+		event := Event{RunID: "run-1", TaskID: id, Attempt: 1, Time: time.Now()}
+		event.Type = EventTaskStarted
+		sink.Handle(event)
+		event.Type, event.Line = EventTaskOutput, "output of "+id
+		sink.Handle(event)
+		event.Type, event.Status = EventTaskSucceeded, StatusCompleted
+		sink.Handle(event)
+	}
+
+	store := NewLogStore(dir)
+	for _, id := range []string{"a/b", "a_b", "a%2Fb"} {
+		var out bytes.Buffer
+		require.NoError(t, store.Print(context.Background(), "run-1", id, &out, false))
+		assert.Contains(t, out.String(), " output of "+id+"\n")
+		assert.Equal(t, 1, strings.Count(out.String(), " output of "), id)
+	}
+}
+
+func TestLogStore_Follow(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewLogFileSink(dir)
+	store := NewLogStore(dir)
+
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- store.Print(context.Background(), "run-1", "build", &out, true)
+	}()
+
+	// Follow waits for the attempts to appear and for the retry
+	time.Sleep(2 * logFollowInterval)
+	writeAttempt(sink, 1, EventTaskRetrying, StatusFailed, "first")
+	time.Sleep(2 * logFollowInterval)
+	writeAttempt(sink, 2, EventTaskFailed, StatusFailed, "second")
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("follow did not stop after the last attempt")
+	}
+	assert.Contains(t, out.String(), " first\n")
+	assert.Contains(t, out.String(), " === failed: exit status 1 ===\n")
+}
+
+func TestLogStore_OutputLooksLikeMarker(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewLogFileSink(dir)
+	store := NewLogStore(dir)
+
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- store.Print(context.Background(), "run-1", "build", &out, true)
+	}()
+
+	time.Sleep(2 * logFollowInterval)
+	writeAttempt(sink, 1, EventTaskRetrying, StatusFailed, "=== completed ===", `\raw`)
+	time.Sleep(2 * logFollowInterval)
+	select {
+	case <-done:
+		t.Fatal("follow stopped at output that looks like a marker")
+	default:
+	}
+	writeAttempt(sink, 2, EventTaskSucceeded, StatusCompleted, "second")
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("follow did not stop after the last attempt")
+	}
+	assert.Contains(t, out.String(), " === completed ===\n")
+	assert.Contains(t, out.String(), ` \raw`+"\n")
+	assert.NotContains(t, out.String(), `\===`)
+	assert.Contains(t, out.String(), " second\n")
+}
@@ -0,0 +1,189 @@
+package task
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaskedValue replaces secret values in output, events, logs and history
+const MaskedValue = "***"
+
+// minSecretLength is the shortest value that is masked. Shorter values
+// would redact unrelated output.
+const minSecretLength = 4
+
+// Masker redacts secret values, including their base64 and URL-encoded
+// forms. It is safe for concurrent use.
+type Masker struct {
+	mu       sync.RWMutex
+	forms    map[string]bool
+	replacer *strings.Replacer
+}
+
+// NewMasker creates a masker without secrets
+func NewMasker() *Masker {
+	return &Masker{forms: make(map[string]bool)}
+}
+
+// Add registers secret values to be masked
+func (m *Masker) Add(values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	added := false
+	for _, value := range values {
+		// Output is masked line by line, so multi-line secrets are masked
+		// per line as well
+		parts := append([]string{value}, strings.Split(value, "\n")...)
+		for _, part := range parts {
+			part = strings.TrimRight(part, "\r")
+			for _, form := range secretForms(part) {
+				if len(form) >= minSecretLength && !m.forms[form] {
+					m.forms[form] = true
+					added = true
+				}
+			}
+		}
+	}
+	if added {
+		m.rebuild()
+	}
+}
+
+// rebuild recreates the replacer; m.mu must be held
+func (m *Masker) rebuild() {
+	forms := make([]string, 0, len(m.forms))
+	for form := range m.forms {
+		forms = append(forms, form)
+	}
+	// Longer forms first, so a secret containing another is masked whole
+	sort.Slice(forms, func(i, j int) bool {
+		if len(forms[i]) != len(forms[j]) {
+			return len(forms[i]) > len(forms[j])
+		}
+		return forms[i] < forms[j]
+	})
+
+	pairs := make([]string, 0, 2*len(forms))
+	for _, form := range forms {
+		pairs = append(pairs, form, MaskedValue)
+	}
+	m.replacer = strings.NewReplacer(pairs...)
+}
+
+// Mask replaces every registered secret in s
+func (m *Masker) Mask(s string) string {
+	if m == nil {
+		return s
+	}
+	m.mu.RLock()
+	replacer := m.replacer
+	m.mu.RUnlock()
+	if replacer == nil || s == "" {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// secretForms returns the representations of a secret to mask: the value
+// itself, its URL-encoded forms, and the part of its base64 encoding that
+// does not depend on the surrounding bytes, at each of the three possible
+// alignments. The latter also masks secrets inside larger encoded values,
+// e.g. an encoded "user:secret" in a basic auth header.
+func secretForms(value string) []string {
+	if len(value) < minSecretLength {
+		return nil
+	}
+	forms := []string{value, url.QueryEscape(value), url.PathEscape(value)}
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		for offset := 0; offset < 3; offset++ {
+			data := append(make([]byte, offset), value...)
+			encoded := enc.EncodeToString(data)
+			// Characters encoding only bits of the secret
+			start := (8*offset + 5) / 6
+			end := 8 * len(data) / 6
+			if end > start {
+				forms = append(forms, encoded[start:end])
+			}
+		}
+	}
+	return forms
+}
+
+// maskedError is an error whose message had secrets masked
+type maskedError struct {
+	msg string
+	err error
+}
+
+func (e *maskedError) Error() string { return e.msg }
+func (e *maskedError) Unwrap() error { return e.err }
+
+// maskError returns err with secrets masked from its message
+func (m *Masker) maskError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := m.Mask(err.Error()); msg != err.Error() {
+		return &maskedError{msg: msg, err: err}
+	}
+	return err
+}
+
+// maskingHandler masks secrets in the message and attributes of records
+type maskingHandler struct {
+	handler slog.Handler
+	masker  *Masker
+}
+
+func (h *maskingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *maskingHandler) Handle(ctx context.Context, r slog.Record) error {
+	masked := slog.NewRecord(r.Time, r.Level, h.masker.Mask(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(h.maskAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, masked)
+}
+
+func (h *maskingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.maskAttr(a)
+	}
+	return &maskingHandler{handler: h.handler.WithAttrs(masked), masker: h.masker}
+}
+
+func (h *maskingHandler) WithGroup(name string) slog.Handler {
+	return &maskingHandler{handler: h.handler.WithGroup(name), masker: h.masker}
+}
+
+func (h *maskingHandler) maskAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(h.masker.Mask(value.String()))
+	case slog.KindGroup:
+		attrs := value.Group()
+		masked := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			masked[i] = h.maskAttr(ga)
+		}
+		a.Value = slog.GroupValue(masked...)
+	case slog.KindAny:
+		text := fmt.Sprint(value.Any())
+		if masked := h.masker.Mask(text); masked != text {
+			a.Value = slog.StringValue(masked)
+		}
+	}
+	return a
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMasker_Mask(t *testing.T) {
+	const secret = "s3cr3t/t0ken+value"
+	masker := NewMasker()
+	masker.Add(secret, "abc", "line-one\nline-two")
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "token=" + secret + " done", "token=*** done"},
+		{"query escaped", "?t=" + url.QueryEscape(secret), "?t=***"},
+		{"path escaped", "/x/" + url.PathEscape(secret), "/x/***"},
+		{"short values are not masked", "abc", "abc"},
+		{"multi-line secret per line", "got line-two", "got ***"},
+		{"no secret", "hello world", "hello world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, masker.Mask(tt.input))
+		})
+	}
+}
+
+func TestMasker_MaskBase64(t *testing.T) {
+	const secret = "hunter2-password"
+	masker := NewMasker()
+	masker.Add(secret)
+
+	// The secret at every alignment inside a larger encoded value
+	for _, prefix := range []string{"", "u:", "us:", "user:"} {
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+			encoded := enc.EncodeToString([]byte(prefix + secret + "!"))
+			masked := masker.Mask("Authorization: Basic " + encoded)
+			assert.Contains(t, masked, MaskedValue, prefix)
+			assert.NotContains(t, masked, encoded, prefix)
+		}
+	}
+}
+
+func TestExecutor_MasksSecrets(t *testing.T) {
+	const secret = "sup3rsecret"
+	var logs bytes.Buffer
+	executor := NewExecutor(1, false)
+	executor.SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	require.NoError(t, executor.AddTask(&Task{
+		ID: "leak", Name: "Leak", Type: TaskTypeCommand,
+		Command: "go", Args: []string{"help", "${TOKEN}"},
+		Secrets: []string{"TOKEN"},
+	}))
+
+	var lines []string
+	executor.Subscribe(SubscriberFunc(func(event Event) {
+		if event.Type == EventTaskOutput {
+			lines = append(lines, event.Line)
+		}
+	}))
+
+	ctx := WithVars(context.Background(), map[string]string{"TOKEN": secret})
+	result, err := executor.ExecuteTask(ctx, "leak")
+	require.NoError(t, err)
+
+	assert.Contains(t, result.Output, "go help ***")
+	assert.NotContains(t, result.Output, secret)
+	require.NotEmpty(t, lines)
+	assert.Contains(t, lines[0], "go help ***")
+	assert.NotContains(t, logs.String(), secret)
+	assert.Contains(t, logs.String(), "running command")
+}
+
+func TestTask_Redacted(t *testing.T) {
+	task := &Task{
+		ID:      "deploy",
+		Env:     map[string]string{"TOKEN": "abcd1234", "REGION": "eu"},
+		Secrets: []string{"TOKEN"},
+	}
+	redacted := task.Redacted()
+	assert.Equal(t, MaskedValue, redacted.Env["TOKEN"])
+	assert.Equal(t, "eu", redacted.Env["REGION"])
+	assert.Equal(t, "abcd1234", task.Env["TOKEN"])
+}
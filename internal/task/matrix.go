@@ -0,0 +1,271 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MatrixVarPrefix prefixes the run variables holding the matrix values of
+// a task instance, e.g. ${matrix.go}
+const MatrixVarPrefix = "matrix."
+
+// Matrix expands one task into an instance per combination of values
+type Matrix struct {
+	Axes []MatrixAxis
+	// Include adds combinations, Exclude removes every combination that
+	// matches all values of an entry
+	Include []map[string]string
+	Exclude []map[string]string
+}
+
+// MatrixAxis is one dimension of a matrix
+type MatrixAxis struct {
+	Name   string
+	Values []string
+}
+
+// UnmarshalYAML reads axes as lists of values, plus include and exclude
+// lists of combinations
+func (m *Matrix) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix must be a map", node.Line)
+	}
+	*m = Matrix{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		var err error
+		switch key {
+		case "include":
+			err = value.Decode(&m.Include)
+		case "exclude":
+			err = value.Decode(&m.Exclude)
+		default:
+			var values []string
+			err = value.Decode(&values)
+			m.Axes = append(m.Axes, MatrixAxis{Name: key, Values: values})
+		}
+		if err != nil {
+			return fmt.Errorf("matrix %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// MarshalYAML writes the matrix in the form UnmarshalYAML reads
+func (m Matrix) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value interface{}) error {
+		var v yaml.Node
+		if err := v.Encode(value); err != nil {
+			return err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &v)
+		return nil
+	}
+	for _, axis := range m.Axes {
+		if err := add(axis.Name, axis.Values); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Include) > 0 {
+		if err := add("include", m.Include); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Exclude) > 0 {
+		if err := add("exclude", m.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// matrixEntry is one combination of matrix values, in axis order
+type matrixEntry struct {
+	names  []string
+	values map[string]string
+}
+
+// matches reports whether the entry has all values of filter
+func (c matrixEntry) matches(filter map[string]string) bool {
+	for k, v := range filter {
+		if c.values[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// suffix formats the entry for an instance ID, e.g. "[go=1.21,pkg=api]"
+func (c matrixEntry) suffix() string {
+	parts := make([]string, len(c.names))
+	for i, name := range c.names {
+		parts[i] = name + "=" + c.values[name]
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// combinations returns the cartesian product of the axes, last axis
+// varying fastest, without excluded entries and with included ones
+func (m *Matrix) combinations() ([]matrixEntry, error) {
+	names := make([]string, 0, len(m.Axes))
+	entries := []matrixEntry{{values: map[string]string{}}}
+	for _, axis := range m.Axes {
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("matrix %s has no values", axis.Name)
+		}
+		names = append(names, axis.Name)
+		next := make([]matrixEntry, 0, len(entries)*len(axis.Values))
+		for _, entry := range entries {
+			for _, value := range axis.Values {
+				values := make(map[string]string, len(entry.values)+1)
+				for k, v := range entry.values {
+					values[k] = v
+				}
+				values[axis.Name] = value
+				next = append(next, matrixEntry{values: values})
+			}
+		}
+		entries = next
+	}
+	if len(m.Axes) == 0 {
+		entries = nil
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		excluded := false
+		for _, filter := range m.Exclude {
+			excluded = excluded || entry.matches(filter)
+		}
+		if !excluded {
+			entry.names = names
+			kept = append(kept, entry)
+		}
+	}
+	entries = kept
+
+	for _, include := range m.Include {
+		if len(include) == 0 {
+			continue
+		}
+		entry := matrixEntry{names: append([]string(nil), names...), values: include}
+		for _, name := range sortedKeys(include) {
+			if !containsString(names, name) {
+				entry.names = append(entry.names, name)
+			}
+		}
+		duplicate := false
+		for _, existing := range entries {
+			duplicate = duplicate || (len(existing.values) == len(include) && existing.matches(include))
+		}
+		if !duplicate {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("matrix has no combinations")
+	}
+	return entries, nil
+}
+
+// expandMatrices replaces every task with a matrix by one instance per
+// combination. Dependencies on a matrix task become dependencies on all of
+// its instances.
+func (c *Config) expandMatrices() error {
+	instances := make(map[string][]string)
+	expanded := make([]*Task, 0, len(c.Tasks))
+	for _, t := range c.Tasks {
+		if t.Matrix == nil {
+			expanded = append(expanded, t)
+			continue
+		}
+		entries, err := t.Matrix.combinations()
+		if err != nil {
+			return fmt.Errorf("task %s: %w", t.ID, err)
+		}
+		for _, entry := range entries {
+			instance := t.instance(entry)
+			expanded = append(expanded, instance)
+			instances[t.ID] = append(instances[t.ID], instance.ID)
+		}
+	}
+
+	for _, t := range expanded {
+		var deps []string
+		for _, dep := range t.DependsOn {
+			if ids, ok := instances[dep]; ok {
+				deps = append(deps, ids...)
+			} else {
+				deps = append(deps, dep)
+			}
+		}
+		t.DependsOn = deps
+	}
+	c.Tasks = expanded
+	return nil
+}
+
+// instance returns the task for one matrix combination. The values are
+// available as ${matrix.<name>} variables and MATRIX_<NAME> env entries.
+func (t *Task) instance(entry matrixEntry) *Task {
+	instance := t.Clone()
+	instance.Matrix = nil
+	instance.Group = t.ID
+	instance.ID = t.ID + entry.suffix()
+	instance.Name = strings.TrimSpace(t.Name + " " + entry.suffix())
+
+	instance.Vars = make(map[string]string, len(t.Vars)+len(entry.names))
+	for k, v := range t.Vars {
+		instance.Vars[k] = v
+	}
+	if instance.Env == nil {
+		instance.Env = make(map[string]string, len(entry.names))
+	}
+	for _, name := range entry.names {
+		value := entry.values[name]
+		instance.Vars[MatrixVarPrefix+name] = value
+		envName := "MATRIX_" + strings.ToUpper(strings.Map(func(r rune) rune {
+			if r < 128 && isEnvNameByte(byte(r), false) {
+				return r
+			}
+			return '_'
+		}, name))
+		if _, ok := instance.Env[envName]; !ok {
+			instance.Env[envName] = value
+		}
+	}
+	return instance
+}
+
+// Instances returns the IDs of the tasks expanded from the matrix task id
+func (c *Config) Instances(id string) []string {
+	var ids []string
+	for _, t := range c.Tasks {
+		if t.Group == id {
+			ids = append(ids, t.ID)
+		}
+	}
+	return ids
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
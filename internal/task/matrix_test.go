@@ -0,0 +1,92 @@
+package task
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_Matrix(t *testing.T) {
+	config, err := loadConfigString(t, `version: "1.0"
+tasks:
+  - id: go-test
+    name: Test
+    type: command
+    command: go
+    args: [test, "./${matrix.pkg}/..."]
+    matrix:
+      go: ["1.21", "1.22"]
+      pkg: [api, cli]
+      exclude:
+        - {go: "1.21", pkg: cli}
+      include:
+        - {go: "1.23", pkg: api, race: "true"}
+        - {go: "1.22", pkg: api}
+  - id: report
+    name: Report
+    type: command
+    command: go version
+    depends_on: [go-test]
+  - id: api-only
+    name: API only
+    type: command
+    command: go version
+    depends_on: ["go-test[go=1.22,pkg=api]"]
+`)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+
+	instances := []string{
+		"go-test[go=1.21,pkg=api]",
+		"go-test[go=1.22,pkg=api]",
+		"go-test[go=1.22,pkg=cli]",
+		"go-test[go=1.23,pkg=api,race=true]",
+	}
+	assert.Equal(t, instances, config.Instances("go-test"))
+
+	first, ok := findConfigTask(config, "go-test[go=1.22,pkg=cli]")
+	require.True(t, ok)
+	assert.Equal(t, "go-test", first.Group)
+	assert.Equal(t, "Test [go=1.22,pkg=cli]", first.Name)
+	assert.Nil(t, first.Matrix)
+	assert.Equal(t, map[string]string{"matrix.go": "1.22", "matrix.pkg": "cli"}, first.Vars)
+	assert.Equal(t, "cli", first.Env["MATRIX_PKG"])
+
+	report, ok := findConfigTask(config, "report")
+	require.True(t, ok)
+	assert.Equal(t, instances, report.DependsOn)
+	apiOnly, ok := findConfigTask(config, "api-only")
+	require.True(t, ok)
+	assert.Equal(t, []string{"go-test[go=1.22,pkg=api]"}, apiOnly.DependsOn)
+}
+
+func TestLoadConfig_MatrixErrors(t *testing.T) {
+	_, err := loadConfigString(t, "version: \"1.0\"\ntasks:\n  - id: a\n    matrix:\n      os: []\n")
+	assert.ErrorContains(t, err, "task a: matrix os has no values")
+
+	_, err = loadConfigString(t, "version: \"1.0\"\ntasks:\n  - id: a\n    matrix:\n      os: [linux]\n      exclude: [{os: linux}]\n")
+	assert.ErrorContains(t, err, "matrix has no combinations")
+}
+
+func TestTask_ExecuteMatrixVars(t *testing.T) {
+	task := &Task{
+		ID: "env", Name: "Env", Type: TaskTypeCommand,
+		Command: "go", Args: []string{"env", "${matrix.var}"},
+		Vars: map[string]string{"matrix.var": "GOOS"},
+	}
+	result := task.Execute(context.Background())
+	require.NoError(t, result.Error)
+	assert.Contains(t, result.Output, runtime.GOOS)
+}
+
+func findConfigTask(config *Config, id string) (*Task, bool) {
+	for _, t := range config.Tasks {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-cli-tool/internal/notify"
+)
+
+// NotifySink sends a notification through a notifier when a run finishes.
+// Runs of the same set of tasks are compared to tell when the status
+// changed. Sending blocks the end of the run, bounded by a timeout per
+// channel.
+type NotifySink struct {
+	notifier *notify.Notifier
+	logger   *slog.Logger
+
+	mu   sync.Mutex
+	runs map[string]*notifyRun
+}
+
+// notifyRun collects the task outcomes of a run in progress
+type notifyRun struct {
+	start   time.Time
+	taskIDs []string
+	tasks   map[string]notify.TaskRun
+}
+
+// NewNotifySink creates a sink notifying notifier of finished runs,
+// logging failed notifications to logger
+func NewNotifySink(notifier *notify.Notifier, logger *slog.Logger) *NotifySink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &NotifySink{notifier: notifier, logger: logger, runs: make(map[string]*notifyRun)}
+}
+
+// Handle collects task outcomes and notifies when the run finishes
+func (s *NotifySink) Handle(event Event) {
+	s.mu.Lock()
+	switch event.Type {
+	case EventRunStarted:
+		s.runs[event.RunID] = &notifyRun{
+			start:   event.Time,
+			taskIDs: event.TaskIDs,
+			tasks:   make(map[string]notify.TaskRun, len(event.TaskIDs)),
+		}
+		s.mu.Unlock()
+		return
+	case EventTaskSucceeded, EventTaskFailed, EventTaskSkipped:
+		if run, ok := s.runs[event.RunID]; ok && containsString(run.taskIDs, event.TaskID) {
+			run.tasks[event.TaskID] = notify.TaskRun{
+				ID:       event.TaskID,
+				Name:     event.TaskName,
+				Status:   string(event.Status),
+				Duration: event.Duration,
+				ExitCode: event.ExitCode,
+				Error:    event.Error,
+			}
+		}
+		s.mu.Unlock()
+		return
+	case EventRunFinished:
+	default:
+		s.mu.Unlock()
+		return
+	}
+
+	run, ok := s.runs[event.RunID]
+	delete(s.runs, event.RunID)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	summary := &notify.Run{
+		ID:       event.RunID,
+		Status:   notify.StatusSuccess,
+		Start:    run.start,
+		End:      event.Time,
+		Duration: event.Duration,
+		Error:    event.Error,
+	}
+	if event.Status != StatusCompleted {
+		summary.Status = notify.StatusFailure
+	}
+	for _, id := range run.taskIDs {
+		t, ok := run.tasks[id]
+		if !ok {
+			t = notify.TaskRun{ID: id, Status: string(StatusPending), Error: "did not run"}
+		}
+		summary.Tasks = append(summary.Tasks, t)
+	}
+
+	key := append([]string(nil), run.taskIDs...)
+	sort.Strings(key)
+	if err := s.notifier.Notify(context.Background(), strings.Join(key, ","), summary); err != nil {
+		s.logger.Warn("failed to send notification", "run_id", event.RunID, "error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/go-cli-tool/internal/notify"
+)
+
+func TestLoadConfig_Notify(t *testing.T) {
+	config, err := loadConfigString(t, `version: "1.0"
+notify:
+  - type: slack
+    url_env: SLACK_WEBHOOK_URL
+    on: [failure, change]
+  - type: email
+    smtp: localhost:25
+tasks:
+  - id: build
+    name: Build
+    type: command
+    command: go version
+`)
+	require.NoError(t, err)
+	require.Len(t, config.Notify, 2)
+	assert.Equal(t, []notify.Trigger{notify.TriggerFailure, notify.TriggerChange}, config.Notify[0].On)
+	assert.ErrorContains(t, config.Validate(), "notify[1]: email notification needs smtp, from and to")
+}
+
+func TestNotifySink(t *testing.T) {
+	runs := make(chan notify.Run, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var run notify.Run
+		_ = json.Unmarshal(body, &run)
+		runs <- run
+	}))
+	defer server.Close()
+
+	notifier := notify.New([]notify.Channel{
+		{Type: notify.ChannelWebhook, URL: server.URL, On: []notify.Trigger{notify.TriggerSuccess, notify.TriggerFailure}},
+	}, filepath.Join(t.TempDir(), "notify.json"), nil)
+	executor := NewExecutor(1, false)
+	executor.Subscribe(NewNotifySink(notifier, nil))
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "test", Name: "Test", Type: TaskTypeCommand, Command: "go nosuchcommand", DependsOn: []string{"build"}},
+		{ID: "deploy", Name: "Deploy", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"test"}},
+	}))
+
+	assert.Error(t, executor.ExecuteAll(context.Background()))
+	require.Len(t, runs, 1)
+	run := <-runs
+	assert.Equal(t, notify.StatusFailure, run.Status)
+	assert.NotEmpty(t, run.ID)
+	assert.Empty(t, run.PreviousStatus)
+	require.Len(t, run.Tasks, 3)
+	byID := make(map[string]notify.TaskRun)
+	for _, tr := range run.Tasks {
+		byID[tr.ID] = tr
+	}
+	assert.Equal(t, "completed", byID["build"].Status)
+	assert.Equal(t, "failed", byID["test"].Status)
+	assert.NotEmpty(t, byID["test"].Error)
+	assert.NotEqual(t, "completed", byID["deploy"].Status)
+
+	executor = NewExecutor(1, false)
+	executor.Subscribe(NewNotifySink(notifier, nil))
+	require.NoError(t, executor.AddTask(&Task{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version"}))
+	_, err := executor.ExecuteTask(context.Background(), "build")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	run = <-runs
+	assert.Equal(t, notify.StatusSuccess, run.Status)
+	assert.Empty(t, run.PreviousStatus, "runs of other tasks are tracked separately")
+	assert.Len(t, run.Tasks, 1)
+}
@@ -0,0 +1,139 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// OutputMode controls how task output is shown on the console
+type OutputMode string
+
+const (
+	OutputNone     OutputMode = "none"     // Not shown
+	OutputStream   OutputMode = "stream"   // Each line as it is written, prefixed with the task ID
+	OutputBuffered OutputMode = "buffered" // All lines of an attempt at once when it ends
+)
+
+// Validate checks that the output mode is known
+func (m OutputMode) Validate() error {
+	switch m {
+	case "", OutputNone, OutputStream, OutputBuffered:
+		return nil
+	}
+	return fmt.Errorf("unknown output mode: %s", m)
+}
+
+type outputKey struct{}
+
+// withOutput returns a context whose tasks stream their output to w
+func withOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// outputFrom returns the stream task output is copied to, if any
+func outputFrom(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputKey{}).(io.Writer)
+	return w
+}
+
+// lineWriter writes complete lines to an underlying writer, each prefixed
+// with a fixed string. Incomplete lines are held back until Flush.
+type lineWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newLineWriter(w io.Writer, prefix string) *lineWriter {
+	return &lineWriter{w: w, prefix: prefix}
+}
+
+// Write buffers p and emits every complete line
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := lw.emit(lw.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		lw.buf = lw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits a trailing incomplete line
+func (lw *lineWriter) Flush() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if len(lw.buf) == 0 {
+		return nil
+	}
+	line := append(lw.buf, '\n')
+	lw.buf = nil
+	return lw.emit(line)
+}
+
+func (lw *lineWriter) emit(line []byte) error {
+	_, err := lw.w.Write(append([]byte(lw.prefix), line...))
+	return err
+}
+
+// OutputSink prints task output to a console according to the output mode
+// of each task. Tasks without a mode use the sink's default mode.
+type OutputSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	fallback OutputMode
+	modes    map[string]OutputMode
+	buffers  map[string][]string
+}
+
+// NewOutputSink creates a sink printing the output of tasks to w
+func NewOutputSink(w io.Writer, tasks []*Task, fallback OutputMode) *OutputSink {
+	modes := make(map[string]OutputMode, len(tasks))
+	for _, t := range tasks {
+		if t.OutputMode != "" {
+			modes[t.ID] = t.OutputMode
+		}
+	}
+	return &OutputSink{w: w, fallback: fallback, modes: modes, buffers: make(map[string][]string)}
+}
+
+// Handle prints or buffers output events and flushes buffered output when
+// an attempt ends
+func (s *OutputSink) Handle(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mode, ok := s.modes[event.TaskID]
+	if !ok {
+		mode = s.fallback
+	}
+	switch mode {
+	case OutputStream:
+		if event.Type == EventTaskOutput {
+			fmt.Fprintf(s.w, "[%s] %s\n", event.TaskID, event.Line)
+		}
+	case OutputBuffered:
+		switch event.Type {
+		case EventTaskOutput:
+			s.buffers[event.TaskID] = append(s.buffers[event.TaskID], event.Line)
+		case EventTaskSucceeded, EventTaskRetrying, EventTaskFailed:
+			for _, line := range s.buffers[event.TaskID] {
+				fmt.Fprintf(s.w, "[%s] %s\n", event.TaskID, line)
+			}
+			delete(s.buffers, event.TaskID)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy restricts what the tasks of an untrusted configuration may do. A
+// rule left out of the policy file does not restrict anything; a rule set
+// to an empty list allows nothing.
+type Policy struct {
+	// AllowedExecutables lists the programs tasks may run, by name, path or
+	// glob. Names match only programs looked up on the inherited PATH.
+	AllowedExecutables []string `yaml:"allowed_executables"`
+	// ForbiddenArgs are regular expressions that no argument, shell script
+	// or whole command line may match
+	ForbiddenArgs []string `yaml:"forbidden_args"`
+	// AllowedWorkdirs lists the directories, or globs, tasks may run in or
+	// below. Relative paths are relative to the current directory.
+	AllowedWorkdirs []string `yaml:"allowed_workdirs"`
+	// AllowedHosts lists the hosts, or globs such as *.example.com, that
+	// HTTP tasks may reach
+	AllowedHosts []string `yaml:"allowed_hosts"`
+
+	forbidden []*regexp.Regexp
+}
+
+// PolicyError reports a task denied by the policy
+type PolicyError struct {
+	TaskID string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("task %s denied by policy: %s", e.TaskID, e.Reason)
+}
+
+// LoadPolicy loads a policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	for _, pattern := range policy.ForbiddenArgs {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forbidden_args pattern %q: %w", pattern, err)
+		}
+		policy.forbidden = append(policy.forbidden, re)
+	}
+	return &policy, nil
+}
+
+// Check returns a *PolicyError if task t may not run with the run
+// variables of ctx
+func (p *Policy) Check(ctx context.Context, t *Task) error {
+	vars := varsFrom(WithVars(ctx, t.Vars))
+	deny := func(format string, args ...interface{}) error {
+		return &PolicyError{TaskID: t.ID, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if t.Type == TaskTypeHTTP {
+		if err := p.checkHost(expandVars(t.Command, vars)); err != "" {
+			return deny("%s", err)
+		}
+	} else {
+		argv, err := t.commandLine(vars)
+		if err != nil {
+			return deny("%v", err)
+		}
+		if !p.allowsExecutable(argv[0], expandVars(t.WorkDir, vars), len(t.PathPrepend) > 0) {
+			return deny("executable %s is not allowed", argv[0])
+		}
+		for _, re := range p.forbidden {
+			for _, arg := range append([]string{strings.Join(argv, " ")}, argv...) {
+				if re.MatchString(arg) {
+					return deny("arguments match forbidden pattern %q", re.String())
+				}
+			}
+		}
+	}
+
+	if p.AllowedWorkdirs != nil {
+		dir := expandVars(t.WorkDir, vars)
+		if dir == "" {
+			dir = "."
+		}
+		if !p.allowsWorkdir(dir) {
+			return deny("workdir %s is not allowed", dir)
+		}
+	}
+	return nil
+}
+
+// allowsExecutable reports whether program may be run. A relative path is
+// resolved against the task's workdir, as it is when the program runs.
+// Programs given by name, when the task prepends dirs to PATH, could
+// resolve to anything, so they only match allowed paths.
+func (p *Policy) allowsExecutable(program, workdir string, pathPrepended bool) bool {
+	if p.AllowedExecutables == nil {
+		return true
+	}
+	byName := !strings.ContainsAny(program, `/\`)
+	if !byName && !filepath.IsAbs(program) {
+		program = filepath.Join(workdir, program)
+	}
+	for _, allowed := range p.AllowedExecutables {
+		if strings.ContainsAny(allowed, `/\`) {
+			if abs, err := filepath.Abs(allowed); err == nil && !byName {
+				if path, err := filepath.Abs(program); err == nil && globMatch(abs, path) {
+					return true
+				}
+			}
+			continue
+		}
+		if byName && !pathPrepended && globMatch(allowed, program) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsWorkdir reports whether dir is one of the allowed directories or
+// below one
+func (p *Policy) allowsWorkdir(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range p.AllowedWorkdirs {
+		allowedAbs, err := filepath.Abs(allowed)
+		if err != nil {
+			continue
+		}
+		for d := abs; ; d = filepath.Dir(d) {
+			if globMatch(allowedAbs, d) {
+				return true
+			}
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// checkHost checks the URL of an HTTP task command such as
+// "GET https://api.example.com/health", returning the denial reason
+func (p *Policy) checkHost(command string) string {
+	if p.AllowedHosts == nil {
+		return ""
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "no URL"
+	}
+	u, err := url.Parse(fields[len(fields)-1])
+	if err != nil || u.Hostname() == "" {
+		return fmt.Sprintf("invalid URL %q", fields[len(fields)-1])
+	}
+	for _, allowed := range p.AllowedHosts {
+		if globMatch(strings.ToLower(allowed), strings.ToLower(u.Hostname())) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("host %s is not allowed", u.Hostname())
+}
+
+// CheckAll checks every task, returning one error per denied task
+func (p *Policy) CheckAll(ctx context.Context, tasks []*Task) []error {
+	var denials []error
+	for _, t := range tasks {
+		if err := p.Check(ctx, t); err != nil {
+			denials = append(denials, err)
+		}
+	}
+	return denials
+}
+
+// SetPolicy makes the executor check every task attempt against policy
+// before running it; denied tasks fail with a *PolicyError
+func (e *Executor) SetPolicy(policy *Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = policy
+}
+
+func globMatch(pattern, name string) bool {
+	matched, err := filepath.Match(pattern, name)
+	return err == nil && matched
+}
@@ -0,0 +1,147 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadPolicyString(t *testing.T, content string) (*Policy, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return LoadPolicy(path)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	_, err := loadPolicyString(t, "forbidden_args: ['(']\n")
+	assert.ErrorContains(t, err, `invalid forbidden_args pattern "("`)
+
+	policy, err := loadPolicyString(t, "allowed_executables: []\n")
+	require.NoError(t, err)
+	assert.NotNil(t, policy.AllowedExecutables)
+	assert.Nil(t, policy.AllowedHosts)
+}
+
+func TestPolicy_Check(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := loadPolicyString(t, `allowed_executables: [go, "make*", /usr/bin/git]
+forbidden_args: ['rm\s+-rf', '^--insecure$']
+allowed_workdirs: [`+dir+`/services/*]
+allowed_hosts: [api.example.com, "*.internal.example.com"]
+`)
+	require.NoError(t, err)
+	services := filepath.Join(dir, "services", "api")
+
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr string
+	}{
+		{name: "allowed", task: Task{Command: "go", Args: []string{"test", "./..."}}},
+		{name: "glob name", task: Task{Command: "make-dist"}},
+		{name: "allowed path", task: Task{Command: "/usr/bin/git status"}},
+		{name: "below allowed workdir", task: Task{Command: "go version", WorkDir: filepath.Join(services, "cmd")}},
+		{name: "executable", task: Task{Command: "curl", Args: []string{"example.com"}}, wantErr: "executable curl is not allowed"},
+		{name: "path to allowed name", task: Task{Command: "./go version"}, wantErr: "executable ./go is not allowed"},
+		{name: "name with path_prepend", task: Task{Command: "go version", PathPrepend: []string{"bin"}}, wantErr: "executable go is not allowed"},
+		{name: "shell", task: Task{Command: "rm -rf /", Shell: "sh"}, wantErr: "executable sh is not allowed"},
+		{name: "forbidden arg", task: Task{Command: "go", Args: []string{"get", "--insecure"}}, wantErr: `forbidden pattern "^--insecure$"`},
+		{name: "forbidden in vars", task: Task{Command: "go ${cmd}", Vars: map[string]string{"cmd": "rm -rf"}}, wantErr: `forbidden pattern "rm\\s+-rf"`},
+		{name: "workdir", task: Task{Command: "go version", WorkDir: dir}, wantErr: "workdir " + dir + " is not allowed"},
+		{name: "default workdir", task: Task{Command: "go version"}, wantErr: "workdir . is not allowed"},
+		{name: "host", task: Task{Type: TaskTypeHTTP, Command: "GET https://db.internal.example.com/health", WorkDir: services}},
+		{name: "denied host", task: Task{Type: TaskTypeHTTP, Command: "GET https://evil.example.org/", WorkDir: services}, wantErr: "host evil.example.org is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := tt.task
+			task.ID = "t"
+			if task.Type == "" {
+				task.Type = TaskTypeCommand
+			}
+			if task.WorkDir == "" && tt.name != "default workdir" {
+				task.WorkDir = services
+			}
+			err := policy.Check(context.Background(), &task)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var denial *PolicyError
+			require.True(t, errors.As(err, &denial), "%v", err)
+			assert.Equal(t, "t", denial.TaskID)
+			assert.Contains(t, denial.Reason, tt.wantErr)
+		})
+	}
+}
+
+func TestPolicy_RelativeExecutable(t *testing.T) {
+	repo, evil := t.TempDir(), t.TempDir()
+	policy, err := loadPolicyString(t, `allowed_executables: [`+repo+`/scripts/*]
+`)
+	require.NoError(t, err)
+
+	allowed := Task{ID: "t", Type: TaskTypeCommand, Command: "./scripts/build.sh", WorkDir: repo}
+	assert.NoError(t, policy.Check(context.Background(), &allowed))
+
+	// The program runs from the workdir, not the current directory
+	moved := Task{ID: "t", Type: TaskTypeCommand, Command: "./scripts/build.sh", WorkDir: evil}
+	assert.ErrorContains(t, policy.Check(context.Background(), &moved), "executable ./scripts/build.sh is not allowed")
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(repo))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	unset := Task{ID: "t", Type: TaskTypeCommand, Command: "./scripts/build.sh"}
+	assert.NoError(t, policy.Check(context.Background(), &unset))
+}
+
+func TestPolicy_EmptyRules(t *testing.T) {
+	policy, err := loadPolicyString(t, "forbidden_args: [secret]\n")
+	require.NoError(t, err)
+	assert.NoError(t, policy.Check(context.Background(), &Task{ID: "t", Type: TaskTypeCommand, Command: "anything", WorkDir: "/"}))
+
+	policy, err = loadPolicyString(t, "allowed_executables: []\n")
+	require.NoError(t, err)
+	assert.EqualError(t, policy.Check(context.Background(), &Task{ID: "t", Type: TaskTypeCommand, Command: "go version"}),
+		"task t denied by policy: executable go is not allowed")
+}
+
+func TestExecutor_Policy(t *testing.T) {
+	policy, err := loadPolicyString(t, "allowed_executables: [go]\n")
+	require.NoError(t, err)
+
+	executor := NewExecutor(1, false)
+	executor.SetPolicy(policy)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "fetch", Name: "Fetch", Type: TaskTypeCommand, Command: "curl example.com", RetryCount: 3, OnFailure: FailureContinue},
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"fetch"}},
+		{ID: "vet", Name: "Vet", Type: TaskTypeCommand, Command: "go version"},
+	}))
+	var events []EventType
+	executor.Subscribe(SubscriberFunc(func(event Event) {
+		if event.TaskID == "fetch" {
+			events = append(events, event.Type)
+		}
+	}))
+	assert.Error(t, executor.ExecuteAll(context.Background()))
+
+	fetch, ok := executor.GetResult("fetch")
+	require.True(t, ok)
+	var denial *PolicyError
+	assert.True(t, errors.As(fetch.Error, &denial))
+	assert.Equal(t, []EventType{EventTaskQueued, EventTaskFailed}, events, "denials are not retried")
+
+	build, ok := executor.GetResult("build")
+	require.True(t, ok)
+	assert.Equal(t, StatusSkipped, build.Task.Status)
+	vet, ok := executor.GetResult("vet")
+	require.True(t, ok)
+	assert.True(t, vet.Success)
+}
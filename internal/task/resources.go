@@ -0,0 +1,152 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResourceUses maps resource names to the amount a task holds while it runs
+type ResourceUses map[string]int
+
+// UnmarshalYAML reads a map of amounts, or a list of names and single-entry
+// maps, e.g. [db: 1, cpu: 4] or [db]; a bare name uses 1
+func (u *ResourceUses) UnmarshalYAML(node *yaml.Node) error {
+	uses := make(ResourceUses)
+	add := func(key, value *yaml.Node) error {
+		amount := 1
+		if value != nil {
+			var err error
+			if amount, err = strconv.Atoi(value.Value); err != nil || value.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: amount of resource %s must be an integer", value.Line, key.Value)
+			}
+		}
+		if _, exists := uses[key.Value]; exists {
+			return fmt.Errorf("line %d: resource %s is listed twice", key.Line, key.Value)
+		}
+		uses[key.Value] = amount
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := add(node.Content[i], node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			switch {
+			case item.Kind == yaml.ScalarNode:
+				if err := add(item, nil); err != nil {
+					return err
+				}
+			case item.Kind == yaml.MappingNode && len(item.Content) == 2:
+				if err := add(item.Content[0], item.Content[1]); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("line %d: uses entries must be a name or name: amount", item.Line)
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: uses must be a list or a map", node.Line)
+	}
+	*u = uses
+	return nil
+}
+
+// validateResources checks that every resource used by a task is declared
+// and that no task needs more of a resource than its capacity
+func (c *Config) validateResources() error {
+	for name, capacity := range c.Resources {
+		if capacity <= 0 {
+			return fmt.Errorf("resource %s must have a positive capacity", name)
+		}
+	}
+	for _, t := range c.Tasks {
+		for _, name := range sortedResourceNames(t.Uses) {
+			capacity, ok := c.Resources[name]
+			switch {
+			case !ok:
+				return fmt.Errorf("task %s uses undeclared resource: %s", t.ID, name)
+			case t.Uses[name] <= 0:
+				return fmt.Errorf("task %s must use a positive amount of resource %s", t.ID, name)
+			case t.Uses[name] > capacity:
+				return fmt.Errorf("task %s uses %d of resource %s, which has a capacity of %d", t.ID, t.Uses[name], name, capacity)
+			}
+		}
+	}
+	return nil
+}
+
+// SetResources sets the capacity of the resources tasks declare in uses.
+// The scheduler never starts a task while the tasks running alongside it
+// hold too much of a resource it uses; undeclared resources are unlimited.
+func (e *Executor) SetResources(resources map[string]int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resources = resources
+}
+
+// blocker returns what keeps a ready task from starting next to the running
+// ones, or "" if it can start
+func (s *scheduler) blocker(t *Task) string {
+	if s.exclusive != "" {
+		return "exclusive task " + s.exclusive
+	}
+	if t.Exclusive && s.running > 0 {
+		return "running tasks, as it is exclusive"
+	}
+	for _, name := range sortedResourceNames(t.Uses) {
+		if capacity, ok := s.capacity[name]; ok && s.inUse[name]+t.Uses[name] > capacity {
+			return "resource " + name
+		}
+	}
+	return ""
+}
+
+// acquire marks the resources of a starting task as held
+func (s *scheduler) acquire(t *Task) {
+	s.running++
+	if t.Exclusive {
+		s.exclusive = t.ID
+	}
+	for name, amount := range t.Uses {
+		s.inUse[name] += amount
+	}
+}
+
+// releaseResources returns the resources of a finished task
+func (s *scheduler) releaseResources(t *Task) {
+	s.running--
+	if s.exclusive == t.ID {
+		s.exclusive = ""
+	}
+	for name, amount := range t.Uses {
+		s.inUse[name] -= amount
+	}
+}
+
+// checkCapacity fails if a task needs more of a resource than there is, as
+// it could never start
+func (s *scheduler) checkCapacity() error {
+	for id, t := range s.tasks {
+		for _, name := range sortedResourceNames(t.Uses) {
+			if capacity, ok := s.capacity[name]; ok && t.Uses[name] > capacity {
+				return fmt.Errorf("task %s uses %d of resource %s, which has a capacity of %d", id, t.Uses[name], name, capacity)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedResourceNames(uses ResourceUses) []string {
+	names := make(map[string]string, len(uses))
+	for name := range uses {
+		names[name] = ""
+	}
+	return sortedKeys(names)
+}
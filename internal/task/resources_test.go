@@ -0,0 +1,118 @@
+package task
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResourceUses_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ResourceUses
+		wantErr string
+	}{
+		{name: "list of maps", input: "[db: 1, cpu: 4]", want: ResourceUses{"db": 1, "cpu": 4}},
+		{name: "bare names", input: "[db, cpu: 2]", want: ResourceUses{"db": 1, "cpu": 2}},
+		{name: "map", input: "{db: 1, cpu: 4}", want: ResourceUses{"db": 1, "cpu": 4}},
+		{name: "not an integer", input: "[db: many]", wantErr: "amount of resource db must be an integer"},
+		{name: "listed twice", input: "[db, db: 2]", wantErr: "resource db is listed twice"},
+		{name: "scalar", input: "db", wantErr: "uses must be a list or a map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var uses ResourceUses
+			err := yaml.Unmarshal([]byte(tt.input), &uses)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, uses)
+		})
+	}
+}
+
+func TestConfig_ValidateResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources map[string]int
+		uses      ResourceUses
+		wantErr   string
+	}{
+		{name: "valid", resources: map[string]int{"db": 2}, uses: ResourceUses{"db": 2}},
+		{name: "undeclared", uses: ResourceUses{"db": 1}, wantErr: "task t uses undeclared resource: db"},
+		{name: "over capacity", resources: map[string]int{"db": 1}, uses: ResourceUses{"db": 2}, wantErr: "uses 2 of resource db, which has a capacity of 1"},
+		{name: "zero amount", resources: map[string]int{"db": 1}, uses: ResourceUses{"db": 0}, wantErr: "positive amount of resource db"},
+		{name: "zero capacity", resources: map[string]int{"db": 0}, wantErr: "resource db must have a positive capacity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Version:   "1.0",
+				Resources: tt.resources,
+				Tasks:     []*Task{{ID: "t", Name: "T", Type: TaskTypeCommand, Command: "go version", Uses: tt.uses}},
+			}
+			err := config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func scheduleEntries(entries []ScheduleEntry) map[string]ScheduleEntry {
+	byID := make(map[string]ScheduleEntry, len(entries))
+	for _, entry := range entries {
+		byID[entry.TaskID] = entry
+	}
+	return byID
+}
+
+func TestExecutor_Resources(t *testing.T) {
+	executor := NewExecutor(3, false)
+	executor.SetResources(map[string]int{"db": 1, "cpu": 4})
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "migrate", Name: "Migrate", Type: TaskTypeCommand, Command: "go version", Uses: ResourceUses{"db": 1}},
+		{ID: "seed", Name: "Seed", Type: TaskTypeCommand, Command: "go version", Uses: ResourceUses{"db": 1, "cpu": 1}},
+		{ID: "lint", Name: "Lint", Type: TaskTypeCommand, Command: "go version", Uses: ResourceUses{"cpu": 2}},
+	}))
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+
+	schedule := scheduleEntries(executor.Schedule())
+	migrate, seed, lint := schedule["migrate"], schedule["seed"], schedule["lint"]
+	assert.GreaterOrEqual(t, seed.Start, migrate.End, "seed waits for the database")
+	assert.Equal(t, "resource db", seed.Blocked)
+	assert.Less(t, lint.Start, migrate.End, "lint runs next to migrate")
+	assert.Empty(t, lint.Blocked)
+
+	executor.SetResources(map[string]int{"db": 1})
+	require.NoError(t, executor.AddTask(&Task{ID: "huge", Name: "Huge", Type: TaskTypeCommand, Command: "go version", Uses: ResourceUses{"db": 2}}))
+	assert.EqualError(t, executor.ExecuteAll(context.Background()), "task huge uses 2 of resource db, which has a capacity of 1")
+}
+
+func TestExecutor_Exclusive(t *testing.T) {
+	executor := NewExecutor(3, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "bench", Name: "Bench", Type: TaskTypeCommand, Command: "go version", Exclusive: true},
+		{ID: "lint", Name: "Lint", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "vet", Name: "Vet", Type: TaskTypeCommand, Command: "go version"},
+	}))
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+
+	schedule := scheduleEntries(executor.Schedule())
+	build, bench, lint, vet := schedule["build"], schedule["bench"], schedule["lint"], schedule["vet"]
+	assert.GreaterOrEqual(t, bench.Start, build.End, "bench waits for running tasks")
+	assert.Equal(t, "running tasks, as it is exclusive", bench.Blocked)
+	for _, entry := range []ScheduleEntry{lint, vet} {
+		assert.GreaterOrEqual(t, entry.Start, bench.End, "%s does not start past a waiting exclusive task", entry.TaskID)
+		assert.Equal(t, "exclusive task bench", entry.Blocked)
+	}
+}
@@ -0,0 +1,60 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2026, time.January, 15, 10, 30, 0, 0, time.UTC) // Thursday
+
+	tests := []struct {
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{spec: "*/15 * * * *", want: time.Date(2026, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 2 * * *", want: time.Date(2026, time.January, 16, 2, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * mon-fri", want: time.Date(2026, time.January, 16, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 feb *", want: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "30 10 * * 7", want: time.Date(2026, time.January, 18, 10, 30, 0, 0, time.UTC)},
+		{spec: "@hourly", want: time.Date(2026, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "@weekly", want: time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC)},
+		{spec: "@every 5m", want: time.Date(2026, time.January, 15, 10, 35, 0, 0, time.UTC)},
+		{spec: "", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "@every 10ms", wantErr: true},
+		{spec: "@sometimes", wantErr: true},
+		{spec: "0 0 30 2 *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(base))
+		})
+	}
+}
+
+func TestTask_ValidateSchedule(t *testing.T) {
+	task := &Task{
+		ID:       "nightly",
+		Name:     "Nightly",
+		Type:     TaskTypeCommand,
+		Command:  "go version",
+		Schedule: "not a schedule",
+	}
+	assert.Error(t, task.Validate())
+
+	task.Schedule = "0 3 * * *"
+	assert.NoError(t, task.Validate())
+}
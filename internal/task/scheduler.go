@@ -0,0 +1,416 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ScheduleMode decides which ready task starts first when more tasks are
+// ready than there are free slots
+type ScheduleMode string
+
+const (
+	ScheduleOrder        ScheduleMode = "order"         // Highest priority, then configuration order
+	ScheduleCriticalPath ScheduleMode = "critical-path" // Highest priority, then longest remaining critical path
+)
+
+// Validate checks that the schedule mode is known
+func (m ScheduleMode) Validate() error {
+	switch m {
+	case "", ScheduleOrder, ScheduleCriticalPath:
+		return nil
+	}
+	return fmt.Errorf("unknown schedule mode: %s", m)
+}
+
+// estimateRuns is how many recent completed runs a duration estimate averages
+const estimateRuns = 5
+
+// EstimateDurations estimates the duration of each task as the average of
+// its most recent completed runs in history
+func EstimateDurations(records []HistoryRecord) map[string]time.Duration {
+	recent := make(map[string][]time.Duration)
+	for _, record := range records {
+		if record.Status != StatusCompleted {
+			continue
+		}
+		durations := append(recent[record.TaskID], record.Duration)
+		if len(durations) > estimateRuns {
+			durations = durations[1:]
+		}
+		recent[record.TaskID] = durations
+	}
+
+	estimates := make(map[string]time.Duration, len(recent))
+	for id, durations := range recent {
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		estimates[id] = total / time.Duration(len(durations))
+	}
+	return estimates
+}
+
+// ScheduleEntry records when a task of the latest run became ready and
+// started, and why it started when it did. Times are relative to the start
+// of the run.
+type ScheduleEntry struct {
+	TaskID   string
+	Priority int
+	// Estimate is the expected duration of the task, Path the expected
+	// duration of the longest chain of tasks starting with it
+	Estimate time.Duration
+	Path     time.Duration
+	Ready    time.Duration
+	Start    time.Duration
+	End      time.Duration
+	// WaitedFor is the dependency whose completion made the task ready
+	WaitedFor string
+	// Blocked is what first kept the task from starting once it was ready,
+	// e.g. "resource db"
+	Blocked string
+	// Ahead lists the ready tasks the task was started before, Reason why
+	Ahead   []string
+	Reason  string
+	Skipped bool
+	Started bool
+}
+
+// SetSchedule sets how the following runs pick among ready tasks. The
+// critical-path mode uses estimates, typically from EstimateDurations;
+// tasks without one are assumed to take the average estimate.
+func (e *Executor) SetSchedule(mode ScheduleMode, estimates map[string]time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scheduleMode = mode
+	e.estimates = estimates
+}
+
+// Schedule returns the scheduling decisions of the latest run, in start
+// order. Skipped tasks come last.
+func (e *Executor) Schedule() []ScheduleEntry {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]ScheduleEntry(nil), e.schedule...)
+}
+
+// scheduler tracks the tasks of one run: which are ready, which still wait
+// for dependencies, and the decisions made so far
+type scheduler struct {
+	e     *Executor
+	mode  ScheduleMode
+	start time.Time
+
+	tasks      map[string]*Task
+	index      map[string]int
+	pending    map[string]int
+	dependents map[string][]string
+	entries    map[string]*ScheduleEntry
+	ready      []string
+	// remaining counts the tasks neither started nor skipped
+	remaining int
+
+	// running counts the started tasks that have not finished; they hold
+	// inUse of each resource, and exclusive is set while an exclusive task
+	// runs
+	running   int
+	capacity  map[string]int
+	inUse     map[string]int
+	exclusive string
+}
+
+// newScheduler prepares a run of the tasks in order, which must list
+// dependencies before their dependents
+func (e *Executor) newScheduler(order []string) *scheduler {
+	e.mu.RLock()
+	s := &scheduler{
+		e:          e,
+		mode:       e.scheduleMode,
+		start:      time.Now(),
+		tasks:      make(map[string]*Task, len(order)),
+		index:      make(map[string]int, len(order)),
+		pending:    make(map[string]int, len(order)),
+		dependents: make(map[string][]string, len(order)),
+		entries:    make(map[string]*ScheduleEntry, len(order)),
+		remaining:  len(order),
+		capacity:   e.resources,
+		inUse:      make(map[string]int),
+	}
+	for _, id := range order {
+		s.tasks[id] = e.tasks[id]
+		s.index[id] = e.index[id]
+	}
+	estimates := e.estimates
+	e.mu.RUnlock()
+
+	for _, id := range order {
+		for _, dep := range s.tasks[id].DependsOn {
+			if _, ok := s.tasks[dep]; ok {
+				s.pending[id]++
+				s.dependents[dep] = append(s.dependents[dep], id)
+			}
+		}
+	}
+
+	// Tasks without history are assumed to take as long as the average
+	// known task, so they are neither favoured nor starved
+	var known, total time.Duration
+	for _, id := range order {
+		if d, ok := estimates[id]; ok {
+			known++
+			total += d
+		}
+	}
+	fallback := time.Duration(0)
+	if known > 0 {
+		fallback = total / known
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		estimate, ok := estimates[id]
+		if !ok {
+			estimate = fallback
+		}
+		var longest time.Duration
+		for _, dependent := range s.dependents[id] {
+			if path := s.entries[dependent].Path; path > longest {
+				longest = path
+			}
+		}
+		s.entries[id] = &ScheduleEntry{
+			TaskID:   id,
+			Priority: s.tasks[id].Priority,
+			Estimate: estimate,
+			Path:     estimate + longest,
+		}
+	}
+
+	for _, id := range order {
+		if s.pending[id] == 0 {
+			s.makeReady(id, "")
+		}
+	}
+	return s
+}
+
+// makeReady queues a task whose dependencies in the run have finished, or
+// skips it if any of its dependencies did not succeed
+func (s *scheduler) makeReady(id, after string) {
+	entry := s.entries[id]
+	entry.Ready = time.Since(s.start)
+	entry.WaitedFor = after
+
+	task := s.tasks[id]
+	if s.e.checkDependencies(task) {
+		s.ready = append(s.ready, id)
+		return
+	}
+	entry.Skipped = true
+	s.remaining--
+	s.e.skip(task, "dependencies failed")
+	s.release(id)
+}
+
+// release marks a task as finished, readying dependents with no other
+// unfinished dependencies
+func (s *scheduler) release(id string) {
+	for _, dependent := range s.dependents[id] {
+		s.pending[dependent]--
+		if s.pending[dependent] == 0 {
+			s.makeReady(dependent, id)
+		}
+	}
+}
+
+// before reports whether ready task a should start before b
+func (s *scheduler) before(a, b string) bool {
+	ea, eb := s.entries[a], s.entries[b]
+	if ea.Priority != eb.Priority {
+		return ea.Priority > eb.Priority
+	}
+	if s.mode == ScheduleCriticalPath && ea.Path != eb.Path {
+		return ea.Path > eb.Path
+	}
+	return s.index[a] < s.index[b]
+}
+
+// reason explains why ready task a starts before b
+func (s *scheduler) reason(a, b string) string {
+	ea, eb := s.entries[a], s.entries[b]
+	switch {
+	case ea.Priority != eb.Priority:
+		return fmt.Sprintf("priority %d > %d", ea.Priority, eb.Priority)
+	case s.mode == ScheduleCriticalPath && ea.Path != eb.Path:
+		return fmt.Sprintf("critical path %s > %s", ea.Path.Round(time.Millisecond), eb.Path.Round(time.Millisecond))
+	default:
+		return "configuration order"
+	}
+}
+
+// next removes the ready task to start next from the queue and acquires
+// its resources. Tasks that do not fit next to the running ones are passed
+// over, except exclusive tasks: nothing starts while one waits, so it is
+// not starved. Returns nil if no task can start.
+func (s *scheduler) next() *Task {
+	candidates := append([]string(nil), s.ready...)
+	sort.Slice(candidates, func(i, j int) bool { return s.before(candidates[i], candidates[j]) })
+
+	pick := -1
+	for i, id := range candidates {
+		blocker := s.blocker(s.tasks[id])
+		if blocker == "" {
+			pick = i
+			break
+		}
+		if entry := s.entries[id]; entry.Blocked == "" {
+			entry.Blocked = blocker
+		}
+		if s.tasks[id].Exclusive {
+			break
+		}
+	}
+	if pick < 0 {
+		return nil
+	}
+
+	id := candidates[pick]
+	for i, ready := range s.ready {
+		if ready == id {
+			s.ready = append(s.ready[:i], s.ready[i+1:]...)
+			break
+		}
+	}
+
+	entry := s.entries[id]
+	entry.Started = true
+	entry.Start = time.Since(s.start)
+	if ahead := candidates[pick+1:]; len(ahead) > 0 {
+		entry.Ahead = append([]string(nil), ahead...)
+		entry.Reason = s.reason(id, ahead[0])
+	}
+	s.remaining--
+	s.acquire(s.tasks[id])
+	return s.tasks[id]
+}
+
+// finish records the end of a started task and releases its resources
+func (s *scheduler) finish(id string) {
+	s.entries[id].End = time.Since(s.start)
+	s.releaseResources(s.tasks[id])
+}
+
+// schedule returns the entries of the started tasks in start order,
+// followed by the skipped ones
+func (s *scheduler) schedule() []ScheduleEntry {
+	var started, skipped []ScheduleEntry
+	for _, entry := range s.entries {
+		switch {
+		case entry.Started:
+			started = append(started, *entry)
+		case entry.Skipped:
+			skipped = append(skipped, *entry)
+		}
+	}
+	sort.Slice(started, func(i, j int) bool {
+		if started[i].Start != started[j].Start {
+			return started[i].Start < started[j].Start
+		}
+		return s.index[started[i].TaskID] < s.index[started[j].TaskID]
+	})
+	sort.Slice(skipped, func(i, j int) bool { return s.index[skipped[i].TaskID] < s.index[skipped[j].TaskID] })
+	return append(started, skipped...)
+}
+
+// executeOrder runs the tasks in order on up to concurrency tasks at a
+// time. A task is ready once its dependencies have finished; tasks whose
+// dependencies did not succeed are skipped. Among ready tasks, the
+// scheduler picks by priority, then by the schedule mode.
+func (e *Executor) executeOrder(ctx context.Context, order []string) error {
+	s := e.newScheduler(order)
+	if err := s.checkCapacity(); err != nil {
+		return err
+	}
+	defer func() {
+		e.mu.Lock()
+		e.schedule = s.schedule()
+		e.mu.Unlock()
+	}()
+
+	type finished struct {
+		task   *Task
+		result *TaskResult
+	}
+	done := make(chan finished)
+	var failed, stopped error
+
+	for {
+		for stopped == nil && ctx.Err() == nil && s.running < e.concurrency {
+			task := s.next()
+			if task == nil {
+				break
+			}
+			go func() {
+				done <- finished{task: task, result: e.execute(ctx, task)}
+			}()
+		}
+		if s.running == 0 {
+			break
+		}
+
+		f := <-done
+		s.finish(f.task.ID)
+		e.mu.Lock()
+		e.results[f.task.ID] = f.result
+		e.mu.Unlock()
+
+		if !f.result.Success {
+			err := fmt.Errorf("task %s failed: %w", f.task.ID, f.result.Error)
+			switch {
+			case f.task.OnFailure == FailureContinue:
+				if failed == nil {
+					failed = err
+				}
+			case f.task.OnFailure == FailureStop || f.task.RetryCount == 0:
+				// Without a policy, only tasks that are not retried stop the
+				// run; tasks already running are waited for
+				if stopped == nil {
+					stopped = err
+				}
+				continue
+			}
+		}
+		s.release(f.task.ID)
+	}
+
+	if stopped != nil {
+		return stopped
+	}
+	if err := ctx.Err(); err != nil && s.remaining > 0 {
+		return err
+	}
+	return failed
+}
+
+// skip records a task as skipped for reason, e.g. because its
+// dependencies failed
+func (e *Executor) skip(task *Task, reason string) {
+	task.Status = StatusSkipped
+	e.mu.Lock()
+	e.results[task.ID] = &TaskResult{
+		Task:    task,
+		Success: false,
+		Error:   fmt.Errorf("%s", reason),
+	}
+	e.mu.Unlock()
+	e.emit(Event{
+		Type:     EventTaskSkipped,
+		TaskID:   task.ID,
+		TaskName: task.Name,
+		Status:   StatusSkipped,
+		Error:    reason,
+	})
+}
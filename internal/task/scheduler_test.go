@@ -0,0 +1,103 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateDurations(t *testing.T) {
+	var records []HistoryRecord
+	for i := 1; i <= 7; i++ {
+		records = append(records, HistoryRecord{TaskID: "build", Status: StatusCompleted, Duration: time.Duration(i) * time.Second})
+	}
+	records = append(records,
+		HistoryRecord{TaskID: "build", Status: StatusFailed, Duration: time.Hour},
+		HistoryRecord{TaskID: "lint", Status: StatusSkipped},
+	)
+
+	estimates := EstimateDurations(records)
+	assert.Equal(t, map[string]time.Duration{"build": 5 * time.Second}, estimates)
+}
+
+func scheduleIDs(entries []ScheduleEntry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.TaskID
+	}
+	return ids
+}
+
+func TestExecutor_SchedulePriority(t *testing.T) {
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "a", Name: "A", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "b", Name: "B", Type: TaskTypeCommand, Command: "go version", Priority: 5},
+		{ID: "c", Name: "C", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"a"}},
+		{ID: "d", Name: "D", Type: TaskTypeCommand, Command: "go version"},
+	}))
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+
+	schedule := executor.Schedule()
+	assert.Equal(t, []string{"b", "a", "c", "d"}, scheduleIDs(schedule))
+	assert.Equal(t, []string{"a", "d"}, schedule[0].Ahead)
+	assert.Equal(t, "priority 5 > 0", schedule[0].Reason)
+	assert.Equal(t, "configuration order", schedule[1].Reason)
+	assert.Equal(t, "a", schedule[2].WaitedFor)
+	assert.Equal(t, []string{"d"}, schedule[2].Ahead)
+	assert.Empty(t, schedule[3].Ahead)
+}
+
+func TestExecutor_ScheduleCriticalPath(t *testing.T) {
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "short", Name: "Short", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "long", Name: "Long", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "tail", Name: "Tail", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"long"}},
+		{ID: "new", Name: "New", Type: TaskTypeCommand, Command: "go version"},
+	}))
+	executor.SetSchedule(ScheduleCriticalPath, map[string]time.Duration{
+		"short": time.Second,
+		"long":  2 * time.Second,
+		"tail":  6 * time.Second,
+	})
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+
+	schedule := executor.Schedule()
+	assert.Equal(t, []string{"long", "tail", "new", "short"}, scheduleIDs(schedule))
+	assert.Equal(t, 8*time.Second, schedule[0].Path)
+	assert.Equal(t, "critical path 8s > 3s", schedule[0].Reason)
+	assert.Equal(t, 3*time.Second, schedule[2].Estimate, "tasks without history take the average estimate")
+
+	executor.SetSchedule(ScheduleOrder, nil)
+	require.NoError(t, executor.ExecuteAll(context.Background()))
+	assert.Equal(t, []string{"short", "long", "tail", "new"}, scheduleIDs(executor.Schedule()))
+}
+
+func TestExecutor_ScheduleConcurrent(t *testing.T) {
+	executor := NewExecutor(2, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "a", Name: "A", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "b", Name: "B", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "c", Name: "C", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"a", "b"}},
+		{ID: "broken", Name: "Broken", Type: TaskTypeCommand, Command: "nonexistent-command-12345", OnFailure: FailureContinue},
+		{ID: "after", Name: "After", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"broken"}},
+	}))
+	assert.Error(t, executor.ExecuteAll(context.Background()))
+
+	schedule := executor.Schedule()
+	require.Len(t, schedule, 5)
+	assert.Equal(t, []string{"a", "b"}, scheduleIDs(schedule[:2]))
+	assert.Less(t, schedule[1].Start, schedule[0].End, "a and b run at the same time")
+	assert.Equal(t, "after", schedule[4].TaskID)
+	assert.True(t, schedule[4].Skipped)
+
+	for _, id := range []string{"a", "b", "c"} {
+		result, ok := executor.GetResult(id)
+		require.True(t, ok, id)
+		assert.True(t, result.Success, id)
+	}
+}
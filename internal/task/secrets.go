@@ -0,0 +1,72 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/yourusername/go-cli-tool/internal/secrets"
+)
+
+// DefaultVaultFile is the default location of the encrypted secrets vault
+var DefaultVaultFile = filepath.Join(StateDir, "secrets.vault")
+
+// SecretResolver resolves secret://<provider>/<name> references in task
+// env values
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to the SecretResolver interface
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve calls f(ctx, ref)
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+type secretEnvKey struct{}
+
+// withSecretEnv returns a context carrying the resolved secret env values
+// of a task attempt
+func withSecretEnv(ctx context.Context, env map[string]string) context.Context {
+	if len(env) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, secretEnvKey{}, env)
+}
+
+// secretEnvFrom returns the resolved secret env values carried by ctx
+func secretEnvFrom(ctx context.Context) map[string]string {
+	env, _ := ctx.Value(secretEnvKey{}).(map[string]string)
+	return env
+}
+
+// resolveSecretEnv resolves the env values of the task that reference a
+// secret provider. Run variables are expanded in the reference first.
+func resolveSecretEnv(ctx context.Context, resolver SecretResolver, t *Task) (map[string]string, error) {
+	names := make([]string, 0, len(t.Env))
+	for name, value := range t.Env {
+		if secrets.IsRef(expandVars(value, varsFrom(ctx))) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if resolver == nil {
+		return nil, fmt.Errorf("env %s references a secret but no secret providers are configured", names[0])
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]string, len(names))
+	for _, name := range names {
+		value, err := resolver.Resolve(ctx, expandVars(t.Env[name], varsFrom(ctx)))
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", name, err)
+		}
+		resolved[name] = value
+	}
+	return resolved, nil
+}
@@ -0,0 +1,39 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ResolvesSecretEnv(t *testing.T) {
+	const proxy = "https://proxy.example.com/resolved-secret"
+	executor := NewExecutor(1, false)
+	executor.SetSecretResolver(SecretResolverFunc(func(_ context.Context, ref string) (string, error) {
+		if ref != "secret://test/PROXY" {
+			return "", fmt.Errorf("unknown secret %s", ref)
+		}
+		return proxy, nil
+	}))
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "ok", Name: "OK", Type: TaskTypeCommand, Command: "go", Args: []string{"env", "GOPROXY"},
+			Env: map[string]string{"GOPROXY": "secret://test/PROXY"}},
+		{ID: "missing", Name: "Missing", Type: TaskTypeCommand, Command: "go version",
+			Env: map[string]string{"TOKEN": "secret://test/${WHICH}"}},
+	}))
+
+	ctx := WithVars(context.Background(), map[string]string{"WHICH": "OTHER"})
+	result, err := executor.ExecuteTask(ctx, "ok")
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Output)
+	assert.Equal(t, MaskedValue, strings.TrimSpace(result.Output))
+
+	result, err = executor.ExecuteTask(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.ErrorContains(t, result.Error, "env TOKEN: unknown secret secret://test/OTHER")
+}
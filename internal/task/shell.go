@@ -0,0 +1,95 @@
+package task
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// commandLine returns the program and arguments that run the task.
+//
+//   - A script task with an interpreter runs the interpreter with the script
+//     path and args.
+//   - A task with a shell runs the command as a script of that shell. POSIX
+//     shells get args as positional parameters ($1, $2, ...), cmd and
+//     PowerShell get them appended to the command. Run variables are never
+//     expanded into the script text, as their values may come from a
+//     webhook request; scripts read them from the environment instead.
+//   - Otherwise the command runs directly with args, or is split on spaces
+//     when there are no args. Splitting happens before variables are
+//     expanded, so a value never adds arguments.
+func (t *Task) commandLine(vars map[string]string) ([]string, error) {
+	command := expandVars(t.Command, vars)
+	args := make([]string, len(t.Args))
+	for i, arg := range t.Args {
+		args[i] = expandVars(arg, vars)
+	}
+
+	var argv []string
+	switch {
+	case t.Type == TaskTypeScript && t.Interpreter != "":
+		argv = append(expandFields(t.Interpreter, vars), command)
+		argv = append(argv, args...)
+	case t.Shell != "":
+		shell := expandFields(t.Shell, vars)
+		shellArgs := args
+		if len(shell) > 0 && shellKind(shell[0]) != "posix" {
+			// Appended to the script, so not expanded either
+			shellArgs = t.Args
+		}
+		argv = shellCommandLine(shell, t.Command, shellArgs, t.ID)
+	case len(args) > 0:
+		argv = append([]string{command}, args...)
+	default:
+		argv = expandFields(t.Command, vars)
+	}
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+	return argv, nil
+}
+
+// shellCommandLine builds the command line running script with a shell. A
+// shell given as a single program gets its usual script flag; with more
+// fields, e.g. "bash -eo pipefail -c", they are used as they are.
+func shellCommandLine(shell []string, script string, args []string, name string) []string {
+	if len(shell) == 0 {
+		return nil
+	}
+	kind := shellKind(shell[0])
+	argv := append([]string(nil), shell...)
+	if len(shell) == 1 {
+		switch kind {
+		case "cmd":
+			argv = append(argv, "/C")
+		case "powershell":
+			argv = append(argv, "-NoProfile", "-Command")
+		default:
+			argv = append(argv, "-c")
+		}
+	}
+
+	if kind == "posix" {
+		// $0 is the task ID, so error messages of the shell name the task
+		argv = append(argv, script, name)
+		return append(argv, args...)
+	}
+	if len(args) > 0 {
+		script += " " + strings.Join(args, " ")
+	}
+	return append(argv, script)
+}
+
+// shellKind classifies a shell program as "cmd", "powershell" or "posix"
+func shellKind(program string) string {
+	base := strings.ToLower(filepath.Base(program))
+	base = strings.TrimSuffix(base, ".exe")
+	switch base {
+	case "cmd":
+		return "cmd"
+	case "powershell", "pwsh":
+		return "powershell"
+	default:
+		return "posix"
+	}
+}
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_CommandLine(t *testing.T) {
+	vars := map[string]string{"TARGET": "linux", "EMPTY": ""}
+
+	tests := []struct {
+		name string
+		task Task
+		want []string
+	}{
+		{
+			name: "split command",
+			task: Task{Type: TaskTypeCommand, Command: "go build ./..."},
+			want: []string{"go", "build", "./..."},
+		},
+		{
+			name: "split before expanding",
+			task: Task{Type: TaskTypeCommand, Command: "git checkout ${TARGET} ${EMPTY}"},
+			want: []string{"git", "checkout", "linux"},
+		},
+		{
+			name: "command with args",
+			task: Task{Type: TaskTypeCommand, Command: "go", Args: []string{"env", "${TARGET}"}},
+			want: []string{"go", "env", "linux"},
+		},
+		{
+			name: "posix shell with positional args",
+			task: Task{ID: "build", Type: TaskTypeCommand, Shell: "bash", Command: `make "$1" | tee log`, Args: []string{"${TARGET}"}},
+			want: []string{"bash", "-c", `make "$1" | tee log`, "build", "linux"},
+		},
+		{
+			name: "shell with explicit flags",
+			task: Task{ID: "build", Type: TaskTypeCommand, Shell: "bash -eo pipefail -c", Command: "make && make test"},
+			want: []string{"bash", "-eo", "pipefail", "-c", "make && make test", "build"},
+		},
+		{
+			name: "posix shell script is not expanded",
+			task: Task{ID: "build", Type: TaskTypeCommand, Shell: "sh", Command: `echo "${TARGET}"`},
+			want: []string{"sh", "-c", `echo "${TARGET}"`, "build"},
+		},
+		{
+			name: "cmd args are not expanded",
+			task: Task{Type: TaskTypeCommand, Shell: "cmd", Command: "echo", Args: []string{"${TARGET}"}},
+			want: []string{"cmd", "/C", "echo ${TARGET}"},
+		},
+		{
+			name: "cmd appends args",
+			task: Task{Type: TaskTypeCommand, Shell: "cmd.exe", Command: "dir", Args: []string{"/b"}},
+			want: []string{"cmd.exe", "/C", "dir /b"},
+		},
+		{
+			name: "powershell",
+			task: Task{Type: TaskTypeCommand, Shell: "pwsh", Command: "Get-ChildItem"},
+			want: []string{"pwsh", "-NoProfile", "-Command", "Get-ChildItem"},
+		},
+		{
+			name: "script with interpreter",
+			task: Task{Type: TaskTypeScript, Interpreter: "python3 -u", Command: "scripts/report.py", Args: []string{"--target", "${TARGET}"}},
+			want: []string{"python3", "-u", "scripts/report.py", "--target", "linux"},
+		},
+		{
+			name: "interpreter is ignored for commands",
+			task: Task{Type: TaskTypeCommand, Interpreter: "python3", Command: "go version"},
+			want: []string{"go", "version"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argv, err := tt.task.commandLine(vars)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, argv)
+		})
+	}
+
+	_, err := (&Task{Type: TaskTypeCommand, Command: "  "}).commandLine(nil)
+	assert.ErrorContains(t, err, "empty command")
+}
+
+func TestTask_ExecuteWithShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	task := &Task{
+		ID: "shell", Name: "Shell", Type: TaskTypeCommand,
+		Shell:   "sh",
+		Command: `go env GOOS && echo "arg=$1"`,
+		Args:    []string{"value with spaces"},
+	}
+	result := task.Execute(context.Background())
+	require.NoError(t, result.Error)
+	assert.Equal(t, runtime.GOOS+"\narg=value with spaces", strings.TrimSpace(result.Output))
+}
+
+func TestTask_ShellVarsCannotInject(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	task := &Task{
+		ID: "deploy", Name: "Deploy", Type: TaskTypeCommand,
+		Shell:   "sh",
+		Command: `echo "ref=${WEBHOOK_REF}"`,
+	}
+	ctx := WithVars(context.Background(), map[string]string{"WEBHOOK_REF": `"; echo injected; "`})
+	result := task.Execute(ctx)
+	require.NoError(t, result.Error)
+	assert.Equal(t, `ref="; echo injected; "`, strings.TrimSpace(result.Output))
+}
+
+func TestTask_CommandVarsStayOneArgument(t *testing.T) {
+	vars := map[string]string{"WEBHOOK_REF": "main --force -o /etc/x"}
+
+	argv, err := (&Task{Type: TaskTypeCommand, Command: "git push origin ${WEBHOOK_REF}"}).commandLine(vars)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "push", "origin", "main --force -o /etc/x"}, argv)
+
+	argv, err = (&Task{Type: TaskTypeScript, Interpreter: "python3 ${WEBHOOK_REF}", Command: "deploy.py"}).commandLine(vars)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"python3", "main --force -o /etc/x", "deploy.py"}, argv)
+}
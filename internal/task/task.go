@@ -1,11 +1,17 @@
 package task
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // TaskType defines the type of task
@@ -28,19 +34,111 @@ const (
 	StatusSkipped   TaskStatus = "skipped"
 )
 
+// TriggerType defines what can start a task besides the CLI
+type TriggerType string
+
+const (
+	TriggerTypeWebhook TriggerType = "webhook" // Incoming HTTP webhook
+)
+
+// Trigger starts a task in response to an external event
+type Trigger struct {
+	Type TriggerType `yaml:"type" json:"type"`
+	// Path is the URL path the webhook is served on
+	Path string `yaml:"path" json:"path"`
+	// Secret verifies GitHub-style HMAC signatures or GitLab tokens.
+	// SecretEnv names an environment variable holding the secret instead.
+	Secret    string `yaml:"secret,omitempty" json:"-"`
+	SecretEnv string `yaml:"secret_env,omitempty" json:"secret_env,omitempty"`
+}
+
+// Validate checks if the trigger configuration is valid
+func (tr Trigger) Validate() error {
+	switch tr.Type {
+	case TriggerTypeWebhook:
+		if !strings.HasPrefix(tr.Path, "/") {
+			return fmt.Errorf("webhook trigger path must start with /")
+		}
+	default:
+		return fmt.Errorf("unknown trigger type: %s", tr.Type)
+	}
+	return nil
+}
+
+// WebhookSecret returns the secret the webhook payload must be signed with
+func (tr Trigger) WebhookSecret() string {
+	if tr.SecretEnv != "" {
+		return os.Getenv(tr.SecretEnv)
+	}
+	return tr.Secret
+}
+
+// FailurePolicy decides whether a failed task stops the run
+type FailurePolicy string
+
+const (
+	FailureStop     FailurePolicy = "stop"     // Stop the run, tasks not started yet are not run
+	FailureContinue FailurePolicy = "continue" // Keep running tasks that do not depend on the failed one
+)
+
+// Validate checks that the failure policy is known
+func (p FailurePolicy) Validate() error {
+	switch p {
+	case "", FailureStop, FailureContinue:
+		return nil
+	}
+	return fmt.Errorf("unknown failure policy: %s", p)
+}
+
 // Task represents a single automation task
 type Task struct {
 	ID          string            `yaml:"id" json:"id"`
 	Name        string            `yaml:"name" json:"name"`
 	Description string            `yaml:"description" json:"description"`
+	Extends     StringList        `yaml:"extends" json:"extends"`
 	Type        TaskType          `yaml:"type" json:"type"`
 	Command     string            `yaml:"command" json:"command"`
 	Args        []string          `yaml:"args" json:"args"`
 	WorkDir     string            `yaml:"workdir" json:"workdir"`
 	Env         map[string]string `yaml:"env" json:"env"`
+	EnvFile     StringList        `yaml:"env_file" json:"env_file"`
+	EnvInherit  EnvInherit        `yaml:"env_inherit,omitempty" json:"env_inherit"`
+	EnvRemove   []string          `yaml:"env_remove" json:"env_remove"`
+	PathPrepend []string          `yaml:"path_prepend" json:"path_prepend"`
 	Timeout     time.Duration     `yaml:"timeout" json:"timeout"`
 	RetryCount  int               `yaml:"retry_count" json:"retry_count"`
 	DependsOn   []string          `yaml:"depends_on" json:"depends_on"`
+	Sources     []string          `yaml:"sources" json:"sources"`
+	Watch       []string          `yaml:"watch" json:"watch"`
+	Schedule    string            `yaml:"schedule" json:"schedule"`
+	Triggers    []Trigger         `yaml:"triggers" json:"triggers"`
+	Shell       string            `yaml:"shell" json:"shell"`
+	Interpreter string            `yaml:"interpreter" json:"interpreter"`
+	OutputMode  OutputMode        `yaml:"output" json:"output_mode"`
+	OnFailure   FailurePolicy     `yaml:"on_failure" json:"on_failure"`
+	// Priority orders ready tasks: higher priorities start first
+	Priority int `yaml:"priority" json:"priority"`
+	// Uses lists the resources the task holds while it runs, Exclusive
+	// tasks run alone
+	Uses      ResourceUses `yaml:"uses" json:"uses,omitempty"`
+	Exclusive bool         `yaml:"exclusive" json:"exclusive"`
+	// Lock names a lock shared across processes: tasks with the same lock
+	// never run at the same time in the workspace
+	Lock string `yaml:"lock" json:"lock,omitempty"`
+	// Limits caps the memory, CPU time, open files and processes of the
+	// task on Linux
+	Limits *Limits `yaml:"limits,omitempty" json:"limits,omitempty"`
+	// Hooks run before and after the task
+	Hooks *TaskHooks `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+	// Vars are variables of this task, set on top of the run variables
+	Vars   map[string]string `yaml:"vars" json:"vars"`
+	Matrix *Matrix           `yaml:"matrix,omitempty" json:"-"`
+	// Foreach runs one child task per item, computed at run time
+	Foreach *Foreach `yaml:"foreach,omitempty" json:"foreach,omitempty"`
+	// Group is the ID of the matrix task an instance was expanded from
+	Group string `yaml:"-" json:"group,omitempty"`
+	// Secrets names env entries and run variables whose values are masked
+	Secrets []string `yaml:"secrets" json:"secrets"`
 
 	// Runtime fields
 	Status    TaskStatus `yaml:"-" json:"status"`
@@ -48,6 +146,28 @@ type Task struct {
 	EndTime   time.Time  `yaml:"-" json:"end_time"`
 	Output    string     `yaml:"-" json:"output"`
 	Error     string     `yaml:"-" json:"error"`
+
+	// explicit holds the keys set in the configuration file, including keys
+	// set to null, so defaults only fill in keys that were left out
+	explicit map[string]bool
+}
+
+// UnmarshalYAML decodes the task and records which keys were set
+func (t *Task) UnmarshalYAML(node *yaml.Node) error {
+	type plain Task
+	if err := node.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.explicit = make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		t.explicit[node.Content[i].Value] = true
+	}
+	return nil
+}
+
+// isSet reports whether key was set in the configuration file
+func (t *Task) isSet(key string) bool {
+	return t.explicit[key]
 }
 
 // TaskResult represents the result of task execution
@@ -58,6 +178,9 @@ type TaskResult struct {
 	Error    error
 	Duration time.Duration
 	ExitCode int
+	// FailureReason is set when the task failed for a known reason, such
+	// as exceeding one of its limits
+	FailureReason FailureReason
 }
 
 // Execute runs the task
@@ -67,6 +190,7 @@ func (t *Task) Execute(ctx context.Context) *TaskResult {
 		Success: false,
 	}
 
+	ctx = WithVars(ctx, t.Vars)
 	t.Status = StatusRunning
 	t.StartTime = time.Now()
 	defer func() {
@@ -84,79 +208,91 @@ func (t *Task) Execute(ctx context.Context) *TaskResult {
 	// Execute based on task type
 	switch t.Type {
 	case TaskTypeCommand:
-		return t.executeCommand(ctx, result)
+		t.executeCommand(ctx, result)
 	case TaskTypeScript:
-		return t.executeScript(ctx, result)
+		t.executeScript(ctx, result)
 	case TaskTypeHTTP:
-		return t.executeHTTP(ctx, result)
+		t.executeHTTP(ctx, result)
 	default:
 		result.Error = fmt.Errorf("unknown task type: %s", t.Type)
 		t.Status = StatusFailed
 		t.Error = result.Error.Error()
-		return result
 	}
+
+	if t.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		loggerFrom(ctx, t).Warn("task timed out", "timeout", t.Timeout)
+	}
+	return result
 }
 
 // executeCommand executes a shell command
 func (t *Task) executeCommand(ctx context.Context, result *TaskResult) *TaskResult {
-	var cmd *exec.Cmd
-
-	// Combine command and args
-	if len(t.Args) > 0 {
-		// #nosec G204 -- Command and args are from user-controlled task configuration files
-		cmd = exec.CommandContext(ctx, t.Command, t.Args...)
-	} else {
-		// Parse command string
-		parts := strings.Fields(t.Command)
-		if len(parts) == 0 {
-			result.Error = fmt.Errorf("empty command")
-			t.Status = StatusFailed
-			t.Error = result.Error.Error()
-			return result
-		}
-		// #nosec G204 -- Command is from user-controlled task configuration files
-		cmd = exec.CommandContext(ctx, parts[0], parts[1:]...)
+	vars := varsFrom(ctx)
+
+	argv, err := t.commandLine(vars)
+	if err != nil {
+		result.Error = err
+		t.Status = StatusFailed
+		t.Error = result.Error.Error()
+		return result
 	}
+	// #nosec G204 -- Command and args are from user-controlled task configuration files; untrusted ones are restricted with a Policy
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
 
 	// Set working directory
 	if t.WorkDir != "" {
-		cmd.Dir = t.WorkDir
+		cmd.Dir = expandVars(t.WorkDir, vars)
 	}
 
 	// Set environment variables
-	if len(t.Env) > 0 {
-		env := cmd.Environ()
-		for k, v := range t.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
+	if env := t.environ(ctx, cmd, vars); env != nil {
 		cmd.Env = env
 	}
 
+	// Capture combined output, streaming it as well if requested
+	var output bytes.Buffer
+	var out io.Writer = &output
+	if stream := outputFrom(ctx); stream != nil {
+		out = io.MultiWriter(&output, stream)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
 	// Execute command
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
+	logger := loggerFrom(ctx, t)
+	limits := newLimiter(t, logger)
+	defer limits.close()
+	logger.Debug("running command", "args", cmd.Args, "dir", cmd.Dir)
+	limits.prepare(cmd)
+	err = cmd.Run()
+	result.Output = output.String()
 	t.Output = result.Output
 
 	if err != nil {
+		if breach := limits.breach(cmd.ProcessState, err); breach != nil {
+			result.FailureReason = breach.Reason
+			err = breach
+		}
 		result.Error = err
 		t.Status = StatusFailed
 		t.Error = err.Error()
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
 			result.ExitCode = exitErr.ExitCode()
 		}
+		logger.Debug("command failed", "exit_code", result.ExitCode, "error", err)
 		return result
 	}
 
+	logger.Debug("command finished", "exit_code", 0)
 	result.Success = true
 	result.ExitCode = 0
 	t.Status = StatusCompleted
 	return result
 }
 
-// executeScript executes a script file
+// executeScript executes a script file, with the task's interpreter if set
 func (t *Task) executeScript(ctx context.Context, result *TaskResult) *TaskResult {
-	// For now, treat script as command execution
-	// Can be extended to support different script interpreters
 	return t.executeCommand(ctx, result)
 }
 
@@ -184,9 +320,124 @@ func (t *Task) Validate() error {
 	if t.Command == "" {
 		return fmt.Errorf("task command is required")
 	}
+	if err := t.OutputMode.Validate(); err != nil {
+		return err
+	}
+	if err := t.OnFailure.Validate(); err != nil {
+		return err
+	}
+	if t.Limits != nil {
+		if err := t.Limits.validate(); err != nil {
+			return err
+		}
+	}
+	if err := t.Hooks.validate(); err != nil {
+		return err
+	}
+	if t.Foreach != nil {
+		if err := t.Foreach.validate(t); err != nil {
+			return err
+		}
+	}
+	if t.Schedule != "" {
+		if _, err := ParseSchedule(t.Schedule); err != nil {
+			return err
+		}
+	}
+	for _, trigger := range t.Triggers {
+		if err := trigger.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Clone returns a copy of the task definition with fresh runtime state, so
+// the copy can be executed independently of the original.
+func (t *Task) Clone() *Task {
+	clone := *t
+	clone.Args = append([]string(nil), t.Args...)
+	clone.DependsOn = append([]string(nil), t.DependsOn...)
+	clone.Sources = append([]string(nil), t.Sources...)
+	clone.Watch = append([]string(nil), t.Watch...)
+	clone.Triggers = append([]Trigger(nil), t.Triggers...)
+	clone.Secrets = append([]string(nil), t.Secrets...)
+	clone.Extends = append(StringList(nil), t.Extends...)
+	if t.Vars != nil {
+		clone.Vars = make(map[string]string, len(t.Vars))
+		for k, v := range t.Vars {
+			clone.Vars[k] = v
+		}
+	}
+	if t.Uses != nil {
+		clone.Uses = make(ResourceUses, len(t.Uses))
+		for k, v := range t.Uses {
+			clone.Uses[k] = v
+		}
+	}
+	if t.Limits != nil {
+		limits := *t.Limits
+		clone.Limits = &limits
+	}
+	if t.Hooks != nil {
+		hooks := *t.Hooks
+		clone.Hooks = &hooks
+	}
+	clone.EnvFile = append(StringList(nil), t.EnvFile...)
+	if t.EnvInherit.Allow != nil {
+		clone.EnvInherit.Allow = append([]string{}, t.EnvInherit.Allow...)
+	}
+	clone.EnvRemove = append([]string(nil), t.EnvRemove...)
+	clone.PathPrepend = append([]string(nil), t.PathPrepend...)
+	if t.Env != nil {
+		clone.Env = make(map[string]string, len(t.Env))
+		for k, v := range t.Env {
+			clone.Env[k] = v
+		}
+	}
+	clone.Status = StatusPending
+	clone.StartTime = time.Time{}
+	clone.EndTime = time.Time{}
+	clone.Output = ""
+	clone.Error = ""
+	return &clone
+}
+
+// SecretValues returns the values of the task's secrets, as declared in its
+// env and after expanding run variables, and of secret run variables
+func (t *Task) SecretValues(vars map[string]string) []string {
+	var values []string
+	for _, name := range t.Secrets {
+		if v, ok := t.Env[name]; ok {
+			values = append(values, v, expandVars(v, vars))
+		}
+		if v, ok := vars[name]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Redacted returns a copy of the task with its secret env values masked
+func (t *Task) Redacted() *Task {
+	clone := t.Clone()
+	for _, name := range t.Secrets {
+		if _, ok := clone.Env[name]; ok {
+			clone.Env[name] = MaskedValue
+		}
+	}
+	return clone
+}
+
+// WatchPatterns returns the file globs that trigger a re-run of the task in
+// watch mode. An explicit watch list takes precedence over the sources.
+func (t *Task) WatchPatterns() []string {
+	if len(t.Watch) > 0 {
+		return t.Watch
+	}
+	return t.Sources
+}
+
 // String returns a string representation of the task
 func (t *Task) String() string {
 	return fmt.Sprintf("Task[%s: %s]", t.ID, t.Name)
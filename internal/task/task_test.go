@@ -1,7 +1,9 @@
 package task
 
 import (
+	"bytes"
 	"context"
+	"runtime"
 	"testing"
 	"time"
 
@@ -231,3 +233,43 @@ func TestExecutor_ExecuteWithRetry(t *testing.T) {
 	assert.False(t, result.Success)
 	assert.NotNil(t, result.Error)
 }
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"REF": "main", "ENV": "prod"}
+
+	assert.Equal(t, "deploy main to prod", expandVars("deploy ${REF} to ${ENV}", vars))
+	assert.Equal(t, "keep ${UNKNOWN} and $HOME", expandVars("keep ${UNKNOWN} and $HOME", vars))
+	assert.Equal(t, "unterminated ${REF", expandVars("unterminated ${REF", vars))
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"a=1", "b=x=y", "c="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "x=y", "c": ""}, vars)
+
+	_, err = ParseVars([]string{"novalue"})
+	assert.Error(t, err)
+}
+
+func TestExecutor_StreamsOutput(t *testing.T) {
+	executor := NewExecutor(1, false)
+	var console, output bytes.Buffer
+	executor.Subscribe(NewConsoleSink(&console, true))
+
+	tasks := []*Task{{
+		ID:      "goos",
+		Name:    "GOOS",
+		Type:    TaskTypeCommand,
+		Command: "go",
+		Args:    []string{"env", "${WHICH}"},
+	}}
+	executor.Subscribe(NewOutputSink(&output, tasks, OutputStream))
+	require.NoError(t, executor.AddTasks(tasks))
+
+	ctx := WithVars(context.Background(), map[string]string{"WHICH": "GOOS"})
+	result, err := executor.ExecuteTask(ctx, "goos")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Contains(t, console.String(), "[goos] "+runtime.GOOS+"\n")
+	assert.Equal(t, "[goos] "+runtime.GOOS+"\n", output.String())
+}
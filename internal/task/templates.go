@@ -0,0 +1,215 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// appendTag marks a list in a task or template that is appended to the
+// inherited list instead of replacing it
+const appendTag = "!append"
+
+// resolveTemplates replaces every task in the config document that extends
+// templates with the merged result. Templates are merged in the order they
+// are listed, then the task itself is merged on top:
+//
+//   - maps (e.g. env) are merged key by key, recursively
+//   - lists replace the inherited list, unless tagged !append
+//   - any other value replaces the inherited value
+//
+// Templates may extend other templates.
+func resolveTemplates(doc *yaml.Node) error {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	r := &templateResolver{
+		templates: make(map[string]*yaml.Node),
+		resolved:  make(map[string]*yaml.Node),
+	}
+	if templates := mappingValue(root, "templates"); templates != nil {
+		if templates.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: templates must be a map of template names to tasks", templates.Line)
+		}
+		for i := 0; i+1 < len(templates.Content); i += 2 {
+			r.templates[templates.Content[i].Value] = templates.Content[i+1]
+		}
+	}
+
+	tasks := mappingValue(root, "tasks")
+	if tasks == nil || tasks.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for i, node := range tasks.Content {
+		if node.Kind != yaml.MappingNode {
+			continue
+		}
+		merged, err := r.extend(node, nil)
+		if err != nil {
+			id := "#" + fmt.Sprint(i+1)
+			if v := mappingValue(node, "id"); v != nil {
+				id = v.Value
+			}
+			return fmt.Errorf("task %s: %w", id, err)
+		}
+		tasks.Content[i] = merged
+	}
+	return nil
+}
+
+type templateResolver struct {
+	templates map[string]*yaml.Node
+	resolved  map[string]*yaml.Node
+}
+
+// extend merges node onto the templates it extends. stack holds the
+// templates being resolved, to report cycles.
+func (r *templateResolver) extend(node *yaml.Node, stack []string) (*yaml.Node, error) {
+	names, err := extendsNames(node)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return stripAppendTags(cloneNode(node)), nil
+	}
+
+	var base *yaml.Node
+	for _, name := range names {
+		parent, err := r.template(name, stack)
+		if err != nil {
+			return nil, err
+		}
+		if base == nil {
+			base = cloneNode(parent)
+		} else {
+			base = mergeNodes(base, parent)
+		}
+	}
+	return stripAppendTags(mergeNodes(base, node)), nil
+}
+
+// template returns a template with everything it extends merged in
+func (r *templateResolver) template(name string, stack []string) (*yaml.Node, error) {
+	if resolved, ok := r.resolved[name]; ok {
+		return resolved, nil
+	}
+	for _, n := range stack {
+		if n == name {
+			return nil, fmt.Errorf("template cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	node, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q", name)
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: template %s must be a map", node.Line, name)
+	}
+
+	resolved, err := r.extend(node, append(stack, name))
+	if err != nil {
+		return nil, err
+	}
+	// The extends key of a template is not inherited by its tasks
+	removeMappingKey(resolved, "extends")
+	r.resolved[name] = resolved
+	return resolved, nil
+}
+
+// extendsNames returns the templates a node extends, given as a name or a
+// list of names
+func extendsNames(node *yaml.Node) ([]string, error) {
+	value := mappingValue(node, "extends")
+	if value == nil {
+		return nil, nil
+	}
+	var names StringList
+	if err := value.Decode(&names); err != nil {
+		return nil, fmt.Errorf("extends: %w", err)
+	}
+	return names, nil
+}
+
+// mergeNodes returns over merged onto a copy of base
+func mergeNodes(base, over *yaml.Node) *yaml.Node {
+	switch {
+	case base.Kind == yaml.MappingNode && over.Kind == yaml.MappingNode:
+		merged := cloneNode(base)
+		for i := 0; i+1 < len(over.Content); i += 2 {
+			key, value := over.Content[i], over.Content[i+1]
+			j := mappingIndex(merged, key.Value)
+			if j < 0 {
+				merged.Content = append(merged.Content, cloneNode(key), cloneNode(value))
+				continue
+			}
+			merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+		}
+		return merged
+	case base.Kind == yaml.SequenceNode && over.Kind == yaml.SequenceNode && over.Tag == appendTag:
+		merged := cloneNode(base)
+		for _, item := range over.Content {
+			merged.Content = append(merged.Content, cloneNode(item))
+		}
+		merged.Tag = appendTag
+		return merged
+	default:
+		return cloneNode(over)
+	}
+}
+
+// stripAppendTags turns !append lists back into plain lists once merged
+func stripAppendTags(node *yaml.Node) *yaml.Node {
+	if node.Tag == appendTag {
+		node.Tag = "!!seq"
+	}
+	for _, child := range node.Content {
+		stripAppendTags(child)
+	}
+	return node
+}
+
+// cloneNode returns a deep copy of node
+func cloneNode(node *yaml.Node) *yaml.Node {
+	clone := *node
+	if node.Content != nil {
+		clone.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			clone.Content[i] = cloneNode(child)
+		}
+	}
+	return &clone
+}
+
+// mappingIndex returns the index of key in a mapping node, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
+
+// removeMappingKey deletes key from a mapping node
+func removeMappingKey(node *yaml.Node, key string) {
+	if i := mappingIndex(node, key); i >= 0 {
+		node.Content = append(node.Content[:i], node.Content[i+2:]...)
+	}
+}
@@ -0,0 +1,110 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadConfigString(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return LoadConfig(path)
+}
+
+func TestLoadConfig_Templates(t *testing.T) {
+	config, err := loadConfigString(t, `version: "1.0"
+defaults:
+  timeout: 10s
+  retry_count: 3
+templates:
+  base:
+    type: command
+    retry_count: 0
+    env:
+      A: base
+      B: base
+    sources: [go.mod]
+  go:
+    extends: base
+    command: go
+    timeout: 60s
+    env:
+      B: go
+  ci:
+    env:
+      CI: "true"
+    sources: [ci.yaml]
+tasks:
+  - id: go-vet
+    name: Vet
+    extends: go
+    args: [vet, ./...]
+    env:
+      A: task
+  - id: go-test
+    name: Test
+    extends: [go, ci]
+    args: [test]
+    timeout: 5m
+    sources: !append [go.sum]
+  - id: plain
+    name: Plain
+    type: command
+    command: make
+`)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+	vet, test, plain := config.Tasks[0], config.Tasks[1], config.Tasks[2]
+
+	assert.Equal(t, StringList{"go"}, vet.Extends)
+	assert.Equal(t, TaskTypeCommand, vet.Type)
+	assert.Equal(t, "go", vet.Command)
+	assert.Equal(t, []string{"vet", "./..."}, vet.Args)
+	assert.Equal(t, 60*time.Second, vet.Timeout)
+	assert.Zero(t, vet.RetryCount, "template values win over defaults")
+	assert.Equal(t, map[string]string{"A": "task", "B": "go"}, vet.Env)
+	assert.Equal(t, []string{"go.mod"}, vet.Sources)
+
+	assert.Equal(t, 5*time.Minute, test.Timeout)
+	assert.Equal(t, map[string]string{"A": "base", "B": "go", "CI": "true"}, test.Env)
+	assert.Equal(t, []string{"ci.yaml", "go.sum"}, test.Sources)
+
+	assert.Empty(t, plain.Extends)
+	assert.Equal(t, 3, plain.RetryCount)
+}
+
+func TestLoadConfig_TemplateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "unknown template",
+			content: "version: \"1.0\"\ntasks:\n  - id: a\n    extends: missing\n",
+			wantErr: `task a: unknown template "missing"`,
+		},
+		{
+			name:    "cycle",
+			content: "version: \"1.0\"\ntemplates:\n  x: {extends: y}\n  y: {extends: x}\ntasks:\n  - id: a\n    extends: x\n",
+			wantErr: "template cycle: x -> y -> x",
+		},
+		{
+			name:    "template is not a map",
+			content: "version: \"1.0\"\ntemplates:\n  x: [1]\ntasks:\n  - id: a\n    extends: x\n",
+			wantErr: "template x must be a map",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfigString(t, tt.content)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package task
+
+import "context"
+
+// TraceparentEnv is the environment variable carrying the W3C trace context
+// of a task attempt to the task's process
+const TraceparentEnv = "TRACEPARENT"
+
+// Tracer wraps runs and task attempts in trace spans
+type Tracer interface {
+	// StartRun starts the root span of a run. The returned context carries
+	// the span to the attempts of the run; end finishes it.
+	StartRun(ctx context.Context, runID string, taskIDs []string) (context.Context, func(err error))
+	// StartAttempt starts a child span for one attempt of a task and
+	// returns its W3C traceparent; end finishes it with the attempt's result.
+	StartAttempt(ctx context.Context, task *Task, attempt int) (traceparent string, end func(result *TaskResult))
+}
+
+// noopTracer is used when no tracer is set
+type noopTracer struct{}
+
+func (noopTracer) StartRun(ctx context.Context, runID string, taskIDs []string) (context.Context, func(err error)) {
+	return ctx, func(error) {}
+}
+
+func (noopTracer) StartAttempt(ctx context.Context, task *Task, attempt int) (string, func(*TaskResult)) {
+	return "", func(*TaskResult) {}
+}
@@ -0,0 +1,92 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type varsKey struct{}
+
+// WithVars returns a context carrying run variables. Variables are exported
+// to the environment of every task executed with the context, and
+// ${name} references to them in the command, args, workdir and env values
+// are replaced by their value.
+func WithVars(ctx context.Context, vars map[string]string) context.Context {
+	if len(vars) == 0 {
+		return ctx
+	}
+	merged := make(map[string]string)
+	for k, v := range varsFrom(ctx) {
+		merged[k] = v
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, varsKey{}, merged)
+}
+
+// varsFrom returns the run variables carried by ctx
+func varsFrom(ctx context.Context) map[string]string {
+	vars, _ := ctx.Value(varsKey{}).(map[string]string)
+	return vars
+}
+
+// expandVars replaces ${name} references to known variables. Unknown
+// references are left untouched so shell syntax in commands still works.
+func expandVars(s string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(s, "${") {
+		return s
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+		name := s[start+2 : end]
+		value, ok := vars[name]
+		if !ok {
+			b.WriteString(s[:end+1])
+			s = s[end+1:]
+			continue
+		}
+		b.WriteString(s[:start])
+		b.WriteString(value)
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// expandFields splits s on spaces and then expands variables in each field,
+// so a value always stays a single argument however many spaces it holds.
+// Fields that expand to nothing are dropped.
+func expandFields(s string, vars map[string]string) []string {
+	var fields []string
+	for _, field := range strings.Fields(s) {
+		if field = expandVars(field, vars); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ParseVars parses "key=value" pairs as given on the command line
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
@@ -0,0 +1,375 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long the watcher waits for a burst of file changes
+// to settle before re-running tasks.
+const DefaultDebounce = 300 * time.Millisecond
+
+// Watcher re-runs tasks when the files they declare change
+type Watcher struct {
+	executor *Executor
+	tasks    []*Task
+	debounce time.Duration
+
+	// OnRunStart is called before a batch of tasks is run
+	OnRunStart func(taskIDs []string)
+	// OnRunDone is called once a batch has finished or was cancelled
+	OnRunDone func(taskIDs []string, err error)
+
+	// LockPath, if set, is a lock file each batch holds while it runs, so
+	// other runs of the workspace can take their turn while the watcher is
+	// idle. LockWait makes a batch wait for the lock instead of failing.
+	LockPath string
+	LockWait bool
+}
+
+// watchPattern is an absolute, slash-separated glob owned by a task
+type watchPattern struct {
+	taskID string
+	glob   string
+}
+
+// watchRun is a batch of tasks currently being executed
+type watchRun struct {
+	taskIDs []string
+	cancel  context.CancelFunc
+	done    chan error
+	// interrupted is set once tasks of the batch were cancelled because
+	// their files changed
+	interrupted bool
+}
+
+// NewWatcher creates a watcher for the given tasks. The tasks must already
+// have been added to the executor.
+func NewWatcher(executor *Executor, tasks []*Task, debounce time.Duration) *Watcher {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	return &Watcher{
+		executor: executor,
+		tasks:    tasks,
+		debounce: debounce,
+	}
+}
+
+// Run executes the initial tasks and then blocks, re-running affected tasks
+// and their dependents whenever watched files change, until ctx is done.
+func (w *Watcher) Run(ctx context.Context, initial []string) error {
+	patterns, err := w.patterns()
+	if err != nil {
+		return err
+	}
+	if len(patterns) == 0 {
+		return fmt.Errorf("no tasks declare sources or watch patterns")
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer fsw.Close()
+
+	for _, dir := range watchRoots(patterns) {
+		if err := addRecursive(fsw, dir); err != nil {
+			return err
+		}
+	}
+
+	pending := make(map[string]bool)
+	for _, id := range initial {
+		pending[id] = true
+	}
+	ready := len(pending) > 0
+
+	var current *watchRun
+	var debounceC <-chan time.Time
+
+	start := func() {
+		ids := w.executor.Dependents(setKeys(pending))
+		pending = make(map[string]bool)
+		ready = false
+		current = w.start(ctx, ids)
+	}
+
+	if ready {
+		start()
+	}
+
+	for {
+		var doneC chan error
+		if current != nil {
+			doneC = current.done
+		}
+
+		select {
+		case <-ctx.Done():
+			if current != nil {
+				current.cancel()
+				<-current.done
+			}
+			return nil
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addRecursive(fsw, event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			ids := affectedTasks(patterns, event.Name)
+			if len(ids) == 0 {
+				continue
+			}
+			for _, id := range ids {
+				pending[id] = true
+			}
+			debounceC = time.After(w.debounce)
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("file watcher error: %w", err)
+
+		case <-debounceC:
+			debounceC = nil
+			ready = true
+			if current == nil {
+				start()
+				continue
+			}
+			// Changes affect the batch in flight: cancel the affected tasks
+			// that are running, they are re-run once the batch is over.
+			// Unaffected tasks of the batch run on.
+			if w.executor.CancelTasks(w.executor.Dependents(setKeys(pending))) {
+				current.interrupted = true
+			}
+
+		case err := <-doneC:
+			if w.OnRunDone != nil {
+				w.OnRunDone(current.taskIDs, err)
+			}
+			// Tasks a cancellation kept from running have to run again.
+			// Tasks that failed on their own are left alone.
+			if err == context.Canceled || current.interrupted {
+				for _, id := range current.taskIDs {
+					result, ok := w.executor.GetResult(id)
+					if !ok || (err == context.Canceled && !result.Success) || result.Task.Status == StatusSkipped {
+						pending[id] = true
+					}
+				}
+			}
+			current = nil
+			if ready && len(pending) > 0 {
+				start()
+			}
+		}
+	}
+}
+
+// start runs a batch of tasks in the background
+func (w *Watcher) start(ctx context.Context, taskIDs []string) *watchRun {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &watchRun{
+		taskIDs: taskIDs,
+		cancel:  cancel,
+		done:    make(chan error, 1),
+	}
+
+	if w.OnRunStart != nil {
+		w.OnRunStart(taskIDs)
+	}
+
+	go func() {
+		defer cancel()
+		err := w.execute(runCtx, taskIDs)
+		if runCtx.Err() != nil {
+			err = context.Canceled
+		}
+		run.done <- err
+	}()
+
+	return run
+}
+
+// execute runs a batch of tasks, holding the lock at LockPath if set
+func (w *Watcher) execute(ctx context.Context, taskIDs []string) error {
+	if w.LockPath != "" {
+		lock, err := AcquireLock(ctx, w.LockPath, "task run --watch", w.LockWait)
+		if err != nil {
+			return fmt.Errorf("failed to acquire run lock: %w", err)
+		}
+		defer func() {
+			if err := lock.Release(); err != nil {
+				w.executor.log().Warn("failed to release run lock", "error", err)
+			}
+		}()
+	}
+	return w.executor.ExecuteTasks(ctx, taskIDs)
+}
+
+// patterns collects the absolute watch globs of all watched tasks
+func (w *Watcher) patterns() ([]watchPattern, error) {
+	var patterns []watchPattern
+	for _, t := range w.tasks {
+		for _, glob := range t.WatchPatterns() {
+			if !filepath.IsAbs(glob) && t.WorkDir != "" {
+				glob = filepath.Join(t.WorkDir, glob)
+			}
+			abs, err := filepath.Abs(glob)
+			if err != nil {
+				return nil, fmt.Errorf("task %s: invalid watch pattern %q: %w", t.ID, glob, err)
+			}
+			// A plain directory watches everything below it
+			if !hasGlobMeta(abs) {
+				if info, err := os.Stat(abs); err == nil && info.IsDir() {
+					abs = filepath.Join(abs, "**")
+				}
+			}
+			patterns = append(patterns, watchPattern{taskID: t.ID, glob: filepath.ToSlash(abs)})
+		}
+	}
+	return patterns, nil
+}
+
+// watchRoots returns the deepest existing directories that contain every
+// file the patterns can match.
+func watchRoots(patterns []watchPattern) []string {
+	seen := make(map[string]bool)
+	var roots []string
+	for _, p := range patterns {
+		segments := strings.Split(p.glob, "/")
+		base := []string{}
+		for _, segment := range segments[:len(segments)-1] {
+			if hasGlobMeta(segment) {
+				break
+			}
+			base = append(base, segment)
+		}
+		dir := filepath.FromSlash(strings.Join(base, "/"))
+		if dir == "" {
+			dir = string(filepath.Separator)
+		}
+		// Fall back to the closest existing parent
+		for {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+// addRecursive watches dir and all its sub-directories, skipping hidden ones
+func addRecursive(fsw *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories may vanish while we walk them
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := fsw.Add(p); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// affectedTasks returns the IDs of tasks with a pattern matching the file
+func affectedTasks(patterns []watchPattern, name string) []string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil
+	}
+	abs = filepath.ToSlash(abs)
+
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		if matchGlob(p.glob, abs) {
+			seen[p.taskID] = true
+		}
+	}
+	return setKeys(seen)
+}
+
+// matchGlob reports whether a slash-separated name matches the pattern.
+// In addition to path.Match syntax, a "**" segment matches any number of
+// directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
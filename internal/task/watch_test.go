@@ -0,0 +1,198 @@
+package task
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"/src/*.go", "/src/main.go", true},
+		{"/src/*.go", "/src/pkg/main.go", false},
+		{"/src/**/*.go", "/src/main.go", true},
+		{"/src/**/*.go", "/src/a/b/main.go", true},
+		{"/src/**", "/src/a/b/c.txt", true},
+		{"/src/**/*.go", "/src/a/b/main.txt", false},
+		{"/src/main.go", "/other/main.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name))
+		})
+	}
+}
+
+func TestExecutor_Dependents(t *testing.T) {
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks([]*Task{
+		{ID: "a", Name: "A", Type: TaskTypeCommand, Command: "go version"},
+		{ID: "b", Name: "B", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"a"}},
+		{ID: "c", Name: "C", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"b"}},
+		{ID: "d", Name: "D", Type: TaskTypeCommand, Command: "go version"},
+	}))
+
+	assert.Equal(t, []string{"a", "b", "c"}, executor.Dependents([]string{"a"}))
+	assert.Equal(t, []string{"b", "c"}, executor.Dependents([]string{"b"}))
+	assert.Equal(t, []string{"d"}, executor.Dependents([]string{"d"}))
+}
+
+func TestWatcher_RerunsAffectedTasks(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("docs"), 0600))
+
+	tasks := []*Task{
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version", Sources: []string{filepath.Join(dir, "*.go")}},
+		{ID: "test", Name: "Test", Type: TaskTypeCommand, Command: "go version", DependsOn: []string{"build"}},
+		{ID: "docs", Name: "Docs", Type: TaskTypeCommand, Command: "go version", Watch: []string{filepath.Join(dir, "*.md")}},
+	}
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks(tasks))
+
+	runs := make(chan []string, 10)
+	watcher := NewWatcher(executor, tasks, 50*time.Millisecond)
+	watcher.OnRunDone = func(ids []string, err error) {
+		runs <- ids
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx, []string{"build", "test", "docs"}) }()
+
+	select {
+	case ids := <-runs:
+		assert.Equal(t, []string{"build", "docs", "test"}, ids)
+	case <-time.After(10 * time.Second):
+		t.Fatal("initial run did not finish")
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0600))
+
+	select {
+	case ids := <-runs:
+		assert.Equal(t, []string{"build", "test"}, ids)
+	case <-time.After(10 * time.Second):
+		t.Fatal("change did not trigger a re-run")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatcher_LocksEachBatch(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "run.lock")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0600))
+
+	tasks := []*Task{
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Command: "go version", Sources: []string{filepath.Join(dir, "*.go")}},
+	}
+	executor := NewExecutor(1, false)
+	require.NoError(t, executor.AddTasks(tasks))
+
+	runs := make(chan error, 10)
+	watcher := NewWatcher(executor, tasks, 50*time.Millisecond)
+	watcher.LockPath = lockPath
+	watcher.LockWait = true
+	watcher.OnRunDone = func(ids []string, err error) {
+		runs <- err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx, []string{"build"}) }()
+
+	select {
+	case err := <-runs:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("initial run did not finish")
+	}
+
+	// The lock is free while the watcher is idle
+	lock, err := AcquireLock(context.Background(), lockPath, "test", false)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0600))
+	select {
+	case <-runs:
+		t.Fatal("batch ran while another process held the lock")
+	case <-time.After(3 * lockPollInterval):
+	}
+
+	require.NoError(t, lock.Release())
+	select {
+	case err := <-runs:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("batch did not run after the lock was released")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatcher_CancelsOnlyAffectedTasks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0600))
+
+	tasks := []*Task{
+		{ID: "build", Name: "Build", Type: TaskTypeCommand, Shell: "sh", Command: "sleep 1", Sources: []string{filepath.Join(dir, "*.go")}},
+		{ID: "lint", Name: "Lint", Type: TaskTypeCommand, Shell: "sh", Command: "sleep 1"},
+	}
+	executor := NewExecutor(2, false)
+	require.NoError(t, executor.AddTasks(tasks))
+
+	type batch struct {
+		ids  []string
+		lint bool
+	}
+	runs := make(chan batch, 10)
+	watcher := NewWatcher(executor, tasks, 50*time.Millisecond)
+	watcher.OnRunDone = func(ids []string, err error) {
+		lint, ok := executor.GetResult("lint")
+		runs <- batch{ids: ids, lint: ok && lint.Success}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx, []string{"build", "lint"}) }()
+
+	time.Sleep(300 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0600))
+
+	select {
+	case b := <-runs:
+		assert.Equal(t, []string{"build", "lint"}, b.ids)
+		assert.True(t, b.lint, "unaffected tasks of the batch run on")
+	case <-time.After(10 * time.Second):
+		t.Fatal("initial run did not finish")
+	}
+	select {
+	case b := <-runs:
+		assert.Equal(t, []string{"build"}, b.ids)
+	case <-time.After(10 * time.Second):
+		t.Fatal("cancelled task was not re-run")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,213 @@
+// Package tracing records runs and task attempts as OpenTelemetry spans and
+// exports them over OTLP.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// IsValid reports whether the ID is not all zeros
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies a span across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01"
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	// Later versions may append fields, version 00 has exactly four
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace ID in traceparent: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span ID in traceparent: %w", err)
+	}
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return sc, nil
+}
+
+// Span is a finished or in-progress operation
+type Span struct {
+	Name         string
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{}
+	// Error is set when the operation failed
+	Error string
+}
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+type remoteParentKey struct{}
+
+// WithRemoteParent returns a context whose runs join the trace of parent,
+// e.g. one passed to this process in TRACEPARENT
+func WithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteParentKey{}, parent)
+}
+
+// runSpanKey carries the run span and the spans collected for the run
+type runSpanKey struct{}
+
+type runTrace struct {
+	root *Span
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (rt *runTrace) add(span *Span) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.spans = append(rt.spans, span)
+}
+
+// exportTimeout bounds exporting the spans of one run
+const exportTimeout = 10 * time.Second
+
+// Tracer implements task.Tracer. The spans of a run are exported together
+// when the run finishes.
+type Tracer struct {
+	exporter Exporter
+	logger   *slog.Logger
+}
+
+var _ task.Tracer = (*Tracer)(nil)
+
+// NewTracer creates a tracer exporting to exporter. Export failures are
+// logged to logger and never fail the run.
+func NewTracer(exporter Exporter, logger *slog.Logger) *Tracer {
+	return &Tracer{exporter: exporter, logger: logger}
+}
+
+// StartRun starts the root span of a run
+func (t *Tracer) StartRun(ctx context.Context, runID string, taskIDs []string) (context.Context, func(err error)) {
+	root := &Span{
+		Name:    "task run",
+		TraceID: newTraceID(),
+		SpanID:  newSpanID(),
+		Start:   time.Now(),
+		Attributes: map[string]interface{}{
+			"run.id":         runID,
+			"run.task_count": len(taskIDs),
+		},
+	}
+	if parent, ok := ctx.Value(remoteParentKey{}).(SpanContext); ok {
+		root.TraceID = parent.TraceID
+		root.ParentSpanID = parent.SpanID
+	}
+
+	rt := &runTrace{root: root}
+	ctx = context.WithValue(ctx, runSpanKey{}, rt)
+
+	return ctx, func(err error) {
+		root.End = time.Now()
+		if err != nil {
+			root.Error = err.Error()
+		}
+		rt.add(root)
+
+		rt.mu.Lock()
+		spans := rt.spans
+		rt.spans = nil
+		rt.mu.Unlock()
+
+		exportCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := t.exporter.Export(exportCtx, spans); err != nil {
+			t.logger.Warn("failed to export trace", "run_id", runID, "trace_id", root.TraceID.String(), "error", err)
+		}
+	}
+}
+
+// StartAttempt starts a child span of the run span for a task attempt
+func (t *Tracer) StartAttempt(ctx context.Context, tk *task.Task, attempt int) (string, func(*task.TaskResult)) {
+	rt, ok := ctx.Value(runSpanKey{}).(*runTrace)
+	if !ok {
+		return "", func(*task.TaskResult) {}
+	}
+
+	span := &Span{
+		Name:         "task " + tk.ID,
+		TraceID:      rt.root.TraceID,
+		SpanID:       newSpanID(),
+		ParentSpanID: rt.root.SpanID,
+		Start:        time.Now(),
+		Attributes: map[string]interface{}{
+			"task.id":      tk.ID,
+			"task.name":    tk.Name,
+			"task.type":    string(tk.Type),
+			"task.attempt": attempt,
+		},
+	}
+	sc := SpanContext{TraceID: span.TraceID, SpanID: span.SpanID}
+
+	return sc.Traceparent(), func(result *task.TaskResult) {
+		span.End = time.Now()
+		span.Attributes["task.exit_code"] = result.ExitCode
+		if !result.Success && result.Error != nil {
+			span.Error = result.Error.Error()
+		}
+		rt.add(span)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-cli-tool/internal/task"
+)
+
+// collectorStub records the OTLP export requests it receives
+type collectorStub struct {
+	mu       sync.Mutex
+	requests []exportRequest
+	headers  []http.Header
+	paths    []string
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req exportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header.Clone())
+	c.paths = append(c.paths, r.URL.Path)
+	c.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *collectorStub) spans() []otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var spans []otlpSpan
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func attr(span otlpSpan, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key != key {
+			continue
+		}
+		switch {
+		case kv.Value.StringValue != nil:
+			return *kv.Value.StringValue
+		case kv.Value.IntValue != nil:
+			return *kv.Value.IntValue
+		}
+	}
+	return ""
+}
+
+func newTestTracer(t *testing.T, collector *collectorStub) *Tracer {
+	t.Helper()
+	ts := httptest.NewServer(collector)
+	t.Cleanup(ts.Close)
+	exporter := NewOTLPExporter(ts.URL, map[string]string{"Authorization": "Bearer abc"})
+	return NewTracer(exporter, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestTracer_ExportsRunAndAttemptSpans(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses printenv")
+	}
+	collector := &collectorStub{}
+	executor := task.NewExecutor(1, false)
+	executor.SetTracer(newTestTracer(t, collector))
+	executor.SetRunID("run-1")
+
+	require.NoError(t, executor.AddTask(&task.Task{
+		ID: "parent", Name: "Parent", Type: task.TaskTypeCommand, Command: "printenv", Args: []string{"TRACEPARENT"},
+	}))
+	require.NoError(t, executor.AddTask(&task.Task{
+		ID: "bad", Name: "Bad", Type: task.TaskTypeCommand, Command: "go", Args: []string{"no-such-command"},
+		DependsOn: []string{"parent"},
+	}))
+	assert.Error(t, executor.ExecuteAll(context.Background()))
+
+	require.Len(t, collector.requests, 1)
+	assert.Equal(t, "/v1/traces", collector.paths[0])
+	assert.Equal(t, "Bearer abc", collector.headers[0].Get("Authorization"))
+	assert.Equal(t, ServiceName, *collector.requests[0].ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := collector.spans()
+	require.Len(t, spans, 3)
+	byName := make(map[string]otlpSpan)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	root := byName["task run"]
+	assert.Empty(t, root.ParentSpanID)
+	assert.Equal(t, "run-1", attr(root, "run.id"))
+	assert.Equal(t, statusCodeError, root.Status.Code)
+
+	ok := byName["task parent"]
+	assert.Equal(t, root.TraceID, ok.TraceID)
+	assert.Equal(t, root.SpanID, ok.ParentSpanID)
+	assert.Equal(t, "parent", attr(ok, "task.id"))
+	assert.Equal(t, "command", attr(ok, "task.type"))
+	assert.Equal(t, "1", attr(ok, "task.attempt"))
+	assert.Equal(t, "0", attr(ok, "task.exit_code"))
+	assert.Equal(t, statusCodeOK, ok.Status.Code)
+
+	bad := byName["task bad"]
+	assert.Equal(t, root.SpanID, bad.ParentSpanID)
+	assert.NotEqual(t, "0", attr(bad, "task.exit_code"))
+	assert.Equal(t, statusCodeError, bad.Status.Code)
+
+	// The task saw its own span as TRACEPARENT
+	result, found := executor.GetResult("parent")
+	require.True(t, found)
+	assert.Equal(t, "00-"+ok.TraceID+"-"+ok.SpanID+"-01", strings.TrimSpace(result.Output))
+}
+
+func TestTracer_JoinsRemoteParent(t *testing.T) {
+	collector := &collectorStub{}
+	executor := task.NewExecutor(1, false)
+	executor.SetTracer(newTestTracer(t, collector))
+	require.NoError(t, executor.AddTask(&task.Task{
+		ID: "version", Name: "Version", Type: task.TaskTypeCommand, Command: "go version",
+	}))
+
+	parent, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	_, err = executor.ExecuteTask(WithRemoteParent(context.Background(), parent), "version")
+	require.NoError(t, err)
+
+	for _, span := range collector.spans() {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+		if span.Name == "task run" {
+			assert.Equal(t, "00f067aa0ba902b7", span.ParentSpanID)
+		}
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz", false},
+		{"version 00 with extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz", true},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true},
+		{"short span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01", true},
+		{"not hex", "00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+			assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+		})
+	}
+}
+
+func TestNewOTLPExporter_URL(t *testing.T) {
+	assert.Equal(t, "http://localhost:4318/v1/traces", NewOTLPExporter("http://localhost:4318/", nil).url)
+	assert.Equal(t, "http://collector/v1/traces", NewOTLPExporter("http://collector/v1/traces", nil).url)
+}
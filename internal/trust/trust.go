@@ -0,0 +1,245 @@
+// Package trust records which task configuration files the user trusts to
+// run, by path and content hash, in the trust section of the user config.
+package trust
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Status is the trust status of a configuration file
+type Status int
+
+const (
+	Untrusted Status = iota // Never trusted
+	Changed                 // Trusted, but its content changed since
+	Trusted                 // Trusted with its current content
+)
+
+// Entry records a trusted configuration file
+type Entry struct {
+	Path      string    `yaml:"path"`
+	SHA256    string    `yaml:"sha256"`
+	TrustedAt time.Time `yaml:"trusted_at"`
+}
+
+// Store keeps trust entries under trust.configs in a YAML file, leaving the
+// rest of the file as it is
+type Store struct {
+	path string
+}
+
+// NewStore creates a store backed by the YAML file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Hash returns the SHA-256 of a file's content, hex-encoded
+func Hash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	return hashContent(data), nil
+}
+
+// hashContent returns the SHA-256 of data, hex-encoded
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Entries returns the trusted configuration files
+func (s *Store) Entries() ([]Entry, error) {
+	doc, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if node := configsNode(doc, false); node != nil {
+		if err := node.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid trust entries in %s: %w", s.path, err)
+		}
+	}
+	return entries, nil
+}
+
+// Check returns the trust status of the configuration file at path
+func (s *Store) Check(path string) (Status, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Untrusted, err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return Untrusted, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return s.CheckContent(abs, data)
+}
+
+// CheckContent returns the trust status of the configuration file at path
+// if its content were data, so content already read can be checked without
+// reading the file again
+func (s *Store) CheckContent(path string, data []byte) (Status, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Untrusted, err
+	}
+	hash := hashContent(data)
+	entries, err := s.Entries()
+	if err != nil {
+		return Untrusted, err
+	}
+	for _, entry := range entries {
+		if entry.Path == abs {
+			if entry.SHA256 == hash {
+				return Trusted, nil
+			}
+			return Changed, nil
+		}
+	}
+	return Untrusted, nil
+}
+
+// Trust records the configuration file at path with its current content
+func (s *Store) Trust(path string) (Entry, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return s.TrustContent(abs, data)
+}
+
+// TrustContent records the configuration file at path with content data, so
+// the content that was reviewed is trusted even if the file changed since
+func (s *Store) TrustContent(path string, data []byte) (Entry, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Path: abs, SHA256: hashContent(data), TrustedAt: time.Now().UTC().Truncate(time.Second)}
+
+	err = s.update(func(entries []Entry) []Entry {
+		kept := removeEntry(entries, abs)
+		return append(kept, entry)
+	})
+	return entry, err
+}
+
+// Untrust removes the configuration file at path, reporting whether it was
+// trusted
+func (s *Store) Untrust(path string) (bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	found := false
+	err = s.update(func(entries []Entry) []Entry {
+		kept := removeEntry(entries, abs)
+		found = len(kept) < len(entries)
+		return kept
+	})
+	return found, err
+}
+
+func removeEntry(entries []Entry, path string) []Entry {
+	kept := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Path != path {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// update rewrites the trust entries with fn
+func (s *Store) update(fn func([]Entry) []Entry) error {
+	entries, err := s.Entries()
+	if err != nil {
+		return err
+	}
+	doc, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	var value yaml.Node
+	if err := value.Encode(fn(entries)); err != nil {
+		return fmt.Errorf("failed to encode trust entries: %w", err)
+	}
+	*configsNode(doc, true) = value
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", s.path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// load reads the YAML document of the store's file; a missing or empty
+// file is an empty document
+func (s *Store) load() (*yaml.Node, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s must contain a map", s.path)
+	}
+	return &doc, nil
+}
+
+// configsNode returns the trust.configs node of doc, creating it if create
+// is set, or nil if it does not exist
+func configsNode(doc *yaml.Node, create bool) *yaml.Node {
+	node := doc.Content[0]
+	for _, key := range []string{"trust", "configs"} {
+		var child *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				child = node.Content[i+1]
+			}
+		}
+		if child == nil {
+			if !create {
+				return nil
+			}
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+		}
+		if child.Kind != yaml.MappingNode && key == "trust" {
+			if !create {
+				return nil
+			}
+			child.Kind, child.Tag, child.Value, child.Content = yaml.MappingNode, "", "", nil
+		}
+		node = child
+	}
+	return node
+}
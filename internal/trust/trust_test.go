@@ -0,0 +1,85 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	userConfig := filepath.Join(dir, "home", ".go-cli-tool.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(userConfig), 0750))
+	require.NoError(t, os.WriteFile(userConfig, []byte("log:\n  level: debug # keep me\n"), 0600))
+	config := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(config, []byte("version: \"1.0\"\n"), 0600))
+	store := NewStore(userConfig)
+
+	status, err := store.Check(config)
+	require.NoError(t, err)
+	assert.Equal(t, Untrusted, status)
+
+	entry, err := store.Trust(config)
+	require.NoError(t, err)
+	assert.Equal(t, config, entry.Path)
+	status, err = store.Check(config)
+	require.NoError(t, err)
+	assert.Equal(t, Trusted, status)
+
+	data, err := os.ReadFile(userConfig)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "level: debug # keep me")
+	assert.Contains(t, string(data), "trust:\n  configs:\n")
+
+	require.NoError(t, os.WriteFile(config, []byte("version: \"1.0\"\ntasks: []\n"), 0600))
+	status, err = store.Check(config)
+	require.NoError(t, err)
+	assert.Equal(t, Changed, status)
+	status, err = store.CheckContent(config, []byte("version: \"1.0\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Trusted, status, "content already read is checked as it was")
+
+	// Trusting again replaces the entry
+	_, err = store.TrustContent(config, []byte("version: \"2.0\"\n"))
+	require.NoError(t, err)
+	status, err = store.Check(config)
+	require.NoError(t, err)
+	assert.Equal(t, Changed, status, "the content given is trusted, not the file's")
+	_, err = store.Trust(config)
+	require.NoError(t, err)
+	entries, err := store.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	found, err := store.Untrust(config)
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = store.Untrust(config)
+	require.NoError(t, err)
+	assert.False(t, found)
+	status, err = store.Check(config)
+	require.NoError(t, err)
+	assert.Equal(t, Untrusted, status)
+}
+
+func TestStore_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "new", ".go-cli-tool.yaml"))
+	entries, err := store.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	config := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(config, nil, 0600))
+	_, err = store.Trust(config)
+	require.NoError(t, err)
+	entries, err = store.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = store.Check(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read config file")
+}